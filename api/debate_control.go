@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ==================== 辩论控制 ====================
+// 异步辩论可以暂停、继续、取消，也可以插入主持人提示（写入下一位发言者的上下文）
+
+// DebateNoteRequest 主持人提示
+type DebateNoteRequest struct {
+	Note string `json:"note"`
+}
+
+// StatusEvent status 事件的数据
+type StatusEvent struct {
+	Status DebateStatus `json:"status"`
+}
+
+// finished 辩论是否已结束
+func (st DebateStatus) finished() bool {
+	return st == DebateStatusCompleted || st == DebateStatusFailed || st == DebateStatusCancelled
+}
+
+// controlledDebate 查找可以控制的辩论（调用方需持有 debateMutex），失败时已写入响应
+func (s *Server) controlledDebate(w http.ResponseWriter, r *http.Request) (*DebateSession, bool) {
+	session, ok := s.debates[r.PathValue("id")]
+	if !ok {
+		http.Error(w, "Debate not found", http.StatusNotFound)
+		return nil, false
+	}
+	if session.control == nil || session.Status.finished() {
+		http.Error(w, "Debate is not running", http.StatusConflict)
+		return nil, false
+	}
+	return session, true
+}
+
+// setStatus 更新辩论状态并推送 status 事件（调用方需持有 debateMutex）
+func (d *DebateSession) setStatus(status DebateStatus) {
+	d.Status = status
+	d.publish(StreamEvent{Type: EventStatus, Data: StatusEvent{Status: status}})
+}
+
+// handleDebatePause 暂停辩论：当前发言结束后不再开始新的发言
+func (s *Server) handleDebatePause(w http.ResponseWriter, r *http.Request) {
+	s.debateMutex.Lock()
+	defer s.debateMutex.Unlock()
+	session, ok := s.controlledDebate(w, r)
+	if !ok {
+		return
+	}
+	if !session.control.Pause() {
+		http.Error(w, "Debate is already paused", http.StatusConflict)
+		return
+	}
+	session.setStatus(DebateStatusPaused)
+	writeJSON(w, session.response())
+}
+
+// handleDebateResume 继续暂停中的辩论
+func (s *Server) handleDebateResume(w http.ResponseWriter, r *http.Request) {
+	s.debateMutex.Lock()
+	defer s.debateMutex.Unlock()
+	session, ok := s.controlledDebate(w, r)
+	if !ok {
+		return
+	}
+	if !session.control.Resume() {
+		http.Error(w, "Debate is not paused", http.StatusConflict)
+		return
+	}
+	session.setStatus(DebateStatusRunning)
+	writeJSON(w, session.response())
+}
+
+// handleDebateCancel 取消辩论：中断正在进行的发言，辩论结束后状态为 cancelled
+func (s *Server) handleDebateCancel(w http.ResponseWriter, r *http.Request) {
+	s.debateMutex.Lock()
+	defer s.debateMutex.Unlock()
+	session, ok := s.controlledDebate(w, r)
+	if !ok {
+		return
+	}
+	if !session.control.Cancel() {
+		http.Error(w, "Debate is already being cancelled", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleDebateNote 插入主持人提示，下一位发言者会在上下文中看到
+func (s *Server) handleDebateNote(w http.ResponseWriter, r *http.Request) {
+	var req DebateNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Note) == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	s.debateMutex.Lock()
+	defer s.debateMutex.Unlock()
+	session, ok := s.controlledDebate(w, r)
+	if !ok {
+		return
+	}
+	session.control.AddNote(strings.TrimSpace(req.Note))
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"agent/philosopher"
+)
+
+// ==================== 人类参与辩论 ====================
+
+// errNotHumanTurn 当前没有在等待人类发言
+var errNotHumanTurn = errors.New("not the human speaker's turn")
+
+// DebateSpeakRequest 人类提交发言
+type DebateSpeakRequest struct {
+	Content string `json:"content"`
+	Pass    bool   `json:"pass,omitempty"` // 跳过本次发言
+}
+
+// humanSeat API 端的人类发言席：引擎等待发言时记录当前回合，/api/debate/{id}/speak 提交发言
+type humanSeat struct {
+	mu        sync.Mutex
+	turn      *philosopher.HumanTurn
+	submitted bool
+	speech    chan string // 容量为 1，每个回合最多提交一次
+
+	onTurn func(turn *philosopher.HumanTurn) // 开始 / 结束等待及截止时间顺延时回调（结束时为 nil），持有 mu 调用
+}
+
+func newHumanSeat(onTurn func(turn *philosopher.HumanTurn)) *humanSeat {
+	return &humanSeat{speech: make(chan string, 1), onTurn: onTurn}
+}
+
+// Await 实现 philosopher.HumanInput：等待提交或 ctx 到期
+func (h *humanSeat) Await(ctx context.Context, turn philosopher.HumanTurn) (string, error) {
+	h.mu.Lock()
+	// 丢弃上一回合超时后才到达的提交
+	select {
+	case <-h.speech:
+	default:
+	}
+	h.turn, h.submitted = &turn, false
+	h.onTurn(&turn)
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.turn = nil
+		h.onTurn(nil)
+	}()
+
+	select {
+	case content := <-h.speech:
+		return content, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// UpdateTurn 实现 philosopher.HumanTurnUpdater：暂停后继续时更新截止时间并重新推送回合
+func (h *humanSeat) UpdateTurn(turn philosopher.HumanTurn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.turn == nil {
+		return
+	}
+	h.turn = &turn
+	h.onTurn(&turn)
+}
+
+// Submit 提交本回合的发言，空字符串表示跳过
+func (h *humanSeat) Submit(content string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.turn == nil || h.submitted {
+		return errNotHumanTurn
+	}
+	h.submitted = true
+	h.speech <- content
+	return nil
+}
+
+// handleDebateSpeak 人类参与者提交发言（仅在轮到人类且辩论未暂停时有效）
+func (s *Server) handleDebateSpeak(w http.ResponseWriter, r *http.Request) {
+	var req DebateSpeakRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Content == "" && !req.Pass) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Pass {
+		req.Content = ""
+	}
+
+	debateID := r.PathValue("id")
+	s.debateMutex.RLock()
+	session, ok := s.debates[debateID]
+	s.debateMutex.RUnlock()
+	if !ok {
+		http.Error(w, "Debate not found", http.StatusNotFound)
+		return
+	}
+	if session.human == nil {
+		http.Error(w, "Debate has no human speaker", http.StatusConflict)
+		return
+	}
+
+	// 暂停期间人类的计时已停止，发言要等继续之后
+	if session.control.Paused() {
+		http.Error(w, "Debate is paused", http.StatusConflict)
+		return
+	}
+	if err := session.human.Submit(req.Content); err != nil {
+		http.Error(w, "Not your turn to speak", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -1,12 +1,18 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"agent/config"
+	"agent/metrics"
 	"agent/philosopher"
 	"agent/react"
 
@@ -15,39 +21,60 @@ import (
 
 // Server HTTP API 服务器
 type Server struct {
-	model           *config.ChatModel
+	model           config.Provider
 	faultTolerant   *config.FaultTolerantModel
 	emotionAnalyzer *philosopher.EmotionAnalyzer
 	deduplicator    *philosopher.ContentDeduplicator
 	cache           *config.ResponseCache
+	settings        atomic.Pointer[serverSettings] // 可热更新的设置，ApplyConfig 整体替换
+	usage           *config.UsageTracker           // 全局 token 用量与费用
 
-	// 会话管理
-	sessions     map[string]*Session
-	sessionMutex sync.RWMutex
+	// 会话管理：历史持久化在 SessionStore 中，用量统计只保存在内存里
+	sessions     SessionStore
+	sessionUsage map[string]*sessionUsage
+	sessionMutex sync.Mutex
 
 	// 辩论管理
 	debates     map[string]*DebateSession
 	debateMutex sync.RWMutex
 }
 
-// Session 用户会话
-type Session struct {
-	ID           string
-	Messages     []config.Message
-	Philosopher  philosopher.PhilosopherType
-	LastActivity time.Time
+// serverSettings 可热更新的服务器设置；请求处理时读取当时的快照，不会读到更新了一半的配置
+type serverSettings struct {
+	corsEnabled    bool          // 是否启用 CORS（Start 时读取，修改需重启）
+	aiEmotion      bool          // 是否启用 AI 深度情绪分析
+	requestTimeout time.Duration // 单个请求的处理时限，0 表示不限制
+	temperature    float64       // 配置的温度（重新生成时在此基础上提高）
+	pricing        config.PricingConfig
+	budget         config.BudgetConfig
+	sessionTTL     time.Duration // 空闲会话的清除时限，0 表示永不清除
+}
+
+// sessionUsage 会话的用量累计（不持久化，重启后重新计数）
+type sessionUsage struct {
+	total *config.UsageTracker // 会话累计用量
+	daily *config.UsageTracker // 当天用量（每日预算）
+	day   string               // daily 对应的日期
 }
 
 // DebateSession 辩论会话
 type DebateSession struct {
-	ID           string                     `json:"id"`
-	Status       DebateStatus               `json:"status"`
-	Topic        string                     `json:"topic"`
-	CurrentPhase philosopher.DebatePhase    `json:"current_phase"`
-	Records      []philosopher.DebateRecord `json:"records"`
-	StartTime    time.Time                  `json:"start_time"`
-	EndTime      *time.Time                 `json:"end_time,omitempty"`
-	Error        string                     `json:"error,omitempty"`
+	ID            string                     `json:"id"`
+	Status        DebateStatus               `json:"status"`
+	Topic         string                     `json:"topic"`
+	Format        string                     `json:"format"`
+	CurrentPhase  philosopher.DebatePhase    `json:"current_phase"`
+	Records       []philosopher.DebateRecord `json:"records"`
+	Judgement     *philosopher.Judgement     `json:"judgement,omitempty"`
+	AwaitingHuman *philosopher.HumanTurn     `json:"awaiting_human,omitempty"` // 正在等待人类发言的回合
+	StartTime     time.Time                  `json:"start_time"`
+	EndTime       *time.Time                 `json:"end_time,omitempty"`
+	Error         string                     `json:"error,omitempty"`
+
+	usage       *config.UsageTracker       // 辩论累计用量（按发言者拆分）
+	subscribers []chan StreamEvent         // SSE 订阅者（/api/debate/events）
+	human       *humanSeat                 // 人类发言席，没有人类参与者时为 nil
+	control     *philosopher.DebateControl // 暂停 / 继续 / 取消，只有异步辩论可控制
 }
 
 // DebateStatus 辩论状态
@@ -58,39 +85,200 @@ const (
 	DebateStatusRunning   DebateStatus = "running"   // 进行中
 	DebateStatusCompleted DebateStatus = "completed" // 已完成
 	DebateStatusFailed    DebateStatus = "failed"    // 失败
+	DebateStatusPaused    DebateStatus = "paused"    // 已暂停
+	DebateStatusCancelled DebateStatus = "cancelled" // 已取消
 )
 
 // NewServer 创建 API 服务器
-func NewServer(model *config.ChatModel) *Server {
-	return &Server{
-		model:           model,
-		emotionAnalyzer: philosopher.NewEmotionAnalyzer(model),
+func NewServer(model config.Provider) *Server {
+	// 所有模型调用都经过响应缓存，只有标记为可缓存的确定性调用会读写
+	cache := config.NewResponseCache(100, 30*time.Minute)
+	cached := config.NewCachedModel(model, cache)
+
+	s := &Server{
+		model:           cached,
+		emotionAnalyzer: philosopher.NewEmotionAnalyzer(cached),
 		deduplicator:    philosopher.NewContentDeduplicator(0.7),
-		cache:           config.NewResponseCache(100, 30*time.Minute),
-		sessions:        make(map[string]*Session),
+		cache:           cache,
+		usage:           config.NewUsageTracker(config.PricingConfig{}),
+		sessionUsage:    make(map[string]*sessionUsage),
 		debates:         make(map[string]*DebateSession),
 	}
+
+	s.settings.Store(&serverSettings{corsEnabled: true, aiEmotion: true, temperature: 0.7})
+
+	// 传入的是容错模型时记录下来，便于获取统计信息
+	if ft, ok := model.(*config.FaultTolerantModel); ok {
+		s.faultTolerant = ft
+	}
+
+	metrics.ActiveSessions.Set(s.activeSessions)
+	metrics.ActiveDebates.Set(s.activeDebates)
+	return s
+}
+
+// activeSessions 当前会话数（/metrics）
+func (s *Server) activeSessions() float64 {
+	if s.sessions == nil {
+		return 0
+	}
+	n, err := s.sessions.Count()
+	if err != nil {
+		log.Warn().Err(err).Msg("统计会话数失败")
+	}
+	return float64(n)
+}
+
+// activeDebates 尚未结束的辩论数（/metrics）
+func (s *Server) activeDebates() float64 {
+	s.debateMutex.RLock()
+	defer s.debateMutex.RUnlock()
+	active := 0
+	for _, d := range s.debates {
+		if !d.Status.finished() {
+			active++
+		}
+	}
+	return float64(active)
 }
 
 // NewServerWithFaultTolerant 创建带容错的 API 服务器
 func NewServerWithFaultTolerant(ft *config.FaultTolerantModel) *Server {
-	return &Server{
-		faultTolerant:   ft,
-		emotionAnalyzer: philosopher.NewEmotionAnalyzer(nil),
-		deduplicator:    philosopher.NewContentDeduplicator(0.7),
-		cache:           config.NewResponseCache(100, 30*time.Minute),
-		sessions:        make(map[string]*Session),
-		debates:         make(map[string]*DebateSession),
+	return NewServer(ft)
+}
+
+// ApplyConfig 根据配置文件调整服务器：缓存容量/过期时间、CORS、AI 情绪分析、价格表、预算等
+// 配置热更新时会再次调用；CORS 与缓存持久化路径只在启动时生效
+func (s *Server) ApplyConfig(cfg *config.Config) {
+	s.settings.Store(&serverSettings{
+		corsEnabled:    cfg.Server.CORSEnabled,
+		aiEmotion:      cfg.Emotion.EnableAIAnalysis,
+		requestTimeout: time.Duration(cfg.Server.RequestTimeout) * time.Second,
+		temperature:    cfg.Temperature,
+		pricing:        cfg.Pricing,
+		budget:         cfg.Budget,
+		sessionTTL:     time.Duration(cfg.Sessions.IdleTTLMinutes) * time.Minute,
+	})
+	s.usage.SetPricing(cfg.Pricing)
+	s.cache.Configure(cfg.Cache.MaxSize, time.Duration(cfg.Cache.ExpirationMinutes)*time.Minute, cfg.Cache.SemanticThreshold)
+	if cfg.Cache.Path != "" && !s.cache.Stats().Persistent {
+		if err := s.cache.Persist(cfg.Cache.Path); err != nil {
+			log.Error().Err(err).Str("path", cfg.Cache.Path).Msg("响应缓存持久化失败，仅使用内存缓存")
+		}
+	}
+	s.emotionAnalyzer.SetAIAnalysis(cfg.Emotion.EnableAIAnalysis)
+}
+
+// SetSessionStore 设置会话存储，未设置时 Start 使用内存中的 SQLite
+func (s *Server) SetSessionStore(store SessionStore) {
+	s.sessions = store
+}
+
+// requestContext 为请求创建带时限的 context（server.request_timeout）
+// 客户端断开时 r.Context() 被取消，进行中的模型调用随之中止
+func (s *Server) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	timeout := s.settings.Load().requestTimeout
+	if timeout <= 0 {
+		return context.WithCancel(r.Context())
 	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// trackUsage 为本轮请求创建用量累计器，并与会话、全局累计器一起挂到 ctx 上
+func (s *Server) trackUsage(ctx context.Context, trackers ...*config.UsageTracker) (context.Context, *config.UsageTracker) {
+	turn := config.NewUsageTracker(s.settings.Load().pricing)
+	return config.WithUsageTracker(ctx, append([]*config.UsageTracker{turn, s.usage}, trackers...)...), turn
+}
+
+// trackTurn 为一轮对话挂上用量累计与预算：单轮上限 + 会话每日上限
+func (s *Server) trackTurn(ctx context.Context, session *Session) (context.Context, *config.UsageTracker) {
+	total, daily := s.sessionTrackers(session.ID)
+	ctx, turn := s.trackUsage(ctx, total, daily)
+	budget := s.settings.Load().budget
+	return config.WithBudget(ctx,
+		config.NewBudget(config.BudgetScopeTurn, turn, budget.TurnTokens, 0, budget.DegradeRatio),
+		config.NewBudget(config.BudgetScopeSession, daily, budget.SessionDailyTokens, budget.SessionDailyCost, budget.DegradeRatio),
+	), turn
+}
+
+// debateBudget 单场辩论 / 讨论的预算
+func (s *Server) debateBudget(ctx context.Context, usage *config.UsageTracker) context.Context {
+	budget := s.settings.Load().budget
+	return config.WithBudget(ctx,
+		config.NewBudget(config.BudgetScopeDebate, usage, budget.DebateTokens, budget.DebateCost, budget.DegradeRatio))
+}
+
+// sessionTrackers 会话的累计用量与当天用量，跨天时重新计数
+func (s *Server) sessionTrackers(id string) (total, daily *config.UsageTracker) {
+	s.sessionMutex.Lock()
+	defer s.sessionMutex.Unlock()
+
+	u, ok := s.sessionUsage[id]
+	if !ok {
+		u = &sessionUsage{total: config.NewUsageTracker(s.settings.Load().pricing)}
+		s.sessionUsage[id] = u
+	}
+	today := time.Now().Format("2006-01-02")
+	if u.daily == nil || u.day != today {
+		u.daily = config.NewUsageTracker(s.settings.Load().pricing)
+		u.day = today
+	}
+	return u.total, u.daily
+}
+
+// sessionUsageSummary 会话累计用量
+func (s *Server) sessionUsageSummary(id string) *config.UsageSummary {
+	total, _ := s.sessionTrackers(id)
+	return total.Summary()
+}
+
+// handleRequestError 处理因客户端断开、超时或预算用尽导致的失败，已处理时返回 true
+func handleRequestError(w http.ResponseWriter, err error) bool {
+	var budgetErr *config.BudgetExceededError
+	switch {
+	case errors.As(err, &budgetErr):
+		log.Warn().Err(err).Msg("Budget exceeded")
+		if budgetErr.Scope == config.BudgetScopeSession {
+			// 每日预算在次日零点恢复
+			now := time.Now()
+			midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+			w.Header().Set("Retry-After", strconv.Itoa(int(midnight.Sub(now).Seconds())+1))
+		}
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return true
+	case errors.Is(err, context.Canceled):
+		// 客户端已断开，无需再写响应
+		log.Info().Err(err).Msg("Client disconnected, request aborted")
+		return true
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Warn().Err(err).Msg("Request timed out")
+		http.Error(w, "Request timeout", http.StatusGatewayTimeout)
+		return true
+	}
+	return false
 }
 
 // RegisterRoutes 注册路由
 func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	// 一对一对话
 	mux.HandleFunc("/api/chat", s.handleChat)
+	mux.HandleFunc("/api/chat/stream", s.handleChatStream)
 
 	// Agent 对话（带工具、反思）
 	mux.HandleFunc("/api/agent/chat", s.handleAgentChat)
+	mux.HandleFunc("/api/agent/chat/stream", s.handleAgentChatStream)
+
+	// 会话管理
+	mux.HandleFunc("GET /api/sessions", s.handleSessionList)
+	mux.HandleFunc("GET /api/sessions/{id}", s.handleSessionGet)
+	mux.HandleFunc("PATCH /api/sessions/{id}", s.handleSessionUpdate)
+	mux.HandleFunc("DELETE /api/sessions/{id}", s.handleSessionDelete)
+	mux.HandleFunc("GET /api/sessions/{id}/messages", s.handleSessionMessages)
+	mux.HandleFunc("POST /api/sessions/{id}/fork", s.handleSessionFork)
+	mux.HandleFunc("POST /api/sessions/{id}/rewind", s.handleSessionRewind)
+	mux.HandleFunc("POST /api/sessions/{id}/regenerate", s.handleRegenerate)
+	mux.HandleFunc("POST /api/sessions/{id}/messages/{index}/edit", s.handleMessageEdit)
+	mux.HandleFunc("POST /api/sessions/{id}/messages/{index}/select", s.handleSelectBranch)
 
 	// 主持人驱动的讨论
 	mux.HandleFunc("/api/agent/discussion", s.handleAgentDiscussion)
@@ -98,12 +286,24 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	// 辩论模式
 	mux.HandleFunc("/api/debate/start", s.handleDebateStart)
 	mux.HandleFunc("/api/debate/status", s.handleDebateStatus)
+	mux.HandleFunc("/api/debate/events", s.handleDebateEvents)
+	mux.HandleFunc("GET /api/debate/formats", s.handleDebateFormats)
+	mux.HandleFunc("POST /api/debate/{id}/speak", s.handleDebateSpeak)
+	mux.HandleFunc("POST /api/debate/{id}/pause", s.handleDebatePause)
+	mux.HandleFunc("POST /api/debate/{id}/resume", s.handleDebateResume)
+	mux.HandleFunc("POST /api/debate/{id}/cancel", s.handleDebateCancel)
+	mux.HandleFunc("POST /api/debate/{id}/note", s.handleDebateNote)
 
 	// 哲学家列表
 	mux.HandleFunc("/api/philosophers", s.handlePhilosophers)
 
-	// 健康检查
+	// 健康检查与用量统计
 	mux.HandleFunc("/api/health", s.handleHealth)
+	mux.HandleFunc("/api/usage", s.handleUsage)
+	mux.HandleFunc("/api/cache", s.handleCacheStats)
+
+	// Prometheus 指标
+	mux.Handle("/metrics", metrics.Default.Handler())
 
 	// 静态文件服务
 	mux.HandleFunc("/", s.handleStatic)
@@ -122,6 +322,7 @@ type ChatRequest struct {
 	SessionID   string                      `json:"session_id"`
 	Message     string                      `json:"message"`
 	Philosopher philosopher.PhilosopherType `json:"philosopher"`
+	Route       string                      `json:"route,omitempty"` // 模型路由：light / heavy / auto（默认）
 }
 
 // ChatResponse 对话响应
@@ -129,7 +330,10 @@ type ChatResponse struct {
 	Response     string                   `json:"response"`
 	Philosopher  string                   `json:"philosopher"`
 	EmotionLevel philosopher.EmotionLevel `json:"emotion_level"`
-	CriticalHit  bool                     `json:"critical_hit"` // 是否触发毒舌标签
+	CriticalHit  bool                     `json:"critical_hit"`            // 是否触发毒舌标签
+	Regenerated  bool                     `json:"regenerated,omitempty"`   // 初次回复与近期回复重复，已提高温度重新生成
+	Usage        *config.UsageSummary     `json:"usage,omitempty"`         // 本轮用量
+	SessionUsage *config.UsageSummary     `json:"session_usage,omitempty"` // 会话累计用量
 }
 
 func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
@@ -144,40 +348,51 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	route, err := config.ParseRoutePolicy(req.Route)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.requestContext(r)
+	defer cancel()
+	ctx = config.WithRoute(ctx, route)
+
 	// 获取或创建会话
-	session := s.getOrCreateSession(req.SessionID, req.Philosopher)
+	session, history, err := s.loadSession(req.SessionID, req.Philosopher)
+	if err != nil {
+		log.Error().Err(err).Str("session", req.SessionID).Msg("读取会话失败")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	ctx, turnUsage := s.trackTurn(ctx, session)
+	if handleRequestError(w, config.CheckBudget(ctx)) {
+		return
+	}
 
 	// 分析情绪
-	emotionLevel := s.emotionAnalyzer.Analyze(req.Message)
+	emotionLevel := s.emotionAnalyzer.Analyze(ctx, req.Message)
 
-	// 添加用户消息
-	session.Messages = append(session.Messages, config.Message{
-		Role:    "user",
-		Content: req.Message,
-	})
+	// 添加用户消息（回复成功后才写入会话）
+	userMsg := config.Message{Role: "user", Content: req.Message}
+	history = append(history, userMsg)
 
 	// 创建哲学家并获取响应
 	p := philosopher.NewPhilosopher(req.Philosopher, s.model)
-	response, err := p.Chat(session.Messages, emotionLevel)
+	response, regenerated, err := s.reply(ctx, p, history, emotionLevel)
+	if handleRequestError(w, err) {
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Chat failed")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// 检查是否重复，如果重复则提高 temperature 重新生成
-	if s.deduplicator.IsDuplicate(response) {
-		// 这里可以实现重新生成逻辑
-		log.Warn().Msg("Detected duplicate response")
+	// 保存本轮对话
+	if err := s.sessions.Append(session.ID, userMsg, config.Message{Role: "assistant", Content: response}); err != nil {
+		log.Error().Err(err).Str("session", session.ID).Msg("保存会话失败")
 	}
-	s.deduplicator.AddResponse(response)
-
-	// 添加助手消息
-	session.Messages = append(session.Messages, config.Message{
-		Role:    "assistant",
-		Content: response,
-	})
-	session.LastActivity = time.Now()
 
 	// 检查是否有毒舌标签
 	criticalHit := containsCriticalHit(response)
@@ -187,12 +402,39 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 		Philosopher:  p.Name,
 		EmotionLevel: emotionLevel,
 		CriticalHit:  criticalHit,
+		Regenerated:  regenerated,
+		Usage:        turnUsage.Summary(),
+		SessionUsage: s.sessionUsageSummary(session.ID),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// reply 获取角色回复；与近期回复重复时提高温度重新生成一次
+func (s *Server) reply(ctx context.Context, p *philosopher.Philosopher, history []config.Message, emotionLevel philosopher.EmotionLevel) (response string, regenerated bool, err error) {
+	response, err = p.Chat(ctx, history, emotionLevel)
+	if err != nil {
+		return "", false, err
+	}
+
+	if s.deduplicator.IsDuplicate(response) {
+		log.Warn().Msg("Detected duplicate response, regenerating")
+		alt, err := p.Chat(config.WithTemperature(ctx, config.RegenerateTemperature(s.settings.Load().temperature)), history, emotionLevel)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return "", false, err
+		case err != nil:
+			// 重新生成失败时仍返回初次的回复
+			log.Warn().Err(err).Msg("Regenerate failed, keeping duplicate response")
+		default:
+			response, regenerated = alt, true
+		}
+	}
+	s.deduplicator.AddResponse(response)
+	return response, regenerated, nil
+}
+
 func containsCriticalHit(response string) bool {
 	tags := []string{
 		"[致命追问!]", "[权力意志!]", "[绝对命令!]",
@@ -215,22 +457,44 @@ func containsSubstring(s, substr string) bool {
 	return false
 }
 
-func (s *Server) getOrCreateSession(id string, pType philosopher.PhilosopherType) *Session {
-	s.sessionMutex.Lock()
-	defer s.sessionMutex.Unlock()
-
-	if session, ok := s.sessions[id]; ok {
-		return session
+// loadSession 获取或创建会话，并读出历史消息
+func (s *Server) loadSession(id string, pType philosopher.PhilosopherType) (*Session, []config.Message, error) {
+	session, err := s.sessions.GetOrCreate(id, pType)
+	if err != nil {
+		return nil, nil, err
+	}
+	history, err := s.sessions.Messages(id)
+	if err != nil {
+		return nil, nil, err
 	}
+	return session, history, nil
+}
+
+// evictIdleSessions 定期清除空闲会话（sessions.idle_ttl_minutes）
+func (s *Server) evictIdleSessions(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	session := &Session{
-		ID:           id,
-		Messages:     []config.Message{},
-		Philosopher:  pType,
-		LastActivity: time.Now(),
+	for range ticker.C {
+		ttl := s.settings.Load().sessionTTL
+		if ttl <= 0 {
+			continue
+		}
+		ids, err := s.sessions.EvictIdle(time.Now().Add(-ttl))
+		if err != nil {
+			log.Error().Err(err).Msg("清除空闲会话失败")
+			continue
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		s.sessionMutex.Lock()
+		for _, id := range ids {
+			delete(s.sessionUsage, id)
+		}
+		s.sessionMutex.Unlock()
+		log.Info().Int("count", len(ids)).Msg("已清除空闲会话")
 	}
-	s.sessions[id] = session
-	return session
 }
 
 // ==================== 辩论模式 ====================
@@ -243,17 +507,26 @@ type DebateStartRequest struct {
 	ProPhilosophers []philosopher.PhilosopherType          `json:"pro_philosophers"`
 	ConPhilosophers []philosopher.PhilosopherType          `json:"con_philosophers"`
 	ForcedStances   map[philosopher.PhilosopherType]string `json:"forced_stances,omitempty"`
-	Async           bool                                   `json:"async,omitempty"` // 是否异步执行
+	Format          string                                 `json:"format,omitempty"`      // 赛制名称（见 /api/debate/formats），默认 standard
+	FreeDebate      philosopher.FreeDebateConfig           `json:"free_debate,omitempty"` // 自由辩论：轮数、token 上限、发言人选择策略
+	Judges          int                                    `json:"judges,omitempty"`      // 评委人数（0~3），0 表示不评判
+	Human           *philosopher.HumanConfig               `json:"human,omitempty"`       // 人类参与者（需异步模式），通过 /api/debate/{id}/speak 发言
+	Async           bool                                   `json:"async,omitempty"`       // 是否异步执行
+	Route           string                                 `json:"route,omitempty"`       // 模型路由：light / heavy / auto（默认）
 }
 
 // DebateResponse 辩论响应
 type DebateResponse struct {
-	ID           string                     `json:"id,omitempty"`
-	Status       DebateStatus               `json:"status"`
-	Topic        string                     `json:"topic,omitempty"`
-	CurrentPhase philosopher.DebatePhase    `json:"current_phase,omitempty"`
-	Records      []philosopher.DebateRecord `json:"records,omitempty"`
-	Error        string                     `json:"error,omitempty"`
+	ID            string                     `json:"id,omitempty"`
+	Status        DebateStatus               `json:"status"`
+	Topic         string                     `json:"topic,omitempty"`
+	Format        string                     `json:"format,omitempty"`
+	CurrentPhase  philosopher.DebatePhase    `json:"current_phase,omitempty"`
+	Records       []philosopher.DebateRecord `json:"records,omitempty"`
+	Judgement     *philosopher.Judgement     `json:"judgement,omitempty"`      // 评委的评分与胜负判定
+	AwaitingHuman *philosopher.HumanTurn     `json:"awaiting_human,omitempty"` // 正在等待人类发言的回合
+	Usage         *config.UsageSummary       `json:"usage,omitempty"`          // token 用量与费用（按发言者拆分）
+	Error         string                     `json:"error,omitempty"`
 }
 
 func (s *Server) handleDebateStart(w http.ResponseWriter, r *http.Request) {
@@ -268,6 +541,39 @@ func (s *Server) handleDebateStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	route, err := config.ParseRoutePolicy(req.Route)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := req.FreeDebate.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Judges < 0 || req.Judges > philosopher.MaxJudges {
+		http.Error(w, fmt.Sprintf("judges must be between 0 and %d", philosopher.MaxJudges), http.StatusBadRequest)
+		return
+	}
+
+	if req.Human != nil {
+		if err := req.Human.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !req.Async {
+			http.Error(w, "human speaker requires async mode", http.StatusBadRequest)
+			return
+		}
+	}
+
+	format, err := philosopher.GetDebateFormat(req.Format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// 创建辩论配置
 	debateConfig := &philosopher.DebateConfig{
 		Topic:           req.Topic,
@@ -276,6 +582,15 @@ func (s *Server) handleDebateStart(w http.ResponseWriter, r *http.Request) {
 		ProPhilosophers: req.ProPhilosophers,
 		ConPhilosophers: req.ConPhilosophers,
 		ForcedStances:   req.ForcedStances,
+		Format:          format,
+		FreeDebate:      req.FreeDebate,
+		Judges:          req.Judges,
+		Human:           req.Human,
+	}
+	pro, con := debateConfig.Sides()
+	if err := format.CheckSides(len(pro), len(con)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	// 生成辩论 ID
@@ -288,9 +603,25 @@ func (s *Server) handleDebateStart(w http.ResponseWriter, r *http.Request) {
 			ID:           debateID,
 			Status:       DebateStatusPending,
 			Topic:        req.Topic,
-			CurrentPhase: philosopher.PhaseOpening,
+			Format:       format.Name,
+			CurrentPhase: format.Phases[0].Phase,
 			Records:      []philosopher.DebateRecord{},
 			StartTime:    time.Now(),
+			usage:        config.NewUsageTracker(s.settings.Load().pricing),
+			control:      philosopher.NewDebateControl(),
+		}
+		debateConfig.Control = session.control
+
+		if req.Human != nil {
+			session.human = newHumanSeat(func(turn *philosopher.HumanTurn) {
+				s.debateMutex.Lock()
+				defer s.debateMutex.Unlock()
+				session.AwaitingHuman = turn
+				if turn != nil {
+					session.publish(StreamEvent{Type: EventHumanTurn, Data: turn})
+				}
+			})
+			debateConfig.Human.Input = session.human
 		}
 
 		s.debateMutex.Lock()
@@ -298,26 +629,38 @@ func (s *Server) handleDebateStart(w http.ResponseWriter, r *http.Request) {
 		s.debateMutex.Unlock()
 
 		// 异步执行辩论
-		go s.runDebateAsync(debateID, debateConfig)
+		go s.runDebateAsync(debateID, debateConfig, route)
 
 		// 立即返回辩论 ID
 		resp := DebateResponse{
 			ID:     debateID,
 			Status: DebateStatusPending,
 			Topic:  req.Topic,
+			Format: format.Name,
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
 		return
 	}
 
-	// 同步模式（原有逻辑）
+	// 同步模式（原有逻辑）：客户端断开后不再继续发言
+	ctx, cancel := s.requestContext(r)
+	defer cancel()
+	ctx = config.WithRoute(ctx, route)
+	ctx, usage := s.trackUsage(ctx)
+	ctx = s.debateBudget(ctx, usage)
+
 	engine := philosopher.NewDebateEngine(debateConfig, s.model)
-	result, err := engine.Run()
+	result, err := engine.Run(ctx)
+	if handleRequestError(w, err) {
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Debate failed")
 		resp := DebateResponse{
 			Status: DebateStatusFailed,
+			Format: format.Name,
+			Usage:  usage.Summary(),
 			Error:  err.Error(),
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -326,8 +669,12 @@ func (s *Server) handleDebateStart(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := DebateResponse{
-		Status:  DebateStatusCompleted,
-		Records: result.Records,
+		Status:    DebateStatusCompleted,
+		Topic:     req.Topic,
+		Format:    format.Name,
+		Records:   result.Records,
+		Judgement: result.Judgement,
+		Usage:     usage.Summary(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -335,43 +682,66 @@ func (s *Server) handleDebateStart(w http.ResponseWriter, r *http.Request) {
 }
 
 // runDebateAsync 异步执行辩论
-func (s *Server) runDebateAsync(debateID string, config *philosopher.DebateConfig) {
-	// 更新状态为运行中
+func (s *Server) runDebateAsync(debateID string, debateConfig *philosopher.DebateConfig, route config.RoutePolicy) {
+	// 更新状态为运行中（开始前已被暂停时保持暂停）
 	s.debateMutex.Lock()
 	session := s.debates[debateID]
-	session.Status = DebateStatusRunning
+	if session.Status == DebateStatusPending {
+		session.setStatus(DebateStatusRunning)
+	}
 	s.debateMutex.Unlock()
 
 	// 创建辩论引擎
-	engine := philosopher.NewDebateEngine(config, s.model)
+	engine := philosopher.NewDebateEngine(debateConfig, s.model)
 
-	// 设置发言回调，实时更新记录
-	engine.SetOnSpeech(func(speaker string, content string, phase philosopher.DebatePhase) {
+	// 设置阶段回调，推送阶段切换事件
+	engine.SetOnPhase(func(phase philosopher.DebatePhase) {
 		s.debateMutex.Lock()
 		session.CurrentPhase = phase
-		session.Records = append(session.Records, philosopher.DebateRecord{
+		session.publish(StreamEvent{Type: EventPhase, Data: PhaseEvent{Phase: phase}})
+		s.debateMutex.Unlock()
+	})
+
+	// 设置发言回调，实时更新记录
+	engine.SetOnSpeech(func(speaker string, content string, phase philosopher.DebatePhase) {
+		record := philosopher.DebateRecord{
 			SpeakerName: speaker,
 			Content:     content,
 			Phase:       phase,
-		})
+		}
+		s.debateMutex.Lock()
+		session.CurrentPhase = phase
+		session.Records = append(session.Records, record)
+		session.publish(StreamEvent{Type: EventSpeech, Data: record})
 		s.debateMutex.Unlock()
 	})
 
-	// 运行辩论
-	result, err := engine.Run()
+	// 运行辩论（异步辩论不随发起请求结束而取消）
+	ctx := config.WithUsageTracker(config.WithRoute(context.Background(), route), session.usage, s.usage)
+	result, err := engine.Run(s.debateBudget(ctx, session.usage))
 
 	// 更新最终状态
 	s.debateMutex.Lock()
 	now := time.Now()
 	session.EndTime = &now
-	if err != nil {
+	switch {
+	case errors.Is(err, philosopher.ErrDebateCancelled):
+		// 已有的发言记录保留在会话中
+		session.Status = DebateStatusCancelled
+		log.Info().Str("debate_id", debateID).Msg("Async debate cancelled")
+		session.publish(StreamEvent{Type: EventDone, Data: session.response()})
+	case err != nil:
 		session.Status = DebateStatusFailed
 		session.Error = err.Error()
 		log.Error().Err(err).Str("debate_id", debateID).Msg("Async debate failed")
-	} else {
+		session.publish(StreamEvent{Type: EventError, Data: ErrorEvent{Error: err.Error()}})
+	default:
 		session.Status = DebateStatusCompleted
 		session.Records = result.Records
+		session.Judgement = result.Judgement
+		session.publish(StreamEvent{Type: EventDone, Data: session.response()})
 	}
+	session.closeSubscribers()
 	s.debateMutex.Unlock()
 }
 
@@ -391,6 +761,10 @@ func (s *Server) handleDebateStatus(w http.ResponseWriter, r *http.Request) {
 	// 查找辩论会话
 	s.debateMutex.RLock()
 	session, ok := s.debates[debateID]
+	var resp DebateResponse
+	if ok {
+		resp = session.response()
+	}
 	s.debateMutex.RUnlock()
 
 	if !ok {
@@ -398,19 +772,15 @@ func (s *Server) handleDebateStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp := DebateResponse{
-		ID:           session.ID,
-		Status:       session.Status,
-		Topic:        session.Topic,
-		CurrentPhase: session.CurrentPhase,
-		Records:      session.Records,
-		Error:        session.Error,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// handleDebateFormats 列出可用的赛制
+func (s *Server) handleDebateFormats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, philosopher.DebateFormats())
+}
+
 // generateDebateID 生成辩论 ID
 func generateDebateID() string {
 	return time.Now().Format("20060102150405") + "-" + randomString(6)
@@ -463,6 +833,7 @@ type AgentChatRequest struct {
 	Philosopher      philosopher.PhilosopherType `json:"philosopher"`
 	EnableTools      bool                        `json:"enable_tools"`
 	EnableReflection bool                        `json:"enable_reflection"`
+	Route            string                      `json:"route,omitempty"` // 模型路由：light / heavy / auto（默认）
 }
 
 // AgentChatResponse Agent 对话响应
@@ -474,6 +845,8 @@ type AgentChatResponse struct {
 	ReActSteps       []react.Step                  `json:"react_steps,omitempty"`
 	ReflectionResult *philosopher.ReflectionResult `json:"reflection_result,omitempty"`
 	AgentEnabled     bool                          `json:"agent_enabled"`
+	Usage            *config.UsageSummary          `json:"usage,omitempty"`         // 本轮用量（按子系统拆分）
+	SessionUsage     *config.UsageSummary          `json:"session_usage,omitempty"` // 会话累计用量
 }
 
 func (s *Server) handleAgentChat(w http.ResponseWriter, r *http.Request) {
@@ -488,27 +861,41 @@ func (s *Server) handleAgentChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 创建 Agent 配置
-	agentConfig := &philosopher.AgentConfig{
-		EnableTools:      req.EnableTools,
-		EnableReflection: req.EnableReflection,
-		EnableRefinement: false,
-		MaxToolCalls:     3,
+	route, err := config.ParseRoutePolicy(req.Route)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	// 创建 Agent
-	agent, err := philosopher.NewAgent(req.Philosopher, s.model, agentConfig)
+	agent, err := philosopher.NewAgent(req.Philosopher, s.model, s.agentConfig(&req))
 	if err != nil {
 		log.Error().Err(err).Msg("创建 Agent 失败")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	ctx, cancel := s.requestContext(r)
+	defer cancel()
+	ctx = config.WithRoute(ctx, route)
+
 	// 获取历史消息
-	session := s.getOrCreateSession(req.SessionID, req.Philosopher)
+	session, history, err := s.loadSession(req.SessionID, req.Philosopher)
+	if err != nil {
+		log.Error().Err(err).Str("session", req.SessionID).Msg("读取会话失败")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	ctx, turnUsage := s.trackTurn(ctx, session)
+	if handleRequestError(w, config.CheckBudget(ctx)) {
+		return
+	}
 
 	// 调用 Agent
-	result, err := agent.Chat(req.Message, session.Messages)
+	result, err := agent.Chat(ctx, req.Message, history)
+	if handleRequestError(w, err) {
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Agent chat failed")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -516,15 +903,12 @@ func (s *Server) handleAgentChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 更新会话
-	session.Messages = append(session.Messages, config.Message{
-		Role:    "user",
-		Content: req.Message,
-	})
-	session.Messages = append(session.Messages, config.Message{
-		Role:    "assistant",
-		Content: result.Content,
-	})
-	session.LastActivity = time.Now()
+	if err := s.sessions.Append(session.ID,
+		config.Message{Role: "user", Content: req.Message},
+		config.Message{Role: "assistant", Content: result.Content},
+	); err != nil {
+		log.Error().Err(err).Str("session", session.ID).Msg("保存会话失败")
+	}
 
 	resp := AgentChatResponse{
 		Response:         result.Content,
@@ -534,12 +918,25 @@ func (s *Server) handleAgentChat(w http.ResponseWriter, r *http.Request) {
 		ReActSteps:       result.ReActSteps,
 		ReflectionResult: result.ReflectionResult,
 		AgentEnabled:     true,
+		Usage:            turnUsage.Summary(),
+		SessionUsage:     s.sessionUsageSummary(session.ID),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// agentConfig 根据请求和服务器配置创建 Agent 配置
+func (s *Server) agentConfig(req *AgentChatRequest) *philosopher.AgentConfig {
+	return &philosopher.AgentConfig{
+		EnableTools:      req.EnableTools,
+		EnableReflection: req.EnableReflection,
+		EnableRefinement: false,
+		MaxToolCalls:     3,
+		DisableAIEmotion: !s.settings.Load().aiEmotion,
+	}
+}
+
 // ==================== 主持人驱动讨论 ====================
 
 // AgentDiscussionRequest 主持人讨论请求
@@ -547,6 +944,7 @@ type AgentDiscussionRequest struct {
 	Topic        string                        `json:"topic"`
 	Participants []philosopher.PhilosopherType `json:"participants"`
 	MaxRounds    int                           `json:"max_rounds"`
+	Route        string                        `json:"route,omitempty"` // 模型路由：light / heavy / auto（默认）
 }
 
 // AgentDiscussionResponse 主持人讨论响应
@@ -555,6 +953,7 @@ type AgentDiscussionResponse struct {
 	Topic     string                     `json:"topic"`
 	Records   []philosopher.DebateRecord `json:"records"`
 	Decisions []ModeratorDecisionInfo    `json:"decisions,omitempty"`
+	Usage     *config.UsageSummary       `json:"usage,omitempty"`
 	Error     string                     `json:"error,omitempty"`
 }
 
@@ -577,6 +976,12 @@ func (s *Server) handleAgentDiscussion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	route, err := config.ParseRoutePolicy(req.Route)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// 默认参与者
 	if len(req.Participants) == 0 {
 		req.Participants = []philosopher.PhilosopherType{
@@ -611,11 +1016,21 @@ func (s *Server) handleAgentDiscussion(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// 运行讨论
-	result, err := moderator.RunAutonomous(nil)
+	ctx, cancel := s.requestContext(r)
+	defer cancel()
+	ctx = config.WithRoute(ctx, route)
+	ctx, usage := s.trackUsage(ctx)
+	ctx = s.debateBudget(ctx, usage)
+
+	result, err := moderator.RunAutonomous(ctx, nil)
+	if handleRequestError(w, err) {
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Agent discussion failed")
 		resp := AgentDiscussionResponse{
 			Status: "failed",
+			Usage:  usage.Summary(),
 			Error:  err.Error(),
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -628,6 +1043,7 @@ func (s *Server) handleAgentDiscussion(w http.ResponseWriter, r *http.Request) {
 		Topic:     req.Topic,
 		Records:   result.Records,
 		Decisions: decisions,
+		Usage:     usage.Summary(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -637,20 +1053,59 @@ func (s *Server) handleAgentDiscussion(w http.ResponseWriter, r *http.Request) {
 // ==================== 健康检查 ====================
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
+	resp := map[string]interface{}{
 		"status":  "healthy",
 		"service": "mygo-chat",
-	})
+	}
+	// 多 API 源时附带各源的熔断状态与延迟
+	if reporter, ok := s.model.(config.StatsReporter); ok {
+		resp["sources"] = reporter.GetStats()["sources"]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleUsage 服务器启动以来的 token 用量与费用
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.usage.Summary())
+}
+
+// handleCacheStats 响应缓存的命中统计
+func (s *Server) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.cache.Stats())
 }
 
 // Start 启动服务器
 func (s *Server) Start(addr string) error {
+	if s.sessions == nil {
+		store, err := NewSQLiteSessionStore(":memory:")
+		if err != nil {
+			return err
+		}
+		s.sessions = store
+	}
+	go s.evictIdleSessions(time.Minute)
+
 	mux := http.NewServeMux()
 	s.RegisterRoutes(mux)
 
 	// 添加 CORS 中间件
-	handler := corsMiddleware(mux)
+	var handler http.Handler = mux
+	if s.settings.Load().corsEnabled {
+		handler = corsMiddleware(mux)
+	}
+	handler = metricsMiddleware(handler)
 
 	log.Info().Str("addr", addr).Msg("Starting API server")
 	return http.ListenAndServe(addr, handler)
@@ -660,7 +1115,7 @@ func (s *Server) Start(addr string) error {
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == http.MethodOptions {
@@ -671,3 +1126,51 @@ func corsMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// metricsMiddleware 按路由统计请求数、状态码与耗时
+// 路由取 ServeMux 匹配到的模式（如 /api/chat），避免把任意路径变成标签
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.Inc(route, r.Method, strconv.Itoa(rec.status))
+		metrics.HTTPDuration.Observe(time.Since(start).Seconds(), route)
+	})
+}
+
+// statusRecorder 记录响应状态码，并保留 Flusher 以免影响 SSE
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问底层连接
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
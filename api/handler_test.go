@@ -0,0 +1,339 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agent/config/replaytest"
+	"agent/philosopher"
+)
+
+// newTestServer 用回放模型和内存会话库启动一个完整的 HTTP 服务
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	store, err := NewSQLiteSessionStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(replaytest.Model(t, filepath.Join("testdata", "cassettes")))
+	s.SetSessionStore(store)
+
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		ts.Close()
+		store.Close()
+	})
+	return ts
+}
+
+func doRequest(t *testing.T, method, url, body string) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+func decode[T any](t *testing.T, data []byte) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	return v
+}
+
+// speech 记录里需要比对的部分
+type speech struct {
+	speaker philosopher.PhilosopherType
+	content string
+}
+
+func checkSpeeches(t *testing.T, got []philosopher.DebateRecord, want []speech) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Speaker != w.speaker || got[i].Content != w.content {
+			t.Errorf("records[%d] = %s %q, want %s %q", i, got[i].Speaker, got[i].Content, w.speaker, w.content)
+		}
+	}
+}
+
+const (
+	replyOpening  = "乐队，是大家一起才能发出的声音。"
+	replyQuestion = "你真的觉得，技术不重要吗？"
+	replyAnswer   = "重要。但是，先要有想传达的心情。"
+	replyFree     = "我……还是想和大家一起。"
+	replyClosing  = "所以，我们要一起，继续走下去。"
+)
+
+func TestHandlers(t *testing.T) {
+	const (
+		tomori = philosopher.TakamatsuTomori
+		soyo   = philosopher.NagasakiSoyo
+	)
+	standardDebate := `{"topic":"乐队更需要技术还是感情","pro_stance":"感情","con_stance":"技术","pro_philosophers":["tomori"],"con_philosophers":["soyo"]}`
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		check      func(t *testing.T, body []byte)
+	}{
+		{
+			name:       "chat",
+			method:     http.MethodPost,
+			path:       "/api/chat",
+			body:       `{"session_id":"s1","message":"今天练习好累","philosopher":"tomori"}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				resp := decode[ChatResponse](t, body)
+				if resp.Response != "……嗯。要不要，先休息一下？" {
+					t.Errorf("response = %q", resp.Response)
+				}
+				if resp.EmotionLevel != philosopher.EmotionComplaining {
+					t.Errorf("emotion_level = %q, want %q", resp.EmotionLevel, philosopher.EmotionComplaining)
+				}
+				// 情绪分析一次，回复一次
+				if resp.Usage == nil || resp.Usage.Calls != 2 {
+					t.Errorf("usage = %+v, want 2 calls", resp.Usage)
+				}
+			},
+		},
+		{
+			name:       "chat rejects bad route",
+			method:     http.MethodPost,
+			path:       "/api/chat",
+			body:       `{"session_id":"s1","message":"你好","philosopher":"tomori","route":"fastest"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "sync debate",
+			method:     http.MethodPost,
+			path:       "/api/debate/start",
+			body:       standardDebate,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				resp := decode[DebateResponse](t, body)
+				if resp.Status != DebateStatusCompleted || resp.Format != "standard" {
+					t.Fatalf("status = %q format = %q, error %q", resp.Status, resp.Format, resp.Error)
+				}
+				checkSpeeches(t, resp.Records, []speech{
+					{tomori, replyOpening}, {soyo, replyOpening},
+					{tomori, replyQuestion}, {soyo, replyAnswer},
+					{soyo, replyQuestion}, {tomori, replyAnswer},
+					{tomori, replyFree}, {soyo, replyFree}, {tomori, replyFree}, {soyo, replyFree},
+					{soyo, replyClosing}, {tomori, replyClosing},
+				})
+				if resp.Usage == nil || resp.Usage.Calls != len(resp.Records) {
+					t.Errorf("usage = %+v, want %d calls", resp.Usage, len(resp.Records))
+				}
+			},
+		},
+		{
+			name:       "debate rejects unknown format",
+			method:     http.MethodPost,
+			path:       "/api/debate/start",
+			body:       `{"topic":"t","pro_philosophers":["tomori"],"con_philosophers":["soyo"],"format":"nope"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "debate rejects too many judges",
+			method:     http.MethodPost,
+			path:       "/api/debate/start",
+			body:       `{"topic":"t","pro_philosophers":["tomori"],"con_philosophers":["soyo"],"judges":4}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "debate with human requires async",
+			method:     http.MethodPost,
+			path:       "/api/debate/start",
+			body:       `{"topic":"t","pro_philosophers":["tomori"],"human":{"side":"con"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "debate status requires id",
+			method:     http.MethodGet,
+			path:       "/api/debate/status",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "debate status of unknown debate",
+			method:     http.MethodGet,
+			path:       "/api/debate/status?id=missing",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "agent discussion",
+			method:     http.MethodPost,
+			path:       "/api/agent/discussion",
+			body:       `{"topic":"乐队更需要技术还是感情","participants":["tomori","soyo"],"max_rounds":6}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				resp := decode[AgentDiscussionResponse](t, body)
+				if resp.Status != "completed" {
+					t.Fatalf("status = %q, error %q", resp.Status, resp.Error)
+				}
+				checkSpeeches(t, resp.Records, []speech{
+					{tomori, replyOpening}, {soyo, replyOpening},
+					{soyo, replyQuestion}, {tomori, replyAnswer},
+					{tomori, replyClosing},
+				})
+				var actions []string
+				for _, d := range resp.Decisions {
+					actions = append(actions, d.Action)
+				}
+				want := []string{"opening_speech", "opening_speech", "ask_question", "request_answer", "request_summary", "end_discussion"}
+				if strings.Join(actions, ",") != strings.Join(want, ",") {
+					t.Errorf("decisions = %v, want %v", actions, want)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			status, body := doRequest(t, tt.method, ts.URL+tt.path, tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", status, tt.wantStatus, body)
+			}
+			if tt.check != nil {
+				tt.check(t, body)
+			}
+		})
+	}
+}
+
+// waitDebate 轮询辩论状态直到满足条件
+func waitDebate(t *testing.T, baseURL, id string, done func(DebateResponse) bool) DebateResponse {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, body := doRequest(t, http.MethodGet, baseURL+"/api/debate/status?id="+id, "")
+		if status != http.StatusOK {
+			t.Fatalf("status = %d: %s", status, body)
+		}
+		resp := decode[DebateResponse](t, body)
+		if done(resp) {
+			return resp
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("debate did not reach the expected state: %+v", resp)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAsyncDebateControl(t *testing.T) {
+	ts := newTestServer(t)
+	status, body := doRequest(t, http.MethodPost, ts.URL+"/api/debate/start",
+		`{"topic":"乐队更需要技术还是感情","pro_stance":"感情","con_stance":"技术","pro_philosophers":["tomori"],"format":"lincoln_douglas","human":{"side":"con","name":"小明"},"async":true}`)
+	if status != http.StatusOK {
+		t.Fatalf("start status = %d: %s", status, body)
+	}
+	id := decode[DebateResponse](t, body).ID
+
+	// 灯开篇后轮到人类
+	resp := waitDebate(t, ts.URL, id, func(r DebateResponse) bool { return r.AwaitingHuman != nil })
+	checkSpeeches(t, resp.Records, []speech{{"", replyOpening}})
+	if resp.Records[0].SpeakerName == "" {
+		t.Error("async record has no speaker name")
+	}
+
+	steps := []struct {
+		action     string
+		body       string
+		wantStatus int
+		wantState  DebateStatus
+	}{
+		{"pause", "", http.StatusOK, DebateStatusPaused},
+		{"pause", "", http.StatusConflict, ""},
+		{"speak", `{"content":"我想先说"}`, http.StatusConflict, ""},
+		{"note", `{"note":"请注意时间"}`, http.StatusAccepted, ""},
+		{"note", `{"note":"  "}`, http.StatusBadRequest, ""},
+		{"resume", "", http.StatusOK, DebateStatusRunning},
+		{"resume", "", http.StatusConflict, ""},
+		{"cancel", "", http.StatusAccepted, ""},
+	}
+	for _, step := range steps {
+		status, body := doRequest(t, http.MethodPost, ts.URL+"/api/debate/"+id+"/"+step.action, step.body)
+		if status != step.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", step.action, status, step.wantStatus, body)
+		}
+		if step.wantState != "" {
+			if got := decode[DebateResponse](t, body).Status; got != step.wantState {
+				t.Errorf("%s: debate status = %q, want %q", step.action, got, step.wantState)
+			}
+		}
+	}
+
+	resp = waitDebate(t, ts.URL, id, func(r DebateResponse) bool { return r.Status.finished() })
+	if resp.Status != DebateStatusCancelled {
+		t.Fatalf("final status = %q, want %q (error %q)", resp.Status, DebateStatusCancelled, resp.Error)
+	}
+
+	// 结束后不能再控制，也不能发言
+	if status, _ := doRequest(t, http.MethodPost, ts.URL+"/api/debate/"+id+"/pause", ""); status != http.StatusConflict {
+		t.Errorf("pause after cancel: status = %d, want %d", status, http.StatusConflict)
+	}
+	if status, _ := doRequest(t, http.MethodPost, ts.URL+"/api/debate/"+id+"/speak", `{"content":"还有话说"}`); status != http.StatusConflict {
+		t.Errorf("speak after cancel: status = %d, want %d", status, http.StatusConflict)
+	}
+}
+
+// TestAsyncDebateHumanDeadline 暂停期间停表：继续后 awaiting_human 的截止时间顺延暂停的时长
+func TestAsyncDebateHumanDeadline(t *testing.T) {
+	ts := newTestServer(t)
+	status, body := doRequest(t, http.MethodPost, ts.URL+"/api/debate/start",
+		`{"topic":"乐队更需要技术还是感情","pro_stance":"感情","con_stance":"技术","pro_philosophers":["tomori"],"format":"lincoln_douglas","human":{"side":"con","name":"小明"},"async":true}`)
+	if status != http.StatusOK {
+		t.Fatalf("start status = %d: %s", status, body)
+	}
+	id := decode[DebateResponse](t, body).ID
+	deadline := waitDebate(t, ts.URL, id, func(r DebateResponse) bool { return r.AwaitingHuman != nil }).AwaitingHuman.Deadline
+
+	const pause = 300 * time.Millisecond
+	for _, action := range []string{"pause", "resume"} {
+		if status, body := doRequest(t, http.MethodPost, ts.URL+"/api/debate/"+id+"/"+action, ""); status != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", action, status, body)
+		}
+		if action == "pause" {
+			time.Sleep(pause)
+		}
+	}
+	resp := waitDebate(t, ts.URL, id, func(r DebateResponse) bool {
+		return r.AwaitingHuman != nil && r.AwaitingHuman.Deadline.Sub(deadline) >= pause
+	})
+	if resp.Status != DebateStatusRunning {
+		t.Errorf("status = %q, want %q", resp.Status, DebateStatusRunning)
+	}
+
+	if status, body := doRequest(t, http.MethodPost, ts.URL+"/api/debate/"+id+"/cancel", ""); status != http.StatusAccepted {
+		t.Fatalf("cancel: status = %d: %s", status, body)
+	}
+	waitDebate(t, ts.URL, id, func(r DebateResponse) bool { return r.Status.finished() })
+}
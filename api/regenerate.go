@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"agent/config"
+	"agent/philosopher"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ==================== 重新生成与编辑 ====================
+// 新回复 / 编辑后的用户消息作为兄弟分支写入会话树，原来的内容保留，可通过 select 切换回去
+
+// RegenerateRequest 重新生成某条回复
+type RegenerateRequest struct {
+	MessageIndex *int     `json:"message_index,omitempty"` // 要重新生成的助手消息，缺省为最后一条
+	Temperature  *float64 `json:"temperature,omitempty"`   // 本次使用的温度（0~2），缺省使用配置的温度
+	Route        string   `json:"route,omitempty"`         // 模型路由：light / heavy / auto（默认）
+}
+
+// EditMessageRequest 编辑用户消息并从这里重新对话
+type EditMessageRequest struct {
+	Content     string   `json:"content"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Route       string   `json:"route,omitempty"`
+}
+
+// SelectBranchRequest 切换到某个兄弟分支
+type SelectBranchRequest struct {
+	Branch int `json:"branch"` // 分支序号（从 0 开始，见消息的 branch / branches）
+}
+
+// RegenerateResponse 新回复及其在会话树中的位置
+type RegenerateResponse struct {
+	ChatResponse
+	SessionID    string `json:"session_id"`
+	MessageIndex int    `json:"message_index"` // 新回复在当前分支中的位置
+	Branch       int    `json:"branch"`        // 新回复的分支序号
+	Branches     int    `json:"branches"`      // 该位置的分支数
+}
+
+// handleRegenerate 为某条回复生成一个新版本（兄弟分支），可指定更高的温度
+func (s *Server) handleRegenerate(w http.ResponseWriter, r *http.Request) {
+	var req RegenerateRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	session, messages, ok := s.sessionForRerun(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	index := len(messages) - 1
+	if req.MessageIndex != nil {
+		index = *req.MessageIndex
+	}
+	if index < 0 || index >= len(messages) || messages[index].Role != "assistant" {
+		http.Error(w, "message_index must point to an assistant message", http.StatusBadRequest)
+		return
+	}
+	history := messages[:index]
+	if lastUserMessage(history) < 0 {
+		http.Error(w, "No user message before message_index", http.StatusBadRequest)
+		return
+	}
+
+	s.rerun(w, r, session, history, index, nil, req.Temperature, req.Route)
+}
+
+// handleMessageEdit 编辑用户消息：编辑后的消息作为兄弟分支，并从这里重新获取回复
+func (s *Server) handleMessageEdit(w http.ResponseWriter, r *http.Request) {
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Content == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		http.Error(w, "Invalid message index", http.StatusBadRequest)
+		return
+	}
+
+	session, messages, ok := s.sessionForRerun(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	if index < 0 || index >= len(messages) || messages[index].Role != "user" {
+		http.Error(w, "message index must point to a user message", http.StatusBadRequest)
+		return
+	}
+
+	edited := config.Message{Role: "user", Content: req.Content}
+	history := append(messages[:index:index], edited)
+	s.rerun(w, r, session, history, index, []config.Message{edited}, req.Temperature, req.Route)
+}
+
+// handleSelectBranch 把某个位置切换为另一个分支
+func (s *Server) handleSelectBranch(w http.ResponseWriter, r *http.Request) {
+	var req SelectBranchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		http.Error(w, "Invalid message index", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	session, err := s.sessions.SelectBranch(id, index, req.Branch)
+	if err != nil {
+		writeSessionError(w, id, err)
+		return
+	}
+	writeJSON(w, session)
+}
+
+// sessionForRerun 读取会话与当前分支的消息，失败时写出错误响应
+func (s *Server) sessionForRerun(w http.ResponseWriter, id string) (*Session, []config.Message, bool) {
+	session, err := s.sessions.Get(id)
+	if err != nil {
+		writeSessionError(w, id, err)
+		return nil, nil, false
+	}
+	messages, err := s.sessions.Messages(id)
+	if err != nil {
+		writeSessionError(w, id, err)
+		return nil, nil, false
+	}
+	return session, messages, true
+}
+
+// rerun 基于 history 重新获取回复，把 prefix 与新回复作为第 index 条消息处的新分支写入会话
+func (s *Server) rerun(w http.ResponseWriter, r *http.Request, session *Session, history []config.Message, index int, prefix []config.Message, temperature *float64, routeName string) {
+	route, err := config.ParseRoutePolicy(routeName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if temperature != nil && (*temperature < 0 || *temperature > 2) {
+		http.Error(w, "temperature must be between 0 and 2", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.requestContext(r)
+	defer cancel()
+	ctx = config.WithRoute(ctx, route)
+	if temperature != nil {
+		ctx = config.WithTemperature(ctx, *temperature)
+	}
+	ctx, turnUsage := s.trackTurn(ctx, session)
+	if handleRequestError(w, config.CheckBudget(ctx)) {
+		return
+	}
+
+	emotionLevel := s.emotionAnalyzer.Analyze(ctx, history[lastUserMessage(history)].Content)
+
+	p := philosopher.NewPhilosopher(session.Philosopher, s.model)
+	response, regenerated, err := s.reply(ctx, p, history, emotionLevel)
+	if handleRequestError(w, err) {
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Regenerate failed")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	branch := append(prefix, config.Message{Role: "assistant", Content: response})
+	if err := s.sessions.Branch(session.ID, index, branch...); err != nil {
+		writeSessionError(w, session.ID, err)
+		return
+	}
+
+	resp := RegenerateResponse{
+		ChatResponse: ChatResponse{
+			Response:     response,
+			Philosopher:  p.Name,
+			EmotionLevel: emotionLevel,
+			CriticalHit:  containsCriticalHit(response),
+			Regenerated:  regenerated,
+			Usage:        turnUsage.Summary(),
+			SessionUsage: s.sessionUsageSummary(session.ID),
+		},
+		SessionID:    session.ID,
+		MessageIndex: index + len(prefix),
+	}
+	if page, _, err := s.sessions.MessagePage(session.ID, resp.MessageIndex, 1); err == nil && len(page) == 1 {
+		resp.Branch, resp.Branches = page[0].Branch, page[0].Branches
+	}
+	writeJSON(w, resp)
+}
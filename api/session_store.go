@@ -0,0 +1,736 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"agent/config"
+	"agent/philosopher"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ==================== 会话存储 ====================
+
+// ErrSessionNotFound 会话不存在（或已因空闲过期被清除）
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrSessionExists 会话 ID 已被占用
+var ErrSessionExists = errors.New("session already exists")
+
+// ErrMessageIndex 消息位置超出当前分支的范围
+var ErrMessageIndex = errors.New("message index out of range")
+
+// Session 用户会话（持久化的部分；消息单独存取）
+// 消息组织成一棵树：重新生成的回复、编辑后的用户消息作为兄弟分支保存，当前分支是从根到 head 的路径，
+// MessageCount 为当前分支的消息数
+type Session struct {
+	ID           string                      `json:"id"`
+	Philosopher  philosopher.PhilosopherType `json:"philosopher"`
+	Title        string                      `json:"title,omitempty"`
+	Metadata     map[string]string           `json:"metadata,omitempty"`
+	CreatedAt    time.Time                   `json:"created_at"`
+	LastActivity time.Time                   `json:"last_activity"`
+	MessageCount int                         `json:"message_count"`
+}
+
+// SessionMessage 当前分支上的一条消息及其位置
+type SessionMessage struct {
+	Index int `json:"index"` // 在当前分支中的位置
+	config.Message
+	Branch    int       `json:"branch"`   // 在兄弟分支中的序号（从 0 开始）
+	Branches  int       `json:"branches"` // 该位置的分支数（含自身）
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SessionPatch 会话的可修改字段，nil / 空表示不修改；Metadata 合并写入，值为空字符串的键被删除
+type SessionPatch struct {
+	Title    *string           `json:"title,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// SessionStore 会话存储接口
+type SessionStore interface {
+	// GetOrCreate 获取会话，不存在时以给定角色创建
+	GetOrCreate(id string, pType philosopher.PhilosopherType) (*Session, error)
+	// Get 获取会话，不存在时返回 ErrSessionNotFound
+	Get(id string) (*Session, error)
+	// List 按最后活动时间倒序分页列出会话，并返回会话总数
+	List(offset, limit int) ([]*Session, int, error)
+	// Update 修改标题与元数据
+	Update(id string, patch SessionPatch) (*Session, error)
+	// Messages 当前分支的全部消息（按顺序）
+	Messages(id string) ([]config.Message, error)
+	// MessagePage 分页读取当前分支的消息，并返回当前分支的消息数
+	MessagePage(id string, offset, limit int) ([]SessionMessage, int, error)
+	// Append 原子地在当前分支末尾追加消息并刷新最后活动时间；并发追加不会交错或丢失
+	Append(id string, messages ...config.Message) error
+	// Branch 在当前分支第 index 条消息处新开一个兄弟分支，写入 messages 并切换过去
+	Branch(id string, index int, messages ...config.Message) error
+	// SelectBranch 把第 index 条消息切换为第 branch 个兄弟分支（沿最新的后续消息走到底）
+	SelectBranch(id string, index, branch int) (*Session, error)
+	// Delete 删除会话及其消息
+	Delete(id string) error
+	// Fork 以当前分支的前 keep 条消息创建新会话 newID（沿用角色、标题与元数据）
+	Fork(id, newID string, keep int) (*Session, error)
+	// Rewind 当前分支只保留前 keep 条消息，其后的消息留作分支，之后的对话从这里分叉
+	Rewind(id string, keep int) (*Session, error)
+	// EvictIdle 删除最后活动早于 before 的会话，返回被删除的会话 ID
+	EvictIdle(before time.Time) ([]string, error)
+	// Count 会话总数
+	Count() (int, error)
+	Close() error
+}
+
+// SQLiteSessionStore SQLite 实现，时间统一按 UTC 存储以便比较
+// 只用一个连接：写操作天然串行，":memory:" 数据库也能在所有请求间共享
+type SQLiteSessionStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteSessionStore 打开（必要时创建）会话数据库，dbPath 为 ":memory:" 时只保存在内存中
+func NewSQLiteSessionStore(dbPath string) (*SQLiteSessionStore, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	store := &SQLiteSessionStore{db: db}
+	if err := store.initTables(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init session tables: %w", err)
+	}
+	return store, nil
+}
+
+// initTables 初始化数据库表
+func (s *SQLiteSessionStore) initTables() error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id TEXT PRIMARY KEY,
+			philosopher TEXT NOT NULL,
+			title TEXT NOT NULL DEFAULT '',
+			metadata TEXT,
+			created_at DATETIME NOT NULL,
+			last_activity DATETIME NOT NULL,
+			message_count INTEGER NOT NULL DEFAULT 0,
+			head INTEGER NOT NULL DEFAULT -1
+		)`,
+		`CREATE TABLE IF NOT EXISTS session_messages (
+			session_id TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			parent INTEGER NOT NULL DEFAULT -1,
+			role TEXT NOT NULL,
+			content TEXT NOT NULL,
+			tool_calls TEXT,
+			tool_call_id TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (session_id, seq)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_last_activity ON sessions(last_activity)`,
+	}
+
+	for _, query := range queries {
+		if _, err := s.db.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query %s: %w", query, err)
+		}
+	}
+	if err := s.migrateTree(); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_session_messages_parent ON session_messages(session_id, parent)`)
+	return err
+}
+
+// migrateTree 旧版数据库的消息是线性的：补上 parent / head 列，每条消息的父节点为前一条
+func (s *SQLiteSessionStore) migrateTree() error {
+	migrations := []struct {
+		table, column string
+		queries       []string
+	}{
+		{"session_messages", "parent", []string{
+			`ALTER TABLE session_messages ADD COLUMN parent INTEGER NOT NULL DEFAULT -1`,
+			`UPDATE session_messages SET parent = seq - 1`,
+		}},
+		{"sessions", "head", []string{
+			`ALTER TABLE sessions ADD COLUMN head INTEGER NOT NULL DEFAULT -1`,
+			`UPDATE sessions SET head = COALESCE(
+				(SELECT MAX(seq) FROM session_messages m WHERE m.session_id = sessions.id), -1)`,
+		}},
+	}
+
+	for _, m := range migrations {
+		exists, err := s.hasColumn(m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		for _, query := range m.queries {
+			if _, err := s.db.Exec(query); err != nil {
+				return fmt.Errorf("failed to migrate %s.%s: %w", m.table, m.column, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteSessionStore) hasColumn(table, column string) (bool, error) {
+	rows, err := s.db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// sqlQuerier *sql.DB 与 *sql.Tx 的公共方法
+type sqlQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// pathCTE 当前分支：从 head 沿 parent 走到根，depth 越大越靠前（?1 为会话 ID）
+const pathCTE = `WITH RECURSIVE path(seq, depth) AS (
+		SELECT head, 0 FROM sessions WHERE id = ?1 AND head >= 0
+		UNION ALL
+		SELECT m.parent, p.depth + 1 FROM path p
+		JOIN session_messages m ON m.session_id = ?1 AND m.seq = p.seq
+		WHERE m.parent >= 0
+	) `
+
+// activePath 当前分支上各消息的序号（按顺序）
+func activePath(q sqlQuerier, id string) ([]int, error) {
+	rows, err := q.Query(pathCTE+`SELECT seq FROM path ORDER BY depth DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var path []int
+	for rows.Next() {
+		var seq int
+		if err := rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+		path = append(path, seq)
+	}
+	return path, rows.Err()
+}
+
+// insertChain 以 parent 为起点依次写入消息（每条是前一条的子节点），返回最后一条的序号
+func insertChain(tx *sql.Tx, id string, parent int, messages []config.Message) (int, error) {
+	var next int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(seq) + 1, 0) FROM session_messages WHERE session_id = ?`, id).
+		Scan(&next); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	for _, msg := range messages {
+		var (
+			toolCalls []byte
+			err       error
+		)
+		if len(msg.ToolCalls) > 0 {
+			if toolCalls, err = json.Marshal(msg.ToolCalls); err != nil {
+				return 0, fmt.Errorf("failed to marshal tool calls: %w", err)
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO session_messages
+			(session_id, seq, parent, role, content, tool_calls, tool_call_id, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, next, parent, msg.Role, msg.Content, toolCalls, msg.ToolCallID, now); err != nil {
+			return 0, err
+		}
+		parent = next
+		next++
+	}
+	return parent, nil
+}
+
+// moveHead 切换当前分支并刷新消息数与活动时间
+func moveHead(tx *sql.Tx, id string, head, count int) error {
+	res, err := tx.Exec(`UPDATE sessions SET head = ?, message_count = ?, last_activity = ? WHERE id = ?`,
+		head, count, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// 会话在本轮对话进行中被清除或删除
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// GetOrCreate 获取或创建会话
+func (s *SQLiteSessionStore) GetOrCreate(id string, pType philosopher.PhilosopherType) (*Session, error) {
+	now := time.Now().UTC()
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO sessions (id, philosopher, created_at, last_activity)
+		VALUES (?, ?, ?, ?)`, id, string(pType), now, now)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// Get 获取会话
+func (s *SQLiteSessionStore) Get(id string) (*Session, error) {
+	row := s.db.QueryRow(`SELECT id, philosopher, title, metadata, created_at, last_activity, message_count
+		FROM sessions WHERE id = ?`, id)
+
+	var (
+		session  Session
+		pType    string
+		metadata sql.NullString
+	)
+	err := row.Scan(&session.ID, &pType, &session.Title, &metadata,
+		&session.CreatedAt, &session.LastActivity, &session.MessageCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.Philosopher = philosopher.PhilosopherType(pType)
+	if metadata.Valid && metadata.String != "" {
+		if err := json.Unmarshal([]byte(metadata.String), &session.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal session metadata: %w", err)
+		}
+	}
+	return &session, nil
+}
+
+// Messages 当前分支的全部消息
+func (s *SQLiteSessionStore) Messages(id string) ([]config.Message, error) {
+	rows, err := s.db.Query(pathCTE+`SELECT m.role, m.content, m.tool_calls, m.tool_call_id
+		FROM path p JOIN session_messages m ON m.session_id = ?1 AND m.seq = p.seq
+		ORDER BY p.depth DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []config.Message{}
+	for rows.Next() {
+		var (
+			msg       config.Message
+			toolCalls sql.NullString
+		)
+		if err := rows.Scan(&msg.Role, &msg.Content, &toolCalls, &msg.ToolCallID); err != nil {
+			return nil, err
+		}
+		if toolCalls.Valid && toolCalls.String != "" {
+			if err := json.Unmarshal([]byte(toolCalls.String), &msg.ToolCalls); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal tool calls: %w", err)
+			}
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// Append 在一个事务中把消息接到 head 之后，并更新会话的计数与活动时间
+func (s *SQLiteSessionStore) Append(id string, messages ...config.Message) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var head, count int
+	err = tx.QueryRow(`SELECT head, message_count FROM sessions WHERE id = ?`, id).Scan(&head, &count)
+	if errors.Is(err, sql.ErrNoRows) {
+		// 会话在本轮对话进行中被清除或删除
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if head, err = insertChain(tx, id, head, messages); err != nil {
+		return err
+	}
+	if err := moveHead(tx, id, head, count+len(messages)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Branch 以第 index 条消息的父节点为起点写入新分支
+func (s *SQLiteSessionStore) Branch(id string, index int, messages ...config.Message) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	path, err := activePath(tx, id)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(path) {
+		return ErrMessageIndex
+	}
+	parent := -1
+	if index > 0 {
+		parent = path[index-1]
+	}
+
+	head, err := insertChain(tx, id, parent, messages)
+	if err != nil {
+		return err
+	}
+	if err := moveHead(tx, id, head, index+len(messages)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SelectBranch 切换兄弟分支
+func (s *SQLiteSessionStore) SelectBranch(id string, index, branch int) (*Session, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	path, err := activePath(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(path) {
+		return nil, ErrMessageIndex
+	}
+	parent := -1
+	if index > 0 {
+		parent = path[index-1]
+	}
+
+	// 兄弟节点按创建顺序编号
+	var node int
+	err = tx.QueryRow(`SELECT seq FROM session_messages WHERE session_id = ? AND parent = ?
+		ORDER BY seq LIMIT 1 OFFSET ?`, id, parent, branch).Scan(&node)
+	if errors.Is(err, sql.ErrNoRows) || branch < 0 {
+		return nil, ErrMessageIndex
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 沿最新的子节点走到叶子
+	count := index + 1
+	for {
+		var child sql.NullInt64
+		if err := tx.QueryRow(`SELECT MAX(seq) FROM session_messages WHERE session_id = ? AND parent = ?`, id, node).
+			Scan(&child); err != nil {
+			return nil, err
+		}
+		if !child.Valid {
+			break
+		}
+		node = int(child.Int64)
+		count++
+	}
+
+	if err := moveHead(tx, id, node, count); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// List 分页列出会话
+func (s *SQLiteSessionStore) List(offset, limit int) ([]*Session, int, error) {
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(`SELECT id FROM sessions ORDER BY last_activity DESC, id LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	sessions := make([]*Session, 0, len(ids))
+	for _, id := range ids {
+		session, err := s.Get(id)
+		if errors.Is(err, ErrSessionNotFound) {
+			// 列出期间被删除
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, total, nil
+}
+
+// Update 修改标题与元数据
+func (s *SQLiteSessionStore) Update(id string, patch SessionPatch) (*Session, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var metadata sql.NullString
+	err = tx.QueryRow(`SELECT metadata FROM sessions WHERE id = ?`, id).Scan(&metadata)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.Title != nil {
+		if _, err := tx.Exec(`UPDATE sessions SET title = ? WHERE id = ?`, *patch.Title, id); err != nil {
+			return nil, err
+		}
+	}
+	if len(patch.Metadata) > 0 {
+		merged := map[string]string{}
+		if metadata.Valid && metadata.String != "" {
+			if err := json.Unmarshal([]byte(metadata.String), &merged); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal session metadata: %w", err)
+			}
+		}
+		for k, v := range patch.Metadata {
+			if v == "" {
+				delete(merged, k)
+			} else {
+				merged[k] = v
+			}
+		}
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal session metadata: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE sessions SET metadata = ? WHERE id = ?`, string(data), id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// MessagePage 分页读取当前分支的消息，附带每个位置的分支信息
+func (s *SQLiteSessionStore) MessagePage(id string, offset, limit int) ([]SessionMessage, int, error) {
+	var total int
+	if err := s.db.QueryRow(pathCTE+`SELECT COUNT(*) FROM path`, id).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(pathCTE+`SELECT m.role, m.content, m.tool_calls, m.tool_call_id, m.created_at,
+			(SELECT COUNT(*) FROM session_messages b WHERE b.session_id = ?1 AND b.parent = m.parent AND b.seq < m.seq),
+			(SELECT COUNT(*) FROM session_messages b WHERE b.session_id = ?1 AND b.parent = m.parent)
+		FROM path p JOIN session_messages m ON m.session_id = ?1 AND m.seq = p.seq
+		ORDER BY p.depth DESC LIMIT ?2 OFFSET ?3`, id, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	messages := []SessionMessage{}
+	for rows.Next() {
+		var (
+			msg       = SessionMessage{Index: offset + len(messages)}
+			toolCalls sql.NullString
+		)
+		if err := rows.Scan(&msg.Role, &msg.Content, &toolCalls, &msg.ToolCallID, &msg.CreatedAt,
+			&msg.Branch, &msg.Branches); err != nil {
+			return nil, 0, err
+		}
+		if toolCalls.Valid && toolCalls.String != "" {
+			if err := json.Unmarshal([]byte(toolCalls.String), &msg.ToolCalls); err != nil {
+				return nil, 0, fmt.Errorf("failed to unmarshal tool calls: %w", err)
+			}
+		}
+		messages = append(messages, msg)
+	}
+	return messages, total, rows.Err()
+}
+
+// Fork 把当前分支的前 keep 条消息复制到新会话（新会话只有这一条分支）
+func (s *SQLiteSessionStore) Fork(id, newID string, keep int) (*Session, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM sessions WHERE id = ?`, newID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists > 0 {
+		return nil, ErrSessionExists
+	}
+
+	now := time.Now().UTC()
+	res, err := tx.Exec(`INSERT INTO sessions (id, philosopher, title, metadata, created_at, last_activity)
+		SELECT ?, philosopher, title, metadata, ?, ? FROM sessions WHERE id = ?`, newID, now, now, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrSessionNotFound
+	}
+
+	path, err := activePath(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if keep < 0 || keep > len(path) {
+		return nil, ErrMessageIndex
+	}
+	for i, seq := range path[:keep] {
+		if _, err := tx.Exec(`INSERT INTO session_messages
+			(session_id, seq, parent, role, content, tool_calls, tool_call_id, created_at)
+			SELECT ?, ?, ?, role, content, tool_calls, tool_call_id, created_at
+			FROM session_messages WHERE session_id = ? AND seq = ?`, newID, i, i-1, id, seq); err != nil {
+			return nil, err
+		}
+	}
+	if err := moveHead(tx, newID, keep-1, keep); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(newID)
+}
+
+// Rewind 把 head 移回第 keep 条消息，其后的消息不删除，仍可通过 SelectBranch 切回
+func (s *SQLiteSessionStore) Rewind(id string, keep int) (*Session, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	path, err := activePath(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if keep < 0 || keep > len(path) {
+		return nil, ErrMessageIndex
+	}
+	head := -1
+	if keep > 0 {
+		head = path[keep-1]
+	}
+	if err := moveHead(tx, id, head, keep); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// Delete 删除会话及其消息
+func (s *SQLiteSessionStore) Delete(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM sessions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM session_messages WHERE session_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// EvictIdle 清除空闲会话
+func (s *SQLiteSessionStore) EvictIdle(before time.Time) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id FROM sessions WHERE last_activity < ?`, before.UTC())
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if _, err := tx.Exec(`DELETE FROM session_messages WHERE session_id = ?`, id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM sessions WHERE id = ?`, id); err != nil {
+			return nil, err
+		}
+	}
+	return ids, tx.Commit()
+}
+
+// Count 会话总数
+func (s *SQLiteSessionStore) Count() (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&n)
+	return n, err
+}
+
+// Close 关闭数据库
+func (s *SQLiteSessionStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"agent/config"
+	"agent/philosopher"
+)
+
+// msgs 按内容前缀生成消息：u 开头为用户消息，其余为助手消息
+func msgs(contents ...string) []config.Message {
+	var messages []config.Message
+	for _, content := range contents {
+		role := "assistant"
+		if strings.HasPrefix(content, "u") {
+			role = "user"
+		}
+		messages = append(messages, config.Message{Role: role, Content: content})
+	}
+	return messages
+}
+
+// sessionOp 对会话库的一次操作
+type sessionOp func(s *SQLiteSessionStore) error
+
+func fork(id, newID string, keep int) sessionOp {
+	return func(s *SQLiteSessionStore) error {
+		_, err := s.Fork(id, newID, keep)
+		return err
+	}
+}
+
+func rewind(id string, keep int) sessionOp {
+	return func(s *SQLiteSessionStore) error {
+		_, err := s.Rewind(id, keep)
+		return err
+	}
+}
+
+func branch(id string, index int, contents ...string) sessionOp {
+	return func(s *SQLiteSessionStore) error {
+		return s.Branch(id, index, msgs(contents...)...)
+	}
+}
+
+func selectBranch(id string, index, branch int) sessionOp {
+	return func(s *SQLiteSessionStore) error {
+		_, err := s.SelectBranch(id, index, branch)
+		return err
+	}
+}
+
+func appendTo(id string, contents ...string) sessionOp {
+	return func(s *SQLiteSessionStore) error {
+		return s.Append(id, msgs(contents...)...)
+	}
+}
+
+func TestSessionTree(t *testing.T) {
+	original := []string{"u1", "a1", "u2", "a2"}
+
+	tests := []struct {
+		name         string
+		ops          []sessionOp // 依次执行，只有最后一步允许出错
+		wantErr      error
+		want         map[string][]string // 各会话当前分支的消息内容
+		wantBranches []string            // 会话 s 当前分支每个位置的 "序号/分支数"
+	}{
+		{
+			name:         "fork keeps a prefix and leaves the original alone",
+			ops:          []sessionOp{fork("s", "f", 2)},
+			want:         map[string][]string{"s": original, "f": {"u1", "a1"}},
+			wantBranches: []string{"0/1", "0/1", "0/1", "0/1"},
+		},
+		{
+			name: "fork with keep 0 creates an empty session",
+			ops:  []sessionOp{fork("s", "f", 0)},
+			want: map[string][]string{"s": original, "f": {}},
+		},
+		{
+			name: "fork copies only the active branch",
+			ops:  []sessionOp{branch("s", 3, "a2'"), fork("s", "f", 4)},
+			want: map[string][]string{"s": {"u1", "a1", "u2", "a2'"}, "f": {"u1", "a1", "u2", "a2'"}},
+		},
+		{
+			name:    "fork into an existing session",
+			ops:     []sessionOp{fork("s", "f", 1), fork("s", "f", 2)},
+			wantErr: ErrSessionExists,
+			want:    map[string][]string{"f": {"u1"}},
+		},
+		{
+			name:    "fork from a missing session",
+			ops:     []sessionOp{fork("missing", "f", 0)},
+			wantErr: ErrSessionNotFound,
+		},
+		{
+			name:    "fork beyond the active branch",
+			ops:     []sessionOp{fork("s", "f", 5)},
+			wantErr: ErrMessageIndex,
+			want:    map[string][]string{"s": original},
+		},
+		{
+			name:         "rewind keeps the later messages as a branch",
+			ops:          []sessionOp{rewind("s", 2), appendTo("s", "u3", "a3")},
+			want:         map[string][]string{"s": {"u1", "a1", "u3", "a3"}},
+			wantBranches: []string{"0/1", "0/1", "1/2", "0/1"},
+		},
+		{
+			name: "rewind to 0 starts a new root",
+			ops:  []sessionOp{rewind("s", 0), appendTo("s", "u0")},
+			want: map[string][]string{"s": {"u0"}},
+			// 根消息也有兄弟分支
+			wantBranches: []string{"1/2"},
+		},
+		{
+			name:    "rewind beyond the active branch",
+			ops:     []sessionOp{rewind("s", 5)},
+			wantErr: ErrMessageIndex,
+			want:    map[string][]string{"s": original},
+		},
+		{
+			name:    "rewind a missing session",
+			ops:     []sessionOp{rewind("missing", 0)},
+			wantErr: ErrSessionNotFound,
+		},
+		{
+			name:         "branch switches to the new sibling",
+			ops:          []sessionOp{branch("s", 3, "a2'")},
+			want:         map[string][]string{"s": {"u1", "a1", "u2", "a2'"}},
+			wantBranches: []string{"0/1", "0/1", "0/1", "1/2"},
+		},
+		{
+			name:         "select branch switches back",
+			ops:          []sessionOp{branch("s", 3, "a2'"), selectBranch("s", 3, 0)},
+			want:         map[string][]string{"s": original},
+			wantBranches: []string{"0/1", "0/1", "0/1", "0/2"},
+		},
+		{
+			name:         "select branch follows the newest descendants",
+			ops:          []sessionOp{rewind("s", 2), appendTo("s", "u3", "a3"), selectBranch("s", 2, 0)},
+			want:         map[string][]string{"s": original},
+			wantBranches: []string{"0/1", "0/1", "0/2", "0/1"},
+		},
+		{
+			name: "select branch above a fork point picks the latest path",
+			ops: []sessionOp{
+				branch("s", 2, "u2'"), appendTo("s", "a2'"),
+				selectBranch("s", 2, 0), // 回到原分支
+				selectBranch("s", 1, 0), // a1 只有一个分支，沿最新的子节点 u2' 走到底
+			},
+			want: map[string][]string{"s": {"u1", "a1", "u2'", "a2'"}},
+		},
+		{
+			name: "select branch at the root",
+			ops:  []sessionOp{branch("s", 0, "u1'"), selectBranch("s", 0, 0)},
+			want: map[string][]string{"s": original},
+		},
+		{
+			name:    "select a missing sibling",
+			ops:     []sessionOp{branch("s", 3, "a2'"), selectBranch("s", 3, 2)},
+			wantErr: ErrMessageIndex,
+			want:    map[string][]string{"s": {"u1", "a1", "u2", "a2'"}},
+		},
+		{
+			name:    "select a negative sibling",
+			ops:     []sessionOp{selectBranch("s", 3, -1)},
+			wantErr: ErrMessageIndex,
+			want:    map[string][]string{"s": original},
+		},
+		{
+			name:    "select beyond the active branch",
+			ops:     []sessionOp{selectBranch("s", 4, 0)},
+			wantErr: ErrMessageIndex,
+		},
+		{
+			name:    "branch beyond the active branch",
+			ops:     []sessionOp{branch("s", 4, "a3")},
+			wantErr: ErrMessageIndex,
+			want:    map[string][]string{"s": original},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewSQLiteSessionStore(":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			if _, err := store.GetOrCreate("s", philosopher.TakamatsuTomori); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Append("s", msgs(original...)...); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, op := range tt.ops {
+				err := op(store)
+				if i < len(tt.ops)-1 {
+					if err != nil {
+						t.Fatalf("op %d: %v", i, err)
+					}
+					continue
+				}
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			}
+
+			for id, want := range tt.want {
+				messages, err := store.Messages(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := []string{}
+				for _, msg := range messages {
+					got = append(got, msg.Content)
+				}
+				if strings.Join(got, ",") != strings.Join(want, ",") {
+					t.Errorf("%s messages = %v, want %v", id, got, want)
+				}
+				if session, err := store.Get(id); err != nil || session.MessageCount != len(want) {
+					t.Errorf("%s message count = %v (%v), want %d", id, session, err, len(want))
+				}
+			}
+
+			if tt.wantBranches != nil {
+				page, total, err := store.MessagePage("s", 0, 100)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, msg := range page {
+					got = append(got, fmt.Sprintf("%d/%d", msg.Branch, msg.Branches))
+				}
+				if total != len(tt.wantBranches) || strings.Join(got, " ") != strings.Join(tt.wantBranches, " ") {
+					t.Errorf("branches = %v (total %d), want %v", got, total, tt.wantBranches)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"agent/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ==================== 会话管理 ====================
+
+// 分页参数
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// SessionListResponse 会话列表
+type SessionListResponse struct {
+	Sessions []*Session `json:"sessions"`
+	Total    int        `json:"total"`
+	Offset   int        `json:"offset"`
+	Limit    int        `json:"limit"`
+}
+
+// SessionMessagesResponse 会话消息（分页）
+type SessionMessagesResponse struct {
+	SessionID string           `json:"session_id"`
+	Messages  []SessionMessage `json:"messages"`
+	Total     int              `json:"total"`
+	Offset    int              `json:"offset"`
+	Limit     int              `json:"limit"`
+}
+
+// SessionForkRequest 从某条消息处分叉出新会话
+type SessionForkRequest struct {
+	MessageIndex *int   `json:"message_index,omitempty"` // 新会话保留到哪条消息（含），-1 表示不带消息，缺省复制全部
+	SessionID    string `json:"session_id,omitempty"`    // 新会话 ID，缺省自动生成
+}
+
+// SessionRewindRequest 回退会话
+type SessionRewindRequest struct {
+	MessageIndex *int `json:"message_index,omitempty"` // 回到哪条消息（含），-1 表示回到开头，缺省撤回最后一轮对话
+}
+
+// handleSessionList 按最后活动时间倒序列出会话
+func (s *Server) handleSessionList(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := pageParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessions, total, err := s.sessions.List(offset, limit)
+	if err != nil {
+		writeSessionError(w, "", err)
+		return
+	}
+
+	writeJSON(w, SessionListResponse{Sessions: sessions, Total: total, Offset: offset, Limit: limit})
+}
+
+// handleSessionGet 获取会话信息
+func (s *Server) handleSessionGet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	session, err := s.sessions.Get(id)
+	if err != nil {
+		writeSessionError(w, id, err)
+		return
+	}
+	writeJSON(w, session)
+}
+
+// handleSessionUpdate 重命名会话或修改元数据
+func (s *Server) handleSessionUpdate(w http.ResponseWriter, r *http.Request) {
+	var patch SessionPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	session, err := s.sessions.Update(id, patch)
+	if err != nil {
+		writeSessionError(w, id, err)
+		return
+	}
+	writeJSON(w, session)
+}
+
+// handleSessionDelete 删除会话
+func (s *Server) handleSessionDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := s.sessions.Delete(id); err != nil {
+		writeSessionError(w, id, err)
+		return
+	}
+
+	s.sessionMutex.Lock()
+	delete(s.sessionUsage, id)
+	s.sessionMutex.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSessionMessages 分页读取会话消息
+func (s *Server) handleSessionMessages(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := pageParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	if _, err := s.sessions.Get(id); err != nil {
+		writeSessionError(w, id, err)
+		return
+	}
+	messages, total, err := s.sessions.MessagePage(id, offset, limit)
+	if err != nil {
+		writeSessionError(w, id, err)
+		return
+	}
+
+	writeJSON(w, SessionMessagesResponse{
+		SessionID: id,
+		Messages:  messages,
+		Total:     total,
+		Offset:    offset,
+		Limit:     limit,
+	})
+}
+
+// handleSessionFork 从某条消息处分叉出新会话，原会话不变
+func (s *Server) handleSessionFork(w http.ResponseWriter, r *http.Request) {
+	var req SessionForkRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	session, err := s.sessions.Get(id)
+	if err != nil {
+		writeSessionError(w, id, err)
+		return
+	}
+
+	keep := session.MessageCount
+	if req.MessageIndex != nil {
+		if *req.MessageIndex < -1 || *req.MessageIndex >= session.MessageCount {
+			http.Error(w, "message_index out of range", http.StatusBadRequest)
+			return
+		}
+		keep = *req.MessageIndex + 1
+	}
+	newID := req.SessionID
+	if newID == "" {
+		newID = generateSessionID()
+	}
+
+	forked, err := s.sessions.Fork(id, newID, keep)
+	if err != nil {
+		writeSessionError(w, newID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(forked)
+}
+
+// handleSessionRewind 回退会话：当前分支回到指定消息处（之后的内容保留为分支），缺省撤回最后一轮对话
+func (s *Server) handleSessionRewind(w http.ResponseWriter, r *http.Request) {
+	var req SessionRewindRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	session, err := s.sessions.Get(id)
+	if err != nil {
+		writeSessionError(w, id, err)
+		return
+	}
+
+	var keep int
+	if req.MessageIndex != nil {
+		if *req.MessageIndex < -1 || *req.MessageIndex >= session.MessageCount {
+			http.Error(w, "message_index out of range", http.StatusBadRequest)
+			return
+		}
+		keep = *req.MessageIndex + 1
+	} else {
+		// 撤回到最后一条用户消息之前
+		messages, err := s.sessions.Messages(id)
+		if err != nil {
+			writeSessionError(w, id, err)
+			return
+		}
+		keep = lastUserMessage(messages)
+		if keep < 0 {
+			http.Error(w, "Nothing to rewind", http.StatusConflict)
+			return
+		}
+	}
+
+	session, err = s.sessions.Rewind(id, keep)
+	if err != nil {
+		writeSessionError(w, id, err)
+		return
+	}
+	writeJSON(w, session)
+}
+
+// lastUserMessage 最后一条用户消息的位置，没有时返回 -1
+func lastUserMessage(messages []config.Message) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return i
+		}
+	}
+	return -1
+}
+
+// pageParams 解析 offset / limit 查询参数
+func pageParams(r *http.Request) (offset, limit int, err error) {
+	limit = defaultPageSize
+	q := r.URL.Query()
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			return 0, 0, errors.New("limit must be a positive integer")
+		}
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return offset, limit, nil
+}
+
+// decodeOptionalBody 解析请求体，允许为空
+func decodeOptionalBody(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// writeSessionError 把会话存储的错误转换为 HTTP 状态码
+func writeSessionError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		http.Error(w, "Session not found", http.StatusNotFound)
+	case errors.Is(err, ErrSessionExists):
+		http.Error(w, "Session already exists", http.StatusConflict)
+	case errors.Is(err, ErrMessageIndex):
+		http.Error(w, "message_index out of range", http.StatusBadRequest)
+	default:
+		log.Error().Err(err).Str("session", id).Msg("会话存储操作失败")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// writeJSON 以 JSON 写出响应
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func generateSessionID() string {
+	return "s-" + time.Now().Format("20060102150405") + "-" + randomString(6)
+}
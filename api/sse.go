@@ -0,0 +1,420 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"agent/config"
+	"agent/philosopher"
+	"agent/react"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ==================== Server-Sent Events ====================
+
+// SSE 事件类型
+const (
+	EventToken      = "token"      // 模型输出的增量文本
+	EventStep       = "step"       // ReAct 的一步（react.Step）
+	EventReflection = "reflection" // 反思结果
+	EventSpeech     = "speech"     // 辩论发言（philosopher.DebateRecord）
+	EventPhase      = "phase"      // 辩论阶段切换
+	EventHumanTurn  = "human_turn" // 轮到人类发言（philosopher.HumanTurn）
+	EventStatus     = "status"     // 辩论状态变化（暂停 / 继续）
+	EventDone       = "done"       // 完成，data 为完整响应
+	EventError      = "error"      // 出错
+)
+
+// StreamEvent 推送给客户端的事件
+type StreamEvent struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// TokenEvent token 事件的数据
+type TokenEvent struct {
+	Content string `json:"content"`
+}
+
+// PhaseEvent phase 事件的数据
+type PhaseEvent struct {
+	Phase philosopher.DebatePhase `json:"phase"`
+}
+
+// ErrorEvent error 事件的数据
+type ErrorEvent struct {
+	Error string `json:"error"`
+}
+
+// sseWriter 封装 SSE 响应的写入
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newSSEWriter 设置 SSE 响应头，不支持 Flush 时返回 nil
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // 关闭 nginx 缓冲
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sseWriter{w: w, flusher: flusher}
+}
+
+// Send 写入一个事件并立即刷新
+func (s *sseWriter) Send(event StreamEvent) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// Ping 发送注释行保活，防止代理断开空闲连接
+func (s *sseWriter) Ping() error {
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// ==================== 流式一对一对话 ====================
+
+func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	route, err := config.ParseRoutePolicy(req.Route)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.requestContext(r)
+	defer cancel()
+	ctx = config.WithRoute(ctx, route)
+
+	// 获取或创建会话
+	session, history, err := s.loadSession(req.SessionID, req.Philosopher)
+	if err != nil {
+		log.Error().Err(err).Str("session", req.SessionID).Msg("读取会话失败")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	ctx, turnUsage := s.trackTurn(ctx, session)
+	if handleRequestError(w, config.CheckBudget(ctx)) {
+		return
+	}
+
+	sse := newSSEWriter(w)
+	if sse == nil {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// 分析情绪
+	emotionLevel := s.emotionAnalyzer.Analyze(ctx, req.Message)
+
+	// 添加用户消息（回复成功后才写入会话）
+	userMsg := config.Message{Role: "user", Content: req.Message}
+	history = append(history, userMsg)
+
+	p := philosopher.NewPhilosopher(req.Philosopher, s.model)
+	response, err := p.ChatStream(ctx, history, emotionLevel, func(token string) {
+		sse.Send(StreamEvent{Type: EventToken, Data: TokenEvent{Content: token}})
+	})
+	if errors.Is(err, context.Canceled) {
+		log.Info().Msg("Client disconnected, chat stream aborted")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Chat stream failed")
+		sse.Send(StreamEvent{Type: EventError, Data: ErrorEvent{Error: err.Error()}})
+		return
+	}
+
+	s.deduplicator.AddResponse(response)
+
+	// 保存本轮对话
+	if err := s.sessions.Append(session.ID, userMsg, config.Message{Role: "assistant", Content: response}); err != nil {
+		log.Error().Err(err).Str("session", session.ID).Msg("保存会话失败")
+	}
+
+	sse.Send(StreamEvent{Type: EventDone, Data: ChatResponse{
+		Response:     response,
+		Philosopher:  p.Name,
+		EmotionLevel: emotionLevel,
+		CriticalHit:  containsCriticalHit(response),
+		Usage:        turnUsage.Summary(),
+		SessionUsage: s.sessionUsageSummary(session.ID),
+	}})
+}
+
+// ==================== 流式 Agent 对话 ====================
+
+func (s *Server) handleAgentChatStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AgentChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	route, err := config.ParseRoutePolicy(req.Route)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	agent, err := philosopher.NewAgent(req.Philosopher, s.model, s.agentConfig(&req))
+	if err != nil {
+		log.Error().Err(err).Msg("创建 Agent 失败")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := s.requestContext(r)
+	defer cancel()
+	ctx = config.WithRoute(ctx, route)
+
+	session, history, err := s.loadSession(req.SessionID, req.Philosopher)
+	if err != nil {
+		log.Error().Err(err).Str("session", req.SessionID).Msg("读取会话失败")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	ctx, turnUsage := s.trackTurn(ctx, session)
+	if handleRequestError(w, config.CheckBudget(ctx)) {
+		return
+	}
+
+	sse := newSSEWriter(w)
+	if sse == nil {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := agent.ChatStream(ctx, req.Message, history, &philosopher.AgentCallbacks{
+		OnToken: func(token string) {
+			sse.Send(StreamEvent{Type: EventToken, Data: TokenEvent{Content: token}})
+		},
+		OnStep: func(step react.Step) {
+			sse.Send(StreamEvent{Type: EventStep, Data: step})
+		},
+		OnReflection: func(result *philosopher.ReflectionResult) {
+			sse.Send(StreamEvent{Type: EventReflection, Data: result})
+		},
+	})
+	if errors.Is(err, context.Canceled) {
+		log.Info().Msg("Client disconnected, agent chat stream aborted")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Agent chat stream failed")
+		sse.Send(StreamEvent{Type: EventError, Data: ErrorEvent{Error: err.Error()}})
+		return
+	}
+
+	// 更新会话
+	if err := s.sessions.Append(session.ID,
+		config.Message{Role: "user", Content: req.Message},
+		config.Message{Role: "assistant", Content: result.Content},
+	); err != nil {
+		log.Error().Err(err).Str("session", session.ID).Msg("保存会话失败")
+	}
+
+	sse.Send(StreamEvent{Type: EventDone, Data: AgentChatResponse{
+		Response:         result.Content,
+		Philosopher:      agent.Name,
+		EmotionLevel:     result.EmotionLevel,
+		ToolResults:      result.ToolResults,
+		ReActSteps:       result.ReActSteps,
+		ReflectionResult: result.ReflectionResult,
+		AgentEnabled:     true,
+		Usage:            turnUsage.Summary(),
+		SessionUsage:     s.sessionUsageSummary(session.ID),
+	}})
+}
+
+// ==================== 辩论事件订阅 ====================
+
+// handleDebateEvents 订阅异步辩论的实时事件（替代轮询 /api/debate/status）
+// 订阅时先补发已有的发言，再推送后续事件，直到辩论结束或客户端断开
+func (s *Server) handleDebateEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	debateID := r.URL.Query().Get("id")
+	if debateID == "" {
+		http.Error(w, "Missing debate id", http.StatusBadRequest)
+		return
+	}
+
+	// 在锁内拍快照并注册订阅，保证不丢事件也不重复
+	s.debateMutex.Lock()
+	session, ok := s.debates[debateID]
+	if !ok {
+		s.debateMutex.Unlock()
+		http.Error(w, "Debate not found", http.StatusNotFound)
+		return
+	}
+	backlog := make([]philosopher.DebateRecord, len(session.Records))
+	copy(backlog, session.Records)
+	phase := session.CurrentPhase
+	awaiting := session.AwaitingHuman
+	finished := session.Status.finished()
+	var events chan StreamEvent
+	if !finished {
+		events = session.subscribe()
+	}
+	snapshot := session.response()
+	s.debateMutex.Unlock()
+
+	if events != nil {
+		defer s.unsubscribeDebate(session, events)
+	}
+
+	sse := newSSEWriter(w)
+	if sse == nil {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sse.Send(StreamEvent{Type: EventPhase, Data: PhaseEvent{Phase: phase}})
+	for _, record := range backlog {
+		sse.Send(StreamEvent{Type: EventSpeech, Data: record})
+	}
+	if snapshot.Status == DebateStatusPaused {
+		sse.Send(StreamEvent{Type: EventStatus, Data: StatusEvent{Status: snapshot.Status}})
+	}
+	if awaiting != nil && !finished {
+		sse.Send(StreamEvent{Type: EventHumanTurn, Data: awaiting})
+	}
+
+	if finished {
+		s.sendDebateFinal(sse, snapshot)
+		return
+	}
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if err := sse.Ping(); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := sse.Send(event); err != nil {
+				return
+			}
+			if event.Type == EventDone || event.Type == EventError {
+				return
+			}
+		}
+	}
+}
+
+// sendDebateFinal 向已结束辩论的订阅者发送最终事件
+func (s *Server) sendDebateFinal(sse *sseWriter, resp DebateResponse) {
+	if resp.Status == DebateStatusFailed {
+		sse.Send(StreamEvent{Type: EventError, Data: ErrorEvent{Error: resp.Error}})
+		return
+	}
+	sse.Send(StreamEvent{Type: EventDone, Data: resp})
+}
+
+// subscribe 注册一个事件订阅（调用方需持有 debateMutex）
+func (d *DebateSession) subscribe() chan StreamEvent {
+	ch := make(chan StreamEvent, 64)
+	d.subscribers = append(d.subscribers, ch)
+	return ch
+}
+
+// publish 向所有订阅者广播事件（调用方需持有 debateMutex）
+// 订阅者消费过慢时丢弃事件，避免阻塞辩论流程
+func (d *DebateSession) publish(event StreamEvent) {
+	for _, ch := range d.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Warn().Str("debate_id", d.ID).Str("event", event.Type).Msg("订阅者处理过慢，丢弃事件")
+		}
+	}
+}
+
+// closeSubscribers 关闭所有订阅（调用方需持有 debateMutex）
+func (d *DebateSession) closeSubscribers() {
+	for _, ch := range d.subscribers {
+		close(ch)
+	}
+	d.subscribers = nil
+}
+
+// unsubscribeDebate 取消订阅
+func (s *Server) unsubscribeDebate(session *DebateSession, ch chan StreamEvent) {
+	s.debateMutex.Lock()
+	defer s.debateMutex.Unlock()
+
+	for i, sub := range session.subscribers {
+		if sub == ch {
+			session.subscribers = append(session.subscribers[:i], session.subscribers[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+// response 生成辩论会话的响应快照（调用方需持有 debateMutex）
+func (d *DebateSession) response() DebateResponse {
+	return DebateResponse{
+		ID:            d.ID,
+		Status:        d.Status,
+		Topic:         d.Topic,
+		Format:        d.Format,
+		CurrentPhase:  d.CurrentPhase,
+		Records:       d.Records,
+		Judgement:     d.Judgement,
+		AwaitingHuman: d.AwaitingHuman,
+		Usage:         d.usage.Summary(),
+		Error:         d.Error,
+	}
+}
@@ -0,0 +1,304 @@
+// fakellm 本地的 OpenAI 兼容假模型服务，用于集成测试
+//
+//	go run ./cmd/fakellm -addr :9090 -script cmd/fakellm/example.yaml
+//
+// 把 config.yaml 的 base_url 指向 http://localhost:9090 即可。
+// 支持按正则返回固定回复、脚本化的 tool_calls 序列、注入 429/500/超时、慢速流式输出和畸形 JSON。
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"agent/config"
+	"agent/utils"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	addr := flag.String("addr", ":9090", "监听地址")
+	scriptPath := flag.String("script", "", "脚本文件（YAML / JSON），为空则回显用户消息")
+	flag.Parse()
+
+	script := &Script{}
+	if *scriptPath != "" {
+		var err error
+		script, err = LoadScript(*scriptPath)
+		if err != nil {
+			log.Fatal().Err(err).Msg("加载脚本失败")
+		}
+	}
+
+	log.Info().Str("addr", *addr).Int("rules", len(script.Rules)).Msg("fakellm 已启动")
+	if err := http.ListenAndServe(*addr, newHandler(script)); err != nil {
+		log.Fatal().Err(err).Msg("fakellm 启动失败")
+	}
+}
+
+type server struct {
+	script *Script
+	seq    atomic.Int64 // 生成 tool_call / completion ID
+}
+
+// newHandler 按脚本应答的 HTTP 处理器（集成测试中配合 httptest 使用）
+func newHandler(script *Script) http.Handler {
+	srv := &server{script: script}
+	mux := http.NewServeMux()
+	mux.HandleFunc(utils.ChatCompletionsPath, srv.handleChatCompletions)
+	mux.HandleFunc("/v1"+utils.ChatCompletionsPath, srv.handleChatCompletions)
+	mux.HandleFunc("/__reset", srv.handleReset)
+	return mux
+}
+
+// chatRequest 只解析需要的字段
+type chatRequest struct {
+	Model    string           `json:"model"`
+	Messages []config.Message `json:"messages"`
+	Stream   bool             `json:"stream"`
+
+	StreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+func (s *server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":{"message":"invalid request body"}}`, http.StatusBadRequest)
+		return
+	}
+
+	lastUser := lastUserMessage(req.Messages)
+	rule, step := s.script.Next(lastUser)
+	if step == nil {
+		reply := s.script.DefaultReply
+		if reply == "" {
+			reply = "echo: " + lastUser
+		}
+		step = &Step{Reply: reply}
+	}
+
+	ruleName := "<default>"
+	if rule != nil {
+		ruleName = rule.Name
+	}
+	log.Info().Str("rule", ruleName).Bool("stream", req.Stream).Str("user", truncate(lastUser, 40)).Msg("chat/completions")
+
+	if step.DelayMs > 0 {
+		select {
+		case <-time.After(time.Duration(step.DelayMs) * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if step.Status != 0 && step.Status != http.StatusOK {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(step.Status)
+		fmt.Fprintf(w, `{"error":{"message":"injected %d","type":"fakellm"}}`, step.Status)
+		return
+	}
+
+	if step.Malformed {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"broken`)
+		return
+	}
+
+	if req.Stream {
+		s.writeStream(w, r, &req, step)
+		return
+	}
+	s.writeCompletion(w, &req, step)
+}
+
+// writeCompletion 一次性返回完整响应（usage 按文本长度估算）
+func (s *server) writeCompletion(w http.ResponseWriter, req *chatRequest, step *Step) {
+	message := map[string]interface{}{
+		"role":    utils.RoleAssistant,
+		"content": step.Reply,
+	}
+	finish := "stop"
+	if len(step.ToolCalls) > 0 {
+		message["tool_calls"] = s.toolCalls(step)
+		finish = "tool_calls"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     s.nextID("chatcmpl"),
+		"object": "chat.completion",
+		"model":  req.Model,
+		"choices": []map[string]interface{}{
+			{"index": 0, "message": message, "finish_reason": finish},
+		},
+		"usage": config.EstimateUsage(req.Messages, step.Reply),
+	})
+}
+
+// writeStream 以 SSE 分片返回，工具调用按 OpenAI 的增量格式输出
+// 请求带 stream_options.include_usage 时，在 [DONE] 前追加一个只含 usage 的事件
+func (s *server) writeStream(w http.ResponseWriter, r *http.Request, req *chatRequest, step *Step) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	id := s.nextID("chatcmpl")
+	write := func(event map[string]interface{}) bool {
+		event["id"] = id
+		event["object"] = "chat.completion.chunk"
+		event["model"] = req.Model
+		data, _ := json.Marshal(event)
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		if step.ChunkDelayMs > 0 {
+			select {
+			case <-time.After(time.Duration(step.ChunkDelayMs) * time.Millisecond):
+			case <-r.Context().Done():
+				return false
+			}
+		}
+		return true
+	}
+	send := func(delta map[string]interface{}, finish interface{}) bool {
+		return write(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"index": 0, "delta": delta, "finish_reason": finish},
+			},
+		})
+	}
+
+	if !send(map[string]interface{}{"role": utils.RoleAssistant}, nil) {
+		return
+	}
+
+	size := step.ChunkSize
+	if size <= 0 {
+		size = 4
+	}
+	for _, piece := range splitRunes(step.Reply, size) {
+		if !send(map[string]interface{}{"content": piece}, nil) {
+			return
+		}
+	}
+
+	for i, tc := range s.toolCalls(step) {
+		fn := tc["function"].(map[string]interface{})
+		head := map[string]interface{}{
+			"index":    i,
+			"id":       tc["id"],
+			"type":     "function",
+			"function": map[string]interface{}{"name": fn["name"], "arguments": ""},
+		}
+		if !send(map[string]interface{}{"tool_calls": []interface{}{head}}, nil) {
+			return
+		}
+		for _, piece := range splitRunes(fn["arguments"].(string), size) {
+			frag := map[string]interface{}{
+				"index":    i,
+				"function": map[string]interface{}{"arguments": piece},
+			}
+			if !send(map[string]interface{}{"tool_calls": []interface{}{frag}}, nil) {
+				return
+			}
+		}
+	}
+
+	finish := "stop"
+	if len(step.ToolCalls) > 0 {
+		finish = "tool_calls"
+	}
+	if !send(map[string]interface{}{}, finish) {
+		return
+	}
+	if req.StreamOptions.IncludeUsage {
+		usage := config.EstimateUsage(req.Messages, step.Reply)
+		if !write(map[string]interface{}{"choices": []interface{}{}, "usage": usage}) {
+			return
+		}
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// toolCalls 把脚本中的工具调用转换为 OpenAI 格式
+func (s *server) toolCalls(step *Step) []map[string]interface{} {
+	var calls []map[string]interface{}
+	for _, tc := range step.ToolCalls {
+		args := tc.Arguments
+		if args == "" {
+			args = "{}"
+		}
+		calls = append(calls, map[string]interface{}{
+			"id":   s.nextID("call"),
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":      tc.Name,
+				"arguments": args,
+			},
+		})
+	}
+	return calls
+}
+
+func (s *server) handleReset(w http.ResponseWriter, r *http.Request) {
+	s.script.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) nextID(prefix string) string {
+	return fmt.Sprintf("%s_%d", prefix, s.seq.Add(1))
+}
+
+// lastUserMessage 最后一条 user 消息
+func lastUserMessage(messages []config.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == utils.RoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// splitRunes 按字符数切分（不切断多字节字符）
+func splitRunes(s string, size int) []string {
+	runes := []rune(s)
+	var parts []string
+	for i := 0; i < len(runes); i += size {
+		end := i + size
+		if end > len(runes) {
+			end = len(runes)
+		}
+		parts = append(parts, string(runes[i:end]))
+	}
+	return parts
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(strings.TrimSpace(s))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"agent/config"
+	"agent/philosopher"
+	"agent/react"
+)
+
+// fakeLLM 按脚本应答的本地服务，hits 为收到的 chat/completions 请求数（含重试）
+type fakeLLM struct {
+	*httptest.Server
+	hits atomic.Int64
+}
+
+func startFakeLLM(t *testing.T, script string) *fakeLLM {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadScript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeLLM{}
+	handler := newHandler(s)
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			f.hits.Add(1)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func sourceStats(t *testing.T, m *config.FaultTolerantModel, name string) config.SourceStats {
+	t.Helper()
+	return m.GetStats()["sources"].(map[string]config.SourceStats)[name]
+}
+
+const fallbackMessage = "兜底回复"
+
+func TestFaultTolerantModel(t *testing.T) {
+	const ok = `
+rules:
+  - steps:
+      - reply: "备用源的回复"
+        chunk_size: 2
+`
+	tests := []struct {
+		name          string
+		primary       string
+		backup        string // 为空表示只有主源
+		timeout       int    // 主源超时（秒）
+		maxRetries    int    // 主源重试次数
+		stream        bool
+		wantContent   string
+		wantPrimary   int64 // 主源收到的请求数
+		wantBackup    int64
+		wantFailure   int    // 主源记录的失败次数
+		wantLastError string // 主源最近一次错误包含的内容
+	}{
+		{
+			name: "retry on 429 and 500",
+			primary: `
+rules:
+  - steps:
+      - status: 429
+      - status: 500
+      - reply: "终于连上了。"
+`,
+			maxRetries:  2,
+			wantContent: "终于连上了。",
+			wantPrimary: 3,
+		},
+		{
+			name: "failover after 5xx retries",
+			primary: `
+rules:
+  - steps:
+      - status: 500
+`,
+			backup:        ok,
+			maxRetries:    1,
+			wantContent:   "备用源的回复",
+			wantPrimary:   2,
+			wantBackup:    1,
+			wantFailure:   1,
+			wantLastError: "injected 500",
+		},
+		{
+			name: "failover after timeout retries",
+			primary: `
+rules:
+  - steps:
+      - delay_ms: 3000
+        reply: "太晚了"
+`,
+			backup:        ok,
+			timeout:       1,
+			maxRetries:    1,
+			wantContent:   "备用源的回复",
+			wantPrimary:   2,
+			wantBackup:    1,
+			wantFailure:   1,
+			wantLastError: "Client.Timeout exceeded",
+		},
+		{
+			name: "malformed JSON is not retried",
+			primary: `
+rules:
+  - steps:
+      - malformed: true
+`,
+			backup:        ok,
+			maxRetries:    2,
+			wantContent:   "备用源的回复",
+			wantPrimary:   1,
+			wantBackup:    1,
+			wantFailure:   1,
+			wantLastError: "unexpected end of JSON input",
+		},
+		{
+			name: "client error is not retried",
+			primary: `
+rules:
+  - steps:
+      - status: 400
+`,
+			maxRetries:    2,
+			wantContent:   fallbackMessage,
+			wantPrimary:   1,
+			wantFailure:   1,
+			wantLastError: "injected 400",
+		},
+		{
+			name: "all sources fail",
+			primary: `
+rules:
+  - steps:
+      - status: 500
+`,
+			backup: `
+rules:
+  - steps:
+      - status: 503
+`,
+			maxRetries:    1,
+			wantContent:   fallbackMessage,
+			wantPrimary:   2,
+			wantBackup:    3,
+			wantFailure:   1,
+			wantLastError: "injected 500",
+		},
+		{
+			name: "stream fails over before first chunk",
+			primary: `
+rules:
+  - steps:
+      - status: 500
+`,
+			backup:        ok,
+			stream:        true,
+			wantContent:   "备用源的回复",
+			wantPrimary:   1,
+			wantBackup:    1,
+			wantFailure:   1,
+			wantLastError: "injected 500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 重试之间要等待，各用例并行执行
+			t.Parallel()
+
+			primary := startFakeLLM(t, tt.primary)
+			sources := []config.APISource{{
+				Name: "primary", BaseURL: primary.URL, ModelName: "fake", Priority: 1,
+				Timeout: tt.timeout, MaxRetries: tt.maxRetries,
+			}}
+			var backup *fakeLLM
+			if tt.backup != "" {
+				backup = startFakeLLM(t, tt.backup)
+				sources = append(sources, config.APISource{Name: "backup", BaseURL: backup.URL, ModelName: "fake", Priority: 2})
+			}
+			m := config.NewFaultTolerantModel(&config.MultiAPIConfig{
+				Sources:         sources,
+				FallbackMessage: fallbackMessage,
+			})
+
+			messages := []config.Message{{Role: "user", Content: "你好"}}
+			var (
+				content string
+				err     error
+				chunks  strings.Builder
+			)
+			if tt.stream {
+				content, _, err = m.InvokeStream(context.Background(), messages, nil, func(c config.StreamChunk) {
+					chunks.WriteString(c.Content)
+				})
+			} else {
+				content, _, err = m.Invoke(context.Background(), messages, nil)
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if content != tt.wantContent {
+				t.Errorf("content = %q, want %q", content, tt.wantContent)
+			}
+			if m.IsFallback(content) != (tt.wantContent == fallbackMessage) {
+				t.Errorf("IsFallback(%q) = %v", content, m.IsFallback(content))
+			}
+			if tt.stream && chunks.String() != content {
+				t.Errorf("streamed %q, want %q", chunks.String(), content)
+			}
+
+			if got := primary.hits.Load(); got != tt.wantPrimary {
+				t.Errorf("primary attempts = %d, want %d", got, tt.wantPrimary)
+			}
+			if backup != nil {
+				if got := backup.hits.Load(); got != tt.wantBackup {
+					t.Errorf("backup attempts = %d, want %d", got, tt.wantBackup)
+				}
+			}
+
+			stats := sourceStats(t, m, "primary")
+			if stats.Failure != tt.wantFailure {
+				t.Errorf("primary failures = %d, want %d", stats.Failure, tt.wantFailure)
+			}
+			if !strings.Contains(stats.LastError, tt.wantLastError) {
+				t.Errorf("primary last error = %q, want it to contain %q", stats.LastError, tt.wantLastError)
+			}
+			if wantSuccess := tt.wantFailure == 0; (stats.Success == 1) != wantSuccess {
+				t.Errorf("primary successes = %d", stats.Success)
+			}
+			if backup != nil && tt.wantContent != fallbackMessage {
+				if got := sourceStats(t, m, "backup").Success; got != 1 {
+					t.Errorf("backup successes = %d, want 1", got)
+				}
+			}
+		})
+	}
+}
+
+func TestChatModel(t *testing.T) {
+	tests := []struct {
+		name        string
+		script      string
+		wantContent string
+		wantErr     string
+	}{
+		{
+			name: "reply",
+			script: `
+rules:
+  - steps:
+      - reply: "嗯...你好。"
+`,
+			wantContent: "嗯...你好。",
+		},
+		{
+			name: "server error surfaces without retry",
+			script: `
+rules:
+  - steps:
+      - status: 500
+`,
+			wantErr: "injected 500",
+		},
+		{
+			name: "malformed JSON surfaces",
+			script: `
+rules:
+  - steps:
+      - malformed: true
+`,
+			wantErr: "unexpected end of JSON input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := startFakeLLM(t, tt.script)
+			m := config.NewChatModel(&config.Config{BaseURL: fake.URL, ModelName: "fake"})
+
+			content, _, err := m.Invoke(context.Background(), []config.Message{{Role: "user", Content: "你好"}}, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if content != tt.wantContent {
+				t.Errorf("content = %q, want %q", content, tt.wantContent)
+			}
+			if got := fake.hits.Load(); got != 1 {
+				t.Errorf("attempts = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestReactToolLoop(t *testing.T) {
+	const script = `
+rules:
+  - match: "歌词"
+    steps:
+      - reply: "想找一句歌词。"
+        tool_calls:
+          - name: search_lyrics
+            arguments: '{"mood":"迷茫"}'
+      - reply: "迷子でもいい、迷子でも進め。"
+`
+	tools := []map[string]interface{}{{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":       "search_lyrics",
+			"parameters": map[string]interface{}{"type": "object"},
+		},
+	}}
+	wantSteps := []react.Step{
+		{Thought: "想找一句歌词。", Action: &react.Action{ToolName: "search_lyrics", Input: `{"mood":"迷茫"}`}, Observation: "迷子でもいい、迷子でも進め"},
+		{Thought: "迷子でもいい、迷子でも進め。"},
+	}
+
+	for _, stream := range []bool{false, true} {
+		name := "invoke"
+		if stream {
+			name = "stream"
+		}
+		t.Run(name, func(t *testing.T) {
+			fake := startFakeLLM(t, script)
+			m := config.NewFaultTolerantModel(&config.MultiAPIConfig{
+				Sources: []config.APISource{{Name: "fake", BaseURL: fake.URL, ModelName: "fake"}},
+			})
+
+			var executed []string
+			input := &react.RunInput{
+				Model: m,
+				Executor: react.ToolExecutorFunc(func(name, args string) (string, error) {
+					executed = append(executed, name+" "+args)
+					return "迷子でもいい、迷子でも進め", nil
+				}),
+				Tools:    tools,
+				Messages: []config.Message{{Role: "user", Content: "有没有适合现在的歌词"}},
+			}
+			var tokens strings.Builder
+			if stream {
+				input.OnToken = func(token string) { tokens.WriteString(token) }
+			}
+
+			result, err := react.Run(context.Background(), input)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if !reflect.DeepEqual(result.Steps, wantSteps) {
+				t.Errorf("Steps = %+v, want %+v", result.Steps, wantSteps)
+			}
+			if want := []string{`search_lyrics {"mood":"迷茫"}`}; !reflect.DeepEqual(executed, want) {
+				t.Errorf("executed = %v, want %v", executed, want)
+			}
+			if got := fake.hits.Load(); got != 2 {
+				t.Errorf("model calls = %d, want 2", got)
+			}
+			if stream && tokens.String() != "想找一句歌词。迷子でもいい、迷子でも進め。" {
+				t.Errorf("streamed tokens = %q", tokens.String())
+			}
+		})
+	}
+}
+
+func TestReflectionRepair(t *testing.T) {
+	tests := []struct {
+		name        string
+		script      string
+		wantRevised string
+		wantHits    int64
+		wantErr     string
+		wantAttempt int // StructuredOutputError.Attempts
+	}{
+		{
+			name: "legacy text is repaired",
+			script: `
+rules:
+  - match: "对以上回复进行反思"
+    steps:
+      - reply: |
+          ACCEPTABLE: false
+          CONFIDENCE: 0.4
+  - match: "JSON Schema"
+    steps:
+      - reply: |
+          ` + "```json" + `
+          {"acceptable": false, "confidence": 0.4,
+           "issues": [{"aspect": "性格一致性", "description": "语气不像角色", "severity": "medium"}],
+           "suggestions": ["多用停顿"],
+           "revised_response": "那个...嗯...我也是这样想的。"}
+          ` + "```" + `
+`,
+			wantRevised: "那个...嗯...我也是这样想的。",
+			wantHits:    2,
+		},
+		{
+			name: "invalid output after repairs",
+			script: `
+rules:
+  - steps:
+      - reply: '{"acceptable": true, "confidence": 3, "issues": [], "suggestions": [], "revised_response": ""}'
+`,
+			wantHits:    3,
+			wantErr:     "confidence must be between 0 and 1",
+			wantAttempt: 3,
+		},
+		{
+			name: "malformed response body",
+			script: `
+rules:
+  - steps:
+      - malformed: true
+`,
+			wantHits: 1,
+			wantErr:  "unexpected end of JSON input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := startFakeLLM(t, tt.script)
+			engine := philosopher.NewReflectionEngine(config.NewChatModel(&config.Config{BaseURL: fake.URL, ModelName: "fake"}))
+
+			revised, result, err := engine.ReflectAndRefine(context.Background(), "嗯。", philosopher.TakamatsuTomori,
+				&philosopher.AgentContext{CurrentMood: "平静"}, "你觉得呢？")
+			if got := fake.hits.Load(); got != tt.wantHits {
+				t.Errorf("model calls = %d, want %d", got, tt.wantHits)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				var structErr *config.StructuredOutputError
+				if gotAs := errors.As(err, &structErr); gotAs != (tt.wantAttempt > 0) {
+					t.Fatalf("errors.As(StructuredOutputError) = %v", gotAs)
+				}
+				if structErr != nil && structErr.Attempts != tt.wantAttempt {
+					t.Errorf("Attempts = %d, want %d", structErr.Attempts, tt.wantAttempt)
+				}
+				if revised != "嗯。" {
+					t.Errorf("revised = %q, want the original response", revised)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if revised != tt.wantRevised {
+				t.Errorf("revised = %q, want %q", revised, tt.wantRevised)
+			}
+			if result.IsAcceptable || len(result.Issues) != 1 || result.Issues[0].Severity != "medium" {
+				t.Errorf("result = %+v", result)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Script 假模型的脚本
+type Script struct {
+	DefaultReply string  `mapstructure:"default_reply"` // 没有规则命中时的回复（为空则回显用户消息）
+	Rules        []*Rule `mapstructure:"rules"`
+
+	mu sync.Mutex
+}
+
+// Rule 一条脚本规则：按正则匹配最后一条 user 消息，命中后依次执行 Steps
+type Rule struct {
+	Name  string `mapstructure:"name"`
+	Match string `mapstructure:"match"` // 正则，为空表示匹配任何消息
+	Steps []Step `mapstructure:"steps"` // 每次命中推进一步，用完后重复最后一步
+
+	re    *regexp.Regexp
+	calls int
+}
+
+// Step 一次调用的脚本化行为
+type Step struct {
+	Reply     string           `mapstructure:"reply"`      // 文本回复
+	ToolCalls []ScriptToolCall `mapstructure:"tool_calls"` // 工具调用
+	Status    int              `mapstructure:"status"`     // 非 0/200 时直接返回该 HTTP 状态码（如 429 / 500）
+	DelayMs   int              `mapstructure:"delay_ms"`   // 响应前等待，用于模拟超时
+	Malformed bool             `mapstructure:"malformed"`  // 返回无法解析的 JSON
+
+	ChunkSize    int `mapstructure:"chunk_size"`     // 流式输出每个片段的字符数（默认 4）
+	ChunkDelayMs int `mapstructure:"chunk_delay_ms"` // 流式输出片段间隔，用于模拟慢速流
+}
+
+// ScriptToolCall 脚本中的工具调用
+type ScriptToolCall struct {
+	Name      string `mapstructure:"name"`
+	Arguments string `mapstructure:"arguments"` // JSON 字符串
+}
+
+// LoadScript 读取脚本（YAML / JSON）并编译正则
+func LoadScript(path string) (*Script, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, errors.Wrap(err, "read script")
+	}
+
+	var script Script
+	if err := v.Unmarshal(&script); err != nil {
+		return nil, errors.Wrap(err, "decode script")
+	}
+	for i, r := range script.Rules {
+		if len(r.Steps) == 0 {
+			return nil, errors.Errorf("rule %d (%s): steps is empty", i, r.Name)
+		}
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, errors.Wrapf(err, "rule %d (%s): bad match", i, r.Name)
+		}
+		r.re = re
+	}
+	return &script, nil
+}
+
+// Next 找到第一条命中的规则并返回它的下一步；没有命中返回 nil
+func (s *Script) Next(lastUser string) (*Rule, *Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.Rules {
+		if !r.re.MatchString(lastUser) {
+			continue
+		}
+		idx := r.calls
+		if idx >= len(r.Steps) {
+			idx = len(r.Steps) - 1
+		}
+		r.calls++
+		step := r.Steps[idx]
+		return r, &step
+	}
+	return nil, nil
+}
+
+// Reset 重置所有规则的调用计数
+func (s *Script) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.Rules {
+		r.calls = 0
+	}
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ==================== 熔断器 ====================
+
+// CircuitBreakerConfig 熔断器配置，对每个 API 源单独生效
+type CircuitBreakerConfig struct {
+	FailureThreshold int `yaml:"failure_threshold" mapstructure:"failure_threshold"` // 连续失败多少次后熔断
+	CoolDown         int `yaml:"cool_down" mapstructure:"cool_down"`                 // 熔断后多久（秒）允许试探
+	HalfOpenMax      int `yaml:"half_open_max" mapstructure:"half_open_max"`         // 半开状态下同时放行的试探请求数，成功这么多次后恢复
+}
+
+// BreakerState 熔断器状态
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // 正常放行
+	BreakerOpen     BreakerState = "open"      // 熔断中，跳过该源
+	BreakerHalfOpen BreakerState = "half_open" // 冷却结束，放行少量试探请求
+)
+
+// SourceStats 单个 API 源的健康统计
+type SourceStats struct {
+	State               BreakerState `json:"state"`
+	Success             int          `json:"success"`
+	Failure             int          `json:"failure"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	AvgLatencyMs        float64      `json:"avg_latency_ms"`  // 成功调用的平滑平均延迟
+	LastLatencyMs       float64      `json:"last_latency_ms"` // 最近一次成功调用的延迟
+	Hedges              int          `json:"hedges"`          // 作为对冲发起的请求数（也计入 success / failure）
+	HedgeWins           int          `json:"hedge_wins"`      // 其中胜出的次数
+	LastError           string       `json:"last_error,omitempty"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"` // 最近一次熔断的时间
+}
+
+// latencySmoothing 平均延迟的平滑系数（指数加权）
+const latencySmoothing = 0.3
+
+// CircuitBreaker 线程安全的熔断器：closed -> open -> half_open -> closed
+type CircuitBreaker struct {
+	mu  sync.Mutex
+	cfg CircuitBreakerConfig
+	now func() time.Time // 时钟，测试中替换
+
+	state         BreakerState
+	openedAt      time.Time
+	probes        int // 半开状态下正在进行的试探请求
+	probeSuccess  int // 半开状态下已成功的试探请求
+	stats         SourceStats
+	latencyRecord bool // 是否已有延迟样本
+}
+
+// NewCircuitBreaker 创建熔断器，未配置的项使用默认值
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	b := &CircuitBreaker{state: BreakerClosed, now: time.Now}
+	b.SetConfig(cfg)
+	return b
+}
+
+// SetConfig 更新熔断参数（热更新，不重置状态）
+func (b *CircuitBreaker) SetConfig(cfg CircuitBreakerConfig) {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 3
+	}
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = 30
+	}
+	if cfg.HalfOpenMax <= 0 {
+		cfg.HalfOpenMax = 1
+	}
+	b.mu.Lock()
+	b.cfg = cfg
+	b.mu.Unlock()
+}
+
+// Allow 是否放行本次请求；放行后必须调用 OnSuccess / OnFailure / OnCancel 之一
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < time.Duration(b.cfg.CoolDown)*time.Second {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probes, b.probeSuccess = 0, 0
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probes >= b.cfg.HalfOpenMax {
+			return false
+		}
+		b.probes++
+		return true
+	default:
+		return true
+	}
+}
+
+// OnSuccess 记录一次成功调用及其延迟
+func (b *CircuitBreaker) OnSuccess(latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stats.Success++
+	b.stats.ConsecutiveFailures = 0
+	b.recordLatency(latency)
+
+	if b.state == BreakerHalfOpen {
+		b.probes--
+		b.probeSuccess++
+		if b.probeSuccess >= b.cfg.HalfOpenMax {
+			b.state = BreakerClosed
+		}
+	}
+}
+
+// OnFailure 记录一次失败；源本身的故障连续达到阈值或试探失败时熔断
+func (b *CircuitBreaker) OnFailure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stats.Failure++
+	if err != nil {
+		b.stats.LastError = err.Error()
+	}
+	if !isSourceFault(err) {
+		if b.state == BreakerHalfOpen && b.probes > 0 {
+			b.probes--
+		}
+		return
+	}
+	b.stats.ConsecutiveFailures++
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probes--
+		b.trip()
+	case BreakerClosed:
+		if b.stats.ConsecutiveFailures >= b.cfg.FailureThreshold {
+			b.trip()
+		}
+	}
+}
+
+// OnHedge 记录一次对冲请求
+func (b *CircuitBreaker) OnHedge() {
+	b.mu.Lock()
+	b.stats.Hedges++
+	b.mu.Unlock()
+}
+
+// OnHedgeWin 记录一次对冲请求胜出
+func (b *CircuitBreaker) OnHedgeWin() {
+	b.mu.Lock()
+	b.stats.HedgeWins++
+	b.mu.Unlock()
+}
+
+// OnCancel 调用方取消了请求：不计成败，只归还试探名额
+func (b *CircuitBreaker) OnCancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// Stats 当前状态与统计的快照
+func (b *CircuitBreaker) Stats() SourceStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := b.stats
+	stats.State = b.state
+	if !b.openedAt.IsZero() {
+		openedAt := b.openedAt
+		stats.OpenedAt = &openedAt
+	}
+	return stats
+}
+
+// AvgLatency 平均延迟，尚无样本时返回 0
+func (b *CircuitBreaker) AvgLatency() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Duration(b.stats.AvgLatencyMs * float64(time.Millisecond))
+}
+
+func (b *CircuitBreaker) trip() {
+	b.state = BreakerOpen
+	b.openedAt = b.now()
+	b.probes, b.probeSuccess = 0, 0
+}
+
+func (b *CircuitBreaker) recordLatency(latency time.Duration) {
+	ms := float64(latency) / float64(time.Millisecond)
+	b.stats.LastLatencyMs = ms
+	if !b.latencyRecord {
+		b.stats.AvgLatencyMs = ms
+		b.latencyRecord = true
+		return
+	}
+	b.stats.AvgLatencyMs = latencySmoothing*ms + (1-latencySmoothing)*b.stats.AvgLatencyMs
+}
+
+// isSourceFault 错误是否说明 API 源本身不健康
+// 请求本身有误（4xx，超时与限流除外）不应让源熔断
+func isSourceFault(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 {
+		return apiErr.StatusCode == http.StatusRequestTimeout || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// breakerOp 对熔断器的一次操作
+type breakerOp struct {
+	op        string // allow / success / failure / cancel / advance
+	err       error  // failure 的错误
+	d         time.Duration
+	wantAllow bool // allow 的期望结果
+}
+
+var (
+	errServer = &APIError{StatusCode: 500, Body: "boom"}
+	errLimit  = &APIError{StatusCode: 429, Body: "slow down"}
+	errBadReq = &APIError{StatusCode: 400, Body: "bad request"}
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cfg := CircuitBreakerConfig{FailureThreshold: 2, CoolDown: 10, HalfOpenMax: 1}
+	trip := []breakerOp{{op: "failure", err: errServer}, {op: "failure", err: errServer}}
+
+	tests := []struct {
+		name      string
+		cfg       CircuitBreakerConfig
+		ops       []breakerOp
+		wantState BreakerState
+	}{
+		{
+			name:      "below threshold stays closed",
+			cfg:       cfg,
+			ops:       []breakerOp{{op: "failure", err: errServer}, {op: "allow", wantAllow: true}},
+			wantState: BreakerClosed,
+		},
+		{
+			name: "success resets consecutive failures",
+			cfg:  cfg,
+			ops: []breakerOp{
+				{op: "failure", err: errServer}, {op: "success"}, {op: "failure", err: errServer},
+				{op: "allow", wantAllow: true},
+			},
+			wantState: BreakerClosed,
+		},
+		{
+			name:      "consecutive failures open the circuit",
+			cfg:       cfg,
+			ops:       append(trip, breakerOp{op: "allow", wantAllow: false}),
+			wantState: BreakerOpen,
+		},
+		{
+			name: "rate limiting counts as a source fault",
+			cfg:  cfg,
+			ops: []breakerOp{
+				{op: "failure", err: errLimit}, {op: "failure", err: errLimit},
+				{op: "allow", wantAllow: false},
+			},
+			wantState: BreakerOpen,
+		},
+		{
+			name: "client errors do not open the circuit",
+			cfg:  cfg,
+			ops: []breakerOp{
+				{op: "failure", err: errBadReq}, {op: "failure", err: errBadReq}, {op: "failure", err: errBadReq},
+				{op: "allow", wantAllow: true},
+			},
+			wantState: BreakerClosed,
+		},
+		{
+			name: "stays open until cool down elapses",
+			cfg:  cfg,
+			ops: append(trip,
+				breakerOp{op: "advance", d: 9 * time.Second},
+				breakerOp{op: "allow", wantAllow: false},
+			),
+			wantState: BreakerOpen,
+		},
+		{
+			name: "cool down lets a single probe through",
+			cfg:  cfg,
+			ops: append(trip,
+				breakerOp{op: "advance", d: 10 * time.Second},
+				breakerOp{op: "allow", wantAllow: true},
+				breakerOp{op: "allow", wantAllow: false},
+			),
+			wantState: BreakerHalfOpen,
+		},
+		{
+			name: "probe success closes the circuit",
+			cfg:  cfg,
+			ops: append(trip,
+				breakerOp{op: "advance", d: 10 * time.Second},
+				breakerOp{op: "allow", wantAllow: true},
+				breakerOp{op: "success"},
+				breakerOp{op: "allow", wantAllow: true},
+				breakerOp{op: "allow", wantAllow: true},
+			),
+			wantState: BreakerClosed,
+		},
+		{
+			name: "probe failure reopens for another cool down",
+			cfg:  cfg,
+			ops: append(trip,
+				breakerOp{op: "advance", d: 10 * time.Second},
+				breakerOp{op: "allow", wantAllow: true},
+				breakerOp{op: "failure", err: errServer},
+				breakerOp{op: "allow", wantAllow: false},
+				breakerOp{op: "advance", d: 9 * time.Second},
+				breakerOp{op: "allow", wantAllow: false},
+				breakerOp{op: "advance", d: time.Second},
+				breakerOp{op: "allow", wantAllow: true},
+			),
+			wantState: BreakerHalfOpen,
+		},
+		{
+			name: "cancelled probe returns its slot",
+			cfg:  cfg,
+			ops: append(trip,
+				breakerOp{op: "advance", d: 10 * time.Second},
+				breakerOp{op: "allow", wantAllow: true},
+				breakerOp{op: "cancel"},
+				breakerOp{op: "allow", wantAllow: true},
+			),
+			wantState: BreakerHalfOpen,
+		},
+		{
+			name: "closes after half_open_max successful probes",
+			cfg:  CircuitBreakerConfig{FailureThreshold: 1, CoolDown: 5, HalfOpenMax: 2},
+			ops: []breakerOp{
+				{op: "failure", err: errServer},
+				{op: "advance", d: 5 * time.Second},
+				{op: "allow", wantAllow: true},
+				{op: "allow", wantAllow: true},
+				{op: "allow", wantAllow: false},
+				{op: "success"},
+				{op: "success"},
+			},
+			wantState: BreakerClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+			b := NewCircuitBreaker(tt.cfg)
+			b.now = clock.now
+
+			for i, op := range tt.ops {
+				switch op.op {
+				case "allow":
+					if got := b.Allow(); got != op.wantAllow {
+						t.Fatalf("op %d: Allow() = %v, want %v (state %s)", i, got, op.wantAllow, b.Stats().State)
+					}
+				case "success":
+					b.OnSuccess(10 * time.Millisecond)
+				case "failure":
+					b.OnFailure(op.err)
+				case "cancel":
+					b.OnCancel()
+				case "advance":
+					clock.advance(op.d)
+				default:
+					t.Fatalf("unknown op %q", op.op)
+				}
+			}
+			if got := b.Stats().State; got != tt.wantState {
+				t.Errorf("state = %s, want %s", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerStats(t *testing.T) {
+	b := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1})
+	b.OnSuccess(100 * time.Millisecond)
+	b.OnSuccess(200 * time.Millisecond)
+	b.OnFailure(errors.New("connection refused"))
+
+	stats := b.Stats()
+	if stats.Success != 2 || stats.Failure != 1 {
+		t.Errorf("success/failure = %d/%d, want 2/1", stats.Success, stats.Failure)
+	}
+	if stats.State != BreakerOpen || stats.OpenedAt == nil {
+		t.Errorf("state = %s opened_at = %v, want open with a timestamp", stats.State, stats.OpenedAt)
+	}
+	if stats.LastError != "connection refused" {
+		t.Errorf("last error = %q", stats.LastError)
+	}
+	// 平滑平均：0.3*200 + 0.7*100
+	if stats.AvgLatencyMs != 130 || stats.LastLatencyMs != 200 {
+		t.Errorf("avg/last latency = %v/%v, want 130/200", stats.AvgLatencyMs, stats.LastLatencyMs)
+	}
+}
+
+func TestOrderSources(t *testing.T) {
+	m := NewFaultTolerantModel(&MultiAPIConfig{Sources: []APISource{
+		{Name: "slow", Priority: 1},
+		{Name: "fast", Priority: 1},
+		{Name: "backup", Priority: 2},
+		{Name: "unmeasured", Priority: 1},
+	}})
+	m.breaker("slow").OnSuccess(300 * time.Millisecond)
+	m.breaker("fast").OnSuccess(50 * time.Millisecond)
+	m.breaker("backup").OnSuccess(time.Millisecond)
+
+	sources, _, _ := m.snapshot()
+	var got []string
+	for _, s := range m.orderSources(sources) {
+		got = append(got, s.Name)
+	}
+	// 同优先级按平均延迟，没有样本的源排在最前面以便尽快测量
+	want := []string{"unmeasured", "fast", "slow", "backup"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+}
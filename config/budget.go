@@ -0,0 +1,171 @@
+package config
+
+import (
+	"context"
+	"fmt"
+)
+
+// ==================== Token / 费用预算 ====================
+
+// BudgetConfig 预算配置，0 表示不限制
+type BudgetConfig struct {
+	TurnTokens         int     `yaml:"turn_tokens" mapstructure:"turn_tokens"`                   // 单轮对话（含情绪分析、ReAct、反思）的 token 上限
+	SessionDailyTokens int     `yaml:"session_daily_tokens" mapstructure:"session_daily_tokens"` // 单个会话每天的 token 上限
+	SessionDailyCost   float64 `yaml:"session_daily_cost" mapstructure:"session_daily_cost"`     // 单个会话每天的费用上限
+	DebateTokens       int     `yaml:"debate_tokens" mapstructure:"debate_tokens"`               // 单场辩论 / 讨论的 token 上限
+	DebateCost         float64 `yaml:"debate_cost" mapstructure:"debate_cost"`                   // 单场辩论 / 讨论的费用上限
+	DegradeRatio       float64 `yaml:"degrade_ratio" mapstructure:"degrade_ratio"`               // 用量达到上限的该比例后开始降级
+}
+
+// 预算范围
+const (
+	BudgetScopeTurn    = "turn"
+	BudgetScopeSession = "session_daily"
+	BudgetScopeDebate  = "debate"
+)
+
+// BudgetLevel 预算状态，数值越大越紧张
+type BudgetLevel int
+
+const (
+	BudgetOK        BudgetLevel = iota // 正常
+	BudgetDegraded                     // 接近上限：关闭反思 / 迭代优化、切换轻量模型、缩短发言
+	BudgetExhausted                    // 已用尽：拒绝新的模型调用
+)
+
+func (l BudgetLevel) String() string {
+	switch l {
+	case BudgetDegraded:
+		return "degraded"
+	case BudgetExhausted:
+		return "exhausted"
+	default:
+		return "ok"
+	}
+}
+
+// BudgetExceededError 预算用尽，API 层映射为 HTTP 429
+type BudgetExceededError struct {
+	Scope      string  // BudgetScopeTurn / BudgetScopeSession / BudgetScopeDebate
+	UsedTokens int     // 已用 token
+	MaxTokens  int     // token 上限（0 表示未限制）
+	UsedCost   float64 // 已用费用
+	MaxCost    float64 // 费用上限（0 表示未限制）
+}
+
+func (e *BudgetExceededError) Error() string {
+	if e.MaxCost > 0 && e.UsedCost >= e.MaxCost {
+		return fmt.Sprintf("%s budget exceeded: cost %.4f of %.4f", e.Scope, e.UsedCost, e.MaxCost)
+	}
+	return fmt.Sprintf("%s budget exceeded: %d of %d tokens", e.Scope, e.UsedTokens, e.MaxTokens)
+}
+
+// Budget 基于 UsageTracker 的预算：用量由 Provider 记入 tracker，预算只负责判断
+type Budget struct {
+	scope        string
+	usage        *UsageTracker
+	maxTokens    int
+	maxCost      float64
+	degradeRatio float64
+}
+
+// NewBudget 创建预算；maxTokens 与 maxCost 都为 0 时返回 nil（不限制）
+func NewBudget(scope string, usage *UsageTracker, maxTokens int, maxCost float64, degradeRatio float64) *Budget {
+	if maxTokens <= 0 && maxCost <= 0 {
+		return nil
+	}
+	if degradeRatio <= 0 || degradeRatio > 1 {
+		degradeRatio = 0.8
+	}
+	return &Budget{
+		scope:        scope,
+		usage:        usage,
+		maxTokens:    maxTokens,
+		maxCost:      maxCost,
+		degradeRatio: degradeRatio,
+	}
+}
+
+// Level 当前预算状态（按 token 与费用中更紧张的一项）
+func (b *Budget) Level() BudgetLevel {
+	if b == nil {
+		return BudgetOK
+	}
+	ratio := b.ratio()
+	switch {
+	case ratio >= 1:
+		return BudgetExhausted
+	case ratio >= b.degradeRatio:
+		return BudgetDegraded
+	default:
+		return BudgetOK
+	}
+}
+
+// Check 预算用尽时返回 *BudgetExceededError
+func (b *Budget) Check() error {
+	if b.Level() < BudgetExhausted {
+		return nil
+	}
+	total := b.usage.Total()
+	return &BudgetExceededError{
+		Scope:      b.scope,
+		UsedTokens: total.TotalTokens,
+		MaxTokens:  b.maxTokens,
+		UsedCost:   total.Cost,
+		MaxCost:    b.maxCost,
+	}
+}
+
+func (b *Budget) ratio() float64 {
+	total := b.usage.Total()
+	var ratio float64
+	if b.maxTokens > 0 {
+		ratio = float64(total.TotalTokens) / float64(b.maxTokens)
+	}
+	if b.maxCost > 0 {
+		if r := total.Cost / b.maxCost; r > ratio {
+			ratio = r
+		}
+	}
+	return ratio
+}
+
+// ==================== context 传递 ====================
+
+type budgetKey struct{}
+
+// WithBudget 把预算挂到 ctx 上（可叠加，如单轮 + 会话每日）；nil 预算会被忽略
+func WithBudget(ctx context.Context, budgets ...*Budget) context.Context {
+	existing, _ := ctx.Value(budgetKey{}).([]*Budget)
+	merged := append([]*Budget(nil), existing...)
+	for _, b := range budgets {
+		if b != nil {
+			merged = append(merged, b)
+		}
+	}
+	return context.WithValue(ctx, budgetKey{}, merged)
+}
+
+// BudgetLevelFrom ctx 上所有预算中最紧张的状态，上层据此降级
+func BudgetLevelFrom(ctx context.Context) BudgetLevel {
+	level := BudgetOK
+	budgets, _ := ctx.Value(budgetKey{}).([]*Budget)
+	for _, b := range budgets {
+		if l := b.Level(); l > level {
+			level = l
+		}
+	}
+	return level
+}
+
+// CheckBudget 在发起模型调用前检查预算，任一预算用尽即返回 *BudgetExceededError
+func CheckBudget(ctx context.Context) error {
+	budgets, _ := ctx.Value(budgetKey{}).([]*Budget)
+	for _, b := range budgets {
+		if err := b.Check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
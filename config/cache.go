@@ -0,0 +1,543 @@
+package config
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog/log"
+)
+
+// ==================== 响应缓存 ====================
+
+// CacheEntry 缓存条目
+type CacheEntry struct {
+	Key        string     // 完整请求的哈希
+	ContextKey string     // 语义匹配的范围（模型、子系统与工具），只在同一范围内比较
+	Prompt     string     // 语义匹配的文本（见 WithSemanticCache），为空表示只做精确匹配
+	Response   string     // 模型回复
+	ToolCalls  []ToolCall // 工具调用
+	Timestamp  time.Time  // 写入时间（过期判断）
+}
+
+// CacheStats 缓存命中统计
+type CacheStats struct {
+	Size         int     `json:"size"`
+	MaxSize      int     `json:"max_size"`
+	Hits         int     `json:"hits"`
+	SemanticHits int     `json:"semantic_hits"` // 其中语义相似命中的次数
+	Misses       int     `json:"misses"`
+	Evictions    int     `json:"evictions"`
+	HitRate      float64 `json:"hit_rate"`
+	Persistent   bool    `json:"persistent"` // 是否持久化到 SQLite
+}
+
+// ResponseCache 响应缓存：线程安全的 LRU，支持过期、语义相似匹配与 SQLite 持久化
+type ResponseCache struct {
+	mu                sync.Mutex
+	maxSize           int
+	expiration        time.Duration
+	semanticThreshold float64 // 语义匹配文本的相似度阈值，0 表示只做精确匹配
+
+	lru     *list.List               // 最近使用的在前，元素为 *CacheEntry
+	entries map[string]*list.Element // Key -> 元素
+	db      *sql.DB                  // 持久化（可选）
+	now     func() time.Time         // 时钟，测试中替换
+
+	hits, semanticHits, misses, evictions int
+}
+
+// NewResponseCache 创建响应缓存（仅内存）
+func NewResponseCache(maxSize int, expiration time.Duration) *ResponseCache {
+	if maxSize <= 0 {
+		maxSize = 100
+	}
+	return &ResponseCache{
+		maxSize:    maxSize,
+		expiration: expiration,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Configure 热更新容量、过期时间与语义匹配阈值；容量缩小时立即淘汰最久未用的条目
+func (c *ResponseCache) Configure(maxSize int, expiration time.Duration, semanticThreshold float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if maxSize > 0 {
+		c.maxSize = maxSize
+	}
+	c.expiration = expiration
+	c.semanticThreshold = semanticThreshold
+	for c.lru.Len() > c.maxSize {
+		c.evictOldest()
+	}
+}
+
+// Persist 启用 SQLite 持久化：加载未过期的条目，之后的写入与淘汰同步到数据库
+func (c *ResponseCache) Persist(dbPath string) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open cache database: %w", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS response_cache (
+		key TEXT PRIMARY KEY,
+		context_key TEXT NOT NULL,
+		prompt TEXT NOT NULL,
+		response TEXT NOT NULL,
+		tool_calls TEXT,
+		created_at DATETIME NOT NULL,
+		last_used DATETIME NOT NULL
+	)`); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to init cache table: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.db != nil {
+		c.db.Close()
+	}
+	c.db = db
+
+	// 取最近使用的 maxSize 条，按从旧到新的顺序放入 LRU，使最近使用的条目排在前面
+	rows, err := db.Query(`SELECT key, context_key, prompt, response, tool_calls, created_at
+		FROM response_cache ORDER BY last_used DESC LIMIT ?`, c.maxSize)
+	if err != nil {
+		return fmt.Errorf("failed to load cache: %w", err)
+	}
+	defer rows.Close()
+
+	var loaded []*CacheEntry
+	for rows.Next() {
+		var (
+			entry     CacheEntry
+			toolCalls sql.NullString
+		)
+		if err := rows.Scan(&entry.Key, &entry.ContextKey, &entry.Prompt, &entry.Response, &toolCalls, &entry.Timestamp); err != nil {
+			return fmt.Errorf("failed to scan cache entry: %w", err)
+		}
+		if toolCalls.Valid && toolCalls.String != "" {
+			if err := json.Unmarshal([]byte(toolCalls.String), &entry.ToolCalls); err != nil {
+				continue
+			}
+		}
+		if c.expired(&entry) {
+			continue
+		}
+		loaded = append(loaded, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := len(loaded) - 1; i >= 0; i-- {
+		if _, ok := c.entries[loaded[i].Key]; !ok {
+			c.entries[loaded[i].Key] = c.lru.PushFront(loaded[i])
+		}
+	}
+	for c.lru.Len() > c.maxSize {
+		c.evictOldest()
+	}
+
+	// 清理已过期的旧记录
+	if c.expiration > 0 {
+		if _, err := db.Exec(`DELETE FROM response_cache WHERE created_at < ?`, time.Now().Add(-c.expiration)); err != nil {
+			log.Warn().Err(err).Msg("清理过期缓存失败")
+		}
+	}
+	log.Info().Str("path", dbPath).Int("entries", c.lru.Len()).Msg("响应缓存已启用持久化")
+	return nil
+}
+
+// Close 关闭持久化数据库
+func (c *ResponseCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.db == nil {
+		return nil
+	}
+	err := c.db.Close()
+	c.db = nil
+	return err
+}
+
+// Get 按完整请求哈希精确查找
+func (c *ResponseCache) Get(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.lookup(key); ok {
+		c.hits++
+		return entry, true
+	}
+	c.misses++
+	return nil, false
+}
+
+// GetSimilar 精确查找未命中时，在同一范围内按语义匹配文本的相似度查找
+func (c *ResponseCache) GetSimilar(key, contextKey, prompt string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.lookup(key); ok {
+		c.hits++
+		return entry, true
+	}
+
+	if c.semanticThreshold > 0 && prompt != "" {
+		var (
+			best      *list.Element
+			bestScore float64
+		)
+		for e := c.lru.Front(); e != nil; e = e.Next() {
+			entry := e.Value.(*CacheEntry)
+			if entry.ContextKey != contextKey || entry.Prompt == "" || c.expired(entry) {
+				continue
+			}
+			if score := textSimilarity(prompt, entry.Prompt); score >= c.semanticThreshold && score > bestScore {
+				best, bestScore = e, score
+			}
+		}
+		if best != nil {
+			c.lru.MoveToFront(best)
+			c.hits++
+			c.semanticHits++
+			return best.Value.(*CacheEntry), true
+		}
+	}
+
+	c.misses++
+	return nil, false
+}
+
+// Set 写入缓存；已满时淘汰最久未使用的条目
+func (c *ResponseCache) Set(entry *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.Timestamp = c.now()
+	if e, ok := c.entries[entry.Key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+	} else {
+		c.entries[entry.Key] = c.lru.PushFront(entry)
+		for c.lru.Len() > c.maxSize {
+			c.evictOldest()
+		}
+	}
+
+	if c.db != nil {
+		var toolCalls []byte
+		if len(entry.ToolCalls) > 0 {
+			toolCalls, _ = json.Marshal(entry.ToolCalls)
+		}
+		if _, err := c.db.Exec(`INSERT OR REPLACE INTO response_cache
+			(key, context_key, prompt, response, tool_calls, created_at, last_used)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			entry.Key, entry.ContextKey, entry.Prompt, entry.Response, string(toolCalls), entry.Timestamp, entry.Timestamp); err != nil {
+			log.Warn().Err(err).Msg("写入持久化缓存失败")
+		}
+	}
+}
+
+// Stats 命中统计快照
+func (c *ResponseCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{
+		Size:         c.lru.Len(),
+		MaxSize:      c.maxSize,
+		Hits:         c.hits,
+		SemanticHits: c.semanticHits,
+		Misses:       c.misses,
+		Evictions:    c.evictions,
+		Persistent:   c.db != nil,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = float64(c.hits) / float64(total)
+	}
+	return stats
+}
+
+// lookup 精确查找，命中时移到 LRU 最前；过期条目直接删除（调用方持有锁）
+func (c *ResponseCache) lookup(key string) (*CacheEntry, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*CacheEntry)
+	if c.expired(entry) {
+		c.remove(e)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	if c.db != nil {
+		if _, err := c.db.Exec(`UPDATE response_cache SET last_used = ? WHERE key = ?`, time.Now(), key); err != nil {
+			log.Warn().Err(err).Msg("更新持久化缓存失败")
+		}
+	}
+	return entry, true
+}
+
+func (c *ResponseCache) expired(entry *CacheEntry) bool {
+	return c.expiration > 0 && c.now().Sub(entry.Timestamp) > c.expiration
+}
+
+func (c *ResponseCache) evictOldest() {
+	if e := c.lru.Back(); e != nil {
+		c.remove(e)
+		c.evictions++
+	}
+}
+
+func (c *ResponseCache) remove(e *list.Element) {
+	entry := c.lru.Remove(e).(*CacheEntry)
+	delete(c.entries, entry.Key)
+	if c.db != nil {
+		if _, err := c.db.Exec(`DELETE FROM response_cache WHERE key = ?`, entry.Key); err != nil {
+			log.Warn().Err(err).Msg("删除持久化缓存失败")
+		}
+	}
+}
+
+// textSimilarity 按字符二元组（中文友好）计算 Jaccard 相似度
+func textSimilarity(a, b string) float64 {
+	setA, setB := bigrams(a), bigrams(b)
+	if len(setA) == 0 && len(setB) == 0 {
+		return 1
+	}
+	intersection := 0
+	for g := range setA {
+		if setB[g] {
+			intersection++
+		}
+	}
+	union := len(setA) + len(setB) - intersection
+	return float64(intersection) / float64(union)
+}
+
+func bigrams(s string) map[string]bool {
+	runes := []rune(s)
+	set := make(map[string]bool, len(runes))
+	if len(runes) == 1 {
+		set[s] = true
+	}
+	for i := 0; i+1 < len(runes); i++ {
+		set[string(runes[i:i+2])] = true
+	}
+	return set
+}
+
+// ==================== 缓存键 ====================
+
+// cacheKeyRequest 参与缓存键计算的请求内容
+type cacheKeyRequest struct {
+	Identity string                   `json:"identity"` // 模型名与温度（见 CacheIdentifier）
+	Messages []Message                `json:"messages"`
+	Tools    []map[string]interface{} `json:"tools,omitempty"`
+}
+
+// GenerateCacheKey 对模型、温度、消息与工具做 SHA-256（json.Marshal 对 map 按键排序，结果稳定）
+func GenerateCacheKey(identity string, messages []Message, tools []map[string]interface{}) string {
+	data, err := json.Marshal(cacheKeyRequest{Identity: identity, Messages: messages, Tools: tools})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheIdentity 模型名 + 温度，例如 "qwen-flash@0.7"
+func cacheIdentity(model string, temperature float64) string {
+	return model + "@" + strconv.FormatFloat(temperature, 'f', -1, 64)
+}
+
+// ==================== 缓存模型 ====================
+
+type cacheableKey struct{}
+
+// cacheMode 调用的缓存方式
+type cacheMode struct {
+	subject string // 语义匹配的文本，为空表示只做精确匹配
+}
+
+// WithCacheable 标记后续调用的结果可以缓存（主持人决策、自我评估等确定性调用），按完整请求精确匹配
+func WithCacheable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheableKey{}, cacheMode{})
+}
+
+// WithSemanticCache 标记后续调用可以缓存，并允许相似的 subject（如待分类的用户输入）命中同一结果
+// 需要配置 cache.semantic_threshold，否则退化为精确匹配
+func WithSemanticCache(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, cacheableKey{}, cacheMode{subject: subject})
+}
+
+func cacheModeFrom(ctx context.Context) (cacheMode, bool) {
+	mode, ok := ctx.Value(cacheableKey{}).(cacheMode)
+	return mode, ok
+}
+
+// withoutCache 取消 ctx 上的可缓存标记，后续调用不读也不写缓存（结构化输出的修正调用）
+func withoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheableKey{}, nil)
+}
+
+type pendingStoreKey struct{}
+
+// pendingStore 推迟的缓存写入：结构化输出解码并校验通过后才 Commit
+type pendingStore struct {
+	mu    sync.Mutex
+	store func()
+}
+
+// withPendingStore 后续调用的缓存写入改为登记到返回的 pendingStore，由调用方决定是否提交
+func withPendingStore(ctx context.Context) (context.Context, *pendingStore) {
+	p := &pendingStore{}
+	return context.WithValue(ctx, pendingStoreKey{}, p), p
+}
+
+// Commit 提交登记的缓存写入（没有登记或 p 为 nil 时什么也不做）
+func (p *pendingStore) Commit() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	store := p.store
+	p.store = nil
+	p.mu.Unlock()
+	if store != nil {
+		store()
+	}
+}
+
+// CachedModel 带响应缓存的 Provider：只有 WithCacheable / WithSemanticCache 标记的调用才读写缓存
+type CachedModel struct {
+	inner Provider
+	cache *ResponseCache
+}
+
+// NewCachedModel 用响应缓存包装 Provider
+func NewCachedModel(inner Provider, cache *ResponseCache) *CachedModel {
+	return &CachedModel{inner: inner, cache: cache}
+}
+
+// Invoke 可缓存的调用先查缓存，未命中时调用模型并写入缓存
+func (m *CachedModel) Invoke(ctx context.Context, messages []Message, tools []map[string]interface{}) (string, []ToolCall, error) {
+	mode, ok := cacheModeFrom(ctx)
+	if !ok {
+		return m.inner.Invoke(ctx, messages, tools)
+	}
+	key, contextKey, prompt := m.keys(ctx, mode, messages, tools)
+	if entry, ok := m.cache.GetSimilar(key, contextKey, prompt); ok {
+		return entry.Response, entry.ToolCalls, nil
+	}
+
+	content, toolCalls, err := m.inner.Invoke(ctx, messages, tools)
+	if err == nil {
+		m.store(ctx, key, contextKey, prompt, content, toolCalls)
+	}
+	return content, toolCalls, err
+}
+
+// InvokeStream 流式版本：命中缓存时把完整回复作为单个片段回调
+func (m *CachedModel) InvokeStream(ctx context.Context, messages []Message, tools []map[string]interface{}, onChunk StreamHandler) (string, []ToolCall, error) {
+	mode, ok := cacheModeFrom(ctx)
+	if !ok {
+		return InvokeStream(ctx, m.inner, messages, tools, onChunk)
+	}
+	key, contextKey, prompt := m.keys(ctx, mode, messages, tools)
+	if entry, ok := m.cache.GetSimilar(key, contextKey, prompt); ok {
+		if onChunk != nil && entry.Response != "" {
+			onChunk(StreamChunk{Content: entry.Response})
+		}
+		return entry.Response, entry.ToolCalls, nil
+	}
+
+	content, toolCalls, err := InvokeStream(ctx, m.inner, messages, tools, onChunk)
+	if err == nil {
+		m.store(ctx, key, contextKey, prompt, content, toolCalls)
+	}
+	return content, toolCalls, err
+}
+
+// ApplyConfig 热更新被包装的模型
+func (m *CachedModel) ApplyConfig(cfg *Config) {
+	if r, ok := m.inner.(Reloadable); ok {
+		r.ApplyConfig(cfg)
+	}
+}
+
+// GetStats 透传被包装模型的统计
+func (m *CachedModel) GetStats() map[string]interface{} {
+	if r, ok := m.inner.(StatsReporter); ok {
+		return r.GetStats()
+	}
+	return nil
+}
+
+// CacheIdentity 透传被包装模型的标识
+func (m *CachedModel) CacheIdentity(ctx context.Context, messages []Message) string {
+	return identityOf(ctx, m.inner, messages)
+}
+
+// keys 完整请求的键、语义匹配的范围（模型 + 子系统 + 工具）与语义匹配文本
+func (m *CachedModel) keys(ctx context.Context, mode cacheMode, messages []Message, tools []map[string]interface{}) (string, string, string) {
+	identity := identityOf(ctx, m.inner, messages)
+	if format := ResponseFormatFrom(ctx); format != nil && format.JSONSchema != nil {
+		identity += "|" + format.JSONSchema.Name
+	}
+	key := GenerateCacheKey(identity, messages, tools)
+	if mode.subject == "" {
+		return key, key, ""
+	}
+	return key, GenerateCacheKey(identity+"|"+UsageScopeFrom(ctx), nil, tools), mode.subject
+}
+
+// store 写入缓存；兜底消息不缓存。ctx 上有 pendingStore 时只登记，由调用方校验后提交
+func (m *CachedModel) store(ctx context.Context, key, contextKey, prompt, content string, toolCalls []ToolCall) {
+	if key == "" || isFallback(m.inner, content) {
+		return
+	}
+	entry := &CacheEntry{
+		Key:        key,
+		ContextKey: contextKey,
+		Prompt:     prompt,
+		Response:   content,
+		ToolCalls:  toolCalls,
+	}
+	if p, ok := ctx.Value(pendingStoreKey{}).(*pendingStore); ok {
+		p.mu.Lock()
+		p.store = func() { m.cache.Set(entry) }
+		p.mu.Unlock()
+		return
+	}
+	m.cache.Set(entry)
+}
+
+// identityOf Provider 的缓存标识，不支持时为空（仍按消息与工具区分）
+func identityOf(ctx context.Context, p Provider, messages []Message) string {
+	if ci, ok := p.(CacheIdentifier); ok {
+		return ci.CacheIdentity(ctx, messages)
+	}
+	return ""
+}
+
+// isFallback 回复是否为容错模型的兜底消息
+func isFallback(p Provider, content string) bool {
+	if fr, ok := p.(FallbackReporter); ok {
+		return fr.IsFallback(content)
+	}
+	return false
+}
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheLRU(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxSize     int
+		ops         []string // set:<key> / get:<key>
+		wantKeys    []string // 仍在缓存中的键
+		wantMissing []string
+		wantEvicted int
+	}{
+		{
+			name:        "evicts least recently set",
+			maxSize:     2,
+			ops:         []string{"set:a", "set:b", "set:c"},
+			wantKeys:    []string{"b", "c"},
+			wantMissing: []string{"a"},
+			wantEvicted: 1,
+		},
+		{
+			name:        "get refreshes recency",
+			maxSize:     2,
+			ops:         []string{"set:a", "set:b", "get:a", "set:c"},
+			wantKeys:    []string{"a", "c"},
+			wantMissing: []string{"b"},
+			wantEvicted: 1,
+		},
+		{
+			name:        "overwrite refreshes recency",
+			maxSize:     2,
+			ops:         []string{"set:a", "set:b", "set:a", "set:c"},
+			wantKeys:    []string{"a", "c"},
+			wantMissing: []string{"b"},
+			wantEvicted: 1,
+		},
+		{
+			name:        "within capacity nothing is evicted",
+			maxSize:     3,
+			ops:         []string{"set:a", "set:b", "set:c"},
+			wantKeys:    []string{"a", "b", "c"},
+			wantEvicted: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewResponseCache(tt.maxSize, 0)
+			for _, op := range tt.ops {
+				switch key := op[4:]; op[:4] {
+				case "set:":
+					c.Set(&CacheEntry{Key: key, Response: "reply " + key})
+				case "get:":
+					if _, ok := c.Get(key); !ok {
+						t.Fatalf("%s: miss", op)
+					}
+				}
+			}
+			for _, key := range tt.wantKeys {
+				if entry, ok := c.Get(key); !ok || entry.Response != "reply "+key {
+					t.Errorf("Get(%q) = %v, %v; want cached", key, entry, ok)
+				}
+			}
+			for _, key := range tt.wantMissing {
+				if _, ok := c.Get(key); ok {
+					t.Errorf("Get(%q) hit, want evicted", key)
+				}
+			}
+			if stats := c.Stats(); stats.Evictions != tt.wantEvicted || stats.Size != len(tt.wantKeys) {
+				t.Errorf("evictions/size = %d/%d, want %d/%d", stats.Evictions, stats.Size, tt.wantEvicted, len(tt.wantKeys))
+			}
+		})
+	}
+}
+
+func TestResponseCacheConfigureShrinks(t *testing.T) {
+	c := NewResponseCache(3, 0)
+	for _, key := range []string{"a", "b", "c"} {
+		c.Set(&CacheEntry{Key: key})
+	}
+	c.Configure(1, 0, 0)
+
+	if _, ok := c.Get("c"); !ok {
+		t.Error("most recent entry was evicted")
+	}
+	if stats := c.Stats(); stats.Size != 1 || stats.Evictions != 2 {
+		t.Errorf("size/evictions = %d/%d, want 1/2", stats.Size, stats.Evictions)
+	}
+}
+
+func TestResponseCacheExpiration(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewResponseCache(10, time.Minute)
+	c.now = clock.now
+	c.Set(&CacheEntry{Key: "a"})
+
+	clock.advance(time.Minute)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("entry expired at exactly the expiration time")
+	}
+	clock.advance(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expired entry was returned")
+	}
+	if stats := c.Stats(); stats.Size != 0 {
+		t.Errorf("size = %d, want the expired entry removed", stats.Size)
+	}
+}
+
+func TestResponseCacheSemanticMatch(t *testing.T) {
+	const stored = "今天练习好累啊"
+
+	tests := []struct {
+		name       string
+		threshold  float64
+		contextKey string
+		prompt     string
+		wantHit    bool
+	}{
+		{name: "similar prompt above threshold", threshold: 0.8, contextKey: "emotion", prompt: "今天练习好累", wantHit: true}, // 5/6
+		{name: "similar prompt below threshold", threshold: 0.8, contextKey: "emotion", prompt: "今天练习好难"},                // 4/7
+		{name: "lower threshold accepts it", threshold: 0.5, contextKey: "emotion", prompt: "今天练习好难", wantHit: true},
+		{name: "unrelated prompt", threshold: 0.5, contextKey: "emotion", prompt: "明天去看海"},
+		{name: "different scope never matches", threshold: 0.5, contextKey: "judge", prompt: stored},
+		{name: "threshold 0 disables semantic match", threshold: 0, contextKey: "emotion", prompt: stored},
+		{name: "empty prompt is exact only", threshold: 0.5, contextKey: "emotion", prompt: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewResponseCache(10, 0)
+			c.Configure(10, 0, tt.threshold)
+			c.Set(&CacheEntry{Key: "stored", ContextKey: "emotion", Prompt: stored, Response: "pain"})
+
+			entry, ok := c.GetSimilar("other", tt.contextKey, tt.prompt)
+			if ok != tt.wantHit {
+				t.Fatalf("hit = %v, want %v (similarity %.2f)", ok, tt.wantHit, textSimilarity(stored, tt.prompt))
+			}
+			stats := c.Stats()
+			if tt.wantHit {
+				if entry.Response != "pain" {
+					t.Errorf("response = %q", entry.Response)
+				}
+				if stats.SemanticHits != 1 || stats.Hits != 1 {
+					t.Errorf("hits/semantic = %d/%d, want 1/1", stats.Hits, stats.SemanticHits)
+				}
+			} else if stats.Misses != 1 {
+				t.Errorf("misses = %d, want 1", stats.Misses)
+			}
+		})
+	}
+}
+
+func TestResponseCacheSemanticPrefersBestMatch(t *testing.T) {
+	c := NewResponseCache(10, 0)
+	c.Configure(10, 0, 0.5)
+	c.Set(&CacheEntry{Key: "close", ContextKey: "s", Prompt: "今天练习好累", Response: "close"})
+	c.Set(&CacheEntry{Key: "closer", ContextKey: "s", Prompt: "今天练习好累啊", Response: "closer"})
+	c.Set(&CacheEntry{Key: "far", ContextKey: "s", Prompt: "今天练习", Response: "far"})
+
+	entry, ok := c.GetSimilar("none", "s", "今天练习好累啊")
+	if !ok || entry.Response != "closer" {
+		t.Fatalf("got %v, %v; want the most similar entry", entry, ok)
+	}
+}
+
+func TestResponseCachePersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	c := NewResponseCache(2, time.Hour)
+	if err := c.Persist(path); err != nil {
+		t.Fatal(err)
+	}
+	c.Set(&CacheEntry{Key: "a", Response: "A", ToolCalls: []ToolCall{{ID: "call_1"}}})
+	c.Set(&CacheEntry{Key: "b", Response: "B"})
+	c.Set(&CacheEntry{Key: "c", Response: "C"}) // 淘汰 a，同时从数据库删除
+	c.Close()
+
+	reloaded := NewResponseCache(2, time.Hour)
+	if err := reloaded.Persist(path); err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Close()
+	if _, ok := reloaded.Get("a"); ok {
+		t.Error("evicted entry was reloaded")
+	}
+	for key, want := range map[string]string{"b": "B", "c": "C"} {
+		if entry, ok := reloaded.Get(key); !ok || entry.Response != want {
+			t.Errorf("Get(%q) = %v, %v; want %q", key, entry, ok, want)
+		}
+	}
+}
+
+// countingProvider 按调用次数编号回复的假模型
+type countingProvider struct {
+	calls    int
+	fallback string
+}
+
+func (p *countingProvider) Invoke(ctx context.Context, messages []Message, tools []map[string]interface{}) (string, []ToolCall, error) {
+	p.calls++
+	if p.fallback != "" {
+		return p.fallback, nil, nil
+	}
+	return fmt.Sprintf("reply %d", p.calls), nil, nil
+}
+
+func (p *countingProvider) IsFallback(content string) bool {
+	return p.fallback != "" && content == p.fallback
+}
+
+func TestCachedModel(t *testing.T) {
+	messages := []Message{{Role: "user", Content: "你好"}}
+
+	tests := []struct {
+		name      string
+		ctx       func(context.Context) context.Context
+		fallback  string
+		wantCalls int
+		wantSize  int
+	}{
+		{
+			name:      "unmarked calls bypass the cache",
+			ctx:       func(ctx context.Context) context.Context { return ctx },
+			wantCalls: 2,
+			wantSize:  0,
+		},
+		{
+			name:      "cacheable calls hit on the second request",
+			ctx:       WithCacheable,
+			wantCalls: 1,
+			wantSize:  1,
+		},
+		{
+			name:      "fallback replies are not cached",
+			ctx:       WithCacheable,
+			fallback:  "兜底",
+			wantCalls: 2,
+			wantSize:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &countingProvider{fallback: tt.fallback}
+			cache := NewResponseCache(10, 0)
+			m := NewCachedModel(inner, cache)
+			ctx := tt.ctx(context.Background())
+
+			first, _, _ := m.Invoke(ctx, messages, nil)
+			second, _, _ := m.Invoke(ctx, messages, nil)
+			if inner.calls != tt.wantCalls {
+				t.Errorf("inner calls = %d, want %d", inner.calls, tt.wantCalls)
+			}
+			if tt.wantCalls == 1 && first != second {
+				t.Errorf("cached reply %q != %q", second, first)
+			}
+			if size := cache.Stats().Size; size != tt.wantSize {
+				t.Errorf("cache size = %d, want %d", size, tt.wantSize)
+			}
+		})
+	}
+}
@@ -1,6 +1,14 @@
 package config
 
 import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -11,21 +19,351 @@ type Config struct {
 	Token       string  `yaml:"token" mapstructure:"token"`
 	ModelName   string  `yaml:"model_name" mapstructure:"model_name"`
 	Temperature float64 `yaml:"temperature" mapstructure:"temperature"`
+
+	// StructuredOutput 结构化输出（主持人决策、反思、自我评估）使用的 response_format：
+	// json_schema（默认）/ json_object / off，接口不支持 JSON schema 时降级
+	StructuredOutput string `yaml:"structured_output" mapstructure:"structured_output"`
+
+	MultiAPI MultiAPIConfig `yaml:"multi_api" mapstructure:"multi_api"` // 多 API 源（容错）
+	Server   ServerConfig   `yaml:"server" mapstructure:"server"`       // 服务器
+	Cache    CacheConfig    `yaml:"cache" mapstructure:"cache"`         // 响应缓存
+	Sessions SessionConfig  `yaml:"sessions" mapstructure:"sessions"`   // 会话存储
+	Debate   DebateConfig   `yaml:"debate" mapstructure:"debate"`       // 辩论
+	Emotion  EmotionConfig  `yaml:"emotion" mapstructure:"emotion"`     // 情绪分析
+	Pricing  PricingConfig  `yaml:"pricing" mapstructure:"pricing"`     // 模型价格表（费用统计）
+	Budget   BudgetConfig   `yaml:"budget" mapstructure:"budget"`       // token / 费用预算
+	Routing  RoutingConfig  `yaml:"routing" mapstructure:"routing"`     // 轻 / 重模型路由
+	Tracing  TracingConfig  `yaml:"tracing" mapstructure:"tracing"`     // 链路追踪
+}
+
+// ServerConfig 服务器配置
+type ServerConfig struct {
+	Port           int  `yaml:"port" mapstructure:"port"`
+	CORSEnabled    bool `yaml:"cors_enabled" mapstructure:"cors_enabled"`
+	RequestTimeout int  `yaml:"request_timeout" mapstructure:"request_timeout"` // 单个请求的处理时限（秒），0 表示不限制
+}
+
+// CacheConfig 响应缓存配置
+type CacheConfig struct {
+	MaxSize           int     `yaml:"max_size" mapstructure:"max_size"`
+	ExpirationMinutes int     `yaml:"expiration_minutes" mapstructure:"expiration_minutes"`
+	Path              string  `yaml:"path" mapstructure:"path"`                             // SQLite 持久化文件，为空则只缓存在内存中（修改需重启）
+	SemanticThreshold float64 `yaml:"semantic_threshold" mapstructure:"semantic_threshold"` // 相似问题命中缓存的阈值（0~1），0 表示只做精确匹配
+}
+
+// SessionConfig 会话存储配置
+type SessionConfig struct {
+	Path           string `yaml:"path" mapstructure:"path"`                         // SQLite 文件，":memory:" 表示只保存在内存中（修改需重启）
+	IdleTTLMinutes int    `yaml:"idle_ttl_minutes" mapstructure:"idle_ttl_minutes"` // 会话空闲多久后清除（分钟），0 表示永不清除
+}
+
+// DebateConfig 辩论配置
+type DebateConfig struct {
+	FormatsPath string `yaml:"formats_path" mapstructure:"formats_path"` // 自定义赛制文件（YAML），为空只使用内置赛制（修改需重启）
+}
+
+// EmotionConfig 情绪分析配置
+type EmotionConfig struct {
+	EnableAIAnalysis bool `yaml:"enable_ai_analysis" mapstructure:"enable_ai_analysis"` // 关键词无法判断时是否调用 AI 深度分析
 }
 
+// 环境变量前缀：MYGO_TOKEN、MYGO_SERVER_PORT、MYGO_SOURCES_<NAME>_TOKEN 等
+const envPrefix = "MYGO"
+
+// localConfigName 本地覆盖文件名，与 config.yaml 同目录，不提交到仓库
+const localConfigName = "config.local.yaml"
+
+var (
+	// reloadMu 串行化配置的加载与热更新
+	reloadMu sync.Mutex
+	// envPattern 匹配 ${VAR} 与 ${VAR:-default}
+	envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+)
+
+// LoadConfig 加载配置，优先级从低到高：
+// 默认值 -> config.yaml -> config.local.yaml -> 环境变量
+// YAML 中的 ${ENV} / ${ENV:-default} 会在解析前替换为环境变量的值
 func LoadConfig() (*Config, error) {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
 	viper.AddConfigPath("./config")
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+	setDefaults()
+	// 先定位配置文件
 	if err := viper.ReadInConfig(); err != nil {
 		log.Error().Err(err).Msg("Error reading config file")
 		return nil, errors.New("Error reading config file: " + err.Error())
 	}
+
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return readConfig()
+}
+
+// WatchConfig 监听 config.yaml 与 config.local.yaml 的变化，重新加载并校验通过后回调 onChange
+// 新配置不合法时保留旧配置，只记录错误；本地覆盖文件在启动后才创建或被删除时同样触发重载
+func WatchConfig(onChange func(cfg *Config)) {
+	reload := func(file string) {
+		reloadMu.Lock()
+		cfg, err := readConfig()
+		reloadMu.Unlock()
+		if err != nil {
+			log.Error().Err(err).Str("file", file).Msg("配置热更新失败，继续使用旧配置")
+			return
+		}
+		log.Info().Str("file", file).Msg("配置已热更新")
+		onChange(cfg)
+	}
+
+	if _, err := watchFiles([]string{viper.ConfigFileUsed(), localConfigPath()}, reload); err != nil {
+		log.Error().Err(err).Msg("监听配置文件失败，配置不会热更新")
+	}
+}
+
+// watchFiles 监听 paths 所在的目录，其中任一文件被创建、修改、删除或改名时回调 onEvent；
+// 监听目录而不是文件本身，启动后才创建的文件和编辑器保存时的原子替换都能被察觉
+func watchFiles(paths []string, onEvent func(file string)) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(paths))
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		wanted[path] = true
+		dirs[filepath.Dir(path)] = true
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if wanted[filepath.Clean(event.Name)] &&
+					event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+					onEvent(event.Name)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error().Err(err).Msg("监听配置文件出错")
+			}
+		}
+	}()
+	return watcher, nil
+}
+
+// readConfig 按层读取并解析配置（调用方需持有 reloadMu）
+func readConfig() (*Config, error) {
+	if err := readInterpolated(viper.ConfigFileUsed(), viper.ReadConfig); err != nil {
+		log.Error().Err(err).Msg("Error reading config file")
+		return nil, errors.New("Error reading config file: " + err.Error())
+	}
+	if local := localConfigPath(); fileExists(local) {
+		if err := readInterpolated(local, viper.MergeConfig); err != nil {
+			log.Error().Err(err).Str("file", local).Msg("Error merging local config file")
+			return nil, errors.New("Error merging local config file: " + err.Error())
+		}
+	}
+
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
 		log.Error().Err(err).Msg("Error unmarshalling config file")
 		return nil, errors.New("Error unmarshalling config file: " + err.Error())
 	}
+	applySourceEnvOverrides(&config.MultiAPI)
+	if err := config.Validate(); err != nil {
+		log.Error().Err(err).Msg("Invalid config file")
+		return nil, errors.New("Invalid config file: " + err.Error())
+	}
 	return &config, nil
 }
+
+// readInterpolated 读取文件、替换 ${ENV} 后交给 viper（ReadConfig 或 MergeConfig）
+func readInterpolated(path string, read func(in io.Reader) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return read(strings.NewReader(expandEnv(string(data))))
+}
+
+// expandEnv 替换 ${VAR} 与 ${VAR:-default}，未设置且无默认值时替换为空串
+func expandEnv(s string) string {
+	return envPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := envPattern.FindStringSubmatch(m)
+		if v, ok := os.LookupEnv(sub[1]); ok && v != "" {
+			return v
+		}
+		return sub[2]
+	})
+}
+
+// applySourceEnvOverrides 用 MYGO_SOURCES_<NAME>_TOKEN / _BASE_URL / _MODEL_NAME 覆盖对应 API 源
+// NAME 为源名称转大写，非字母数字字符替换为下划线，如 dashscope -> MYGO_SOURCES_DASHSCOPE_TOKEN
+func applySourceEnvOverrides(cfg *MultiAPIConfig) {
+	for i := range cfg.Sources {
+		src := &cfg.Sources[i]
+		prefix := envPrefix + "_SOURCES_" + envName(src.Name) + "_"
+		if v := os.Getenv(prefix + "TOKEN"); v != "" {
+			src.Token = v
+		}
+		if v := os.Getenv(prefix + "BASE_URL"); v != "" {
+			src.BaseURL = v
+		}
+		if v := os.Getenv(prefix + "MODEL_NAME"); v != "" {
+			src.ModelName = v
+		}
+	}
+}
+
+// envName 把名称转换为环境变量片段
+func envName(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+// localConfigPath 本地覆盖文件路径（与主配置文件同目录）
+func localConfigPath() string {
+	return filepath.Join(filepath.Dir(viper.ConfigFileUsed()), localConfigName)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// setDefaults 设置各配置项的默认值（配置文件缺省时生效）
+func setDefaults() {
+	viper.SetDefault("temperature", 0.7)
+	viper.SetDefault("structured_output", StructuredJSONSchema)
+	viper.SetDefault("multi_api.circuit_breaker.failure_threshold", 3)
+	viper.SetDefault("multi_api.circuit_breaker.cool_down", 30)
+	viper.SetDefault("multi_api.circuit_breaker.half_open_max", 1)
+	viper.SetDefault("multi_api.hedge.delay_ms", 2000)
+	viper.SetDefault("server.port", 8080)
+	viper.SetDefault("server.cors_enabled", true)
+	viper.SetDefault("server.request_timeout", 300)
+	viper.SetDefault("cache.max_size", 100)
+	viper.SetDefault("cache.expiration_minutes", 30)
+	viper.SetDefault("sessions.path", "./sessions.db")
+	viper.SetDefault("sessions.idle_ttl_minutes", 7*24*60)
+	viper.SetDefault("emotion.enable_ai_analysis", true)
+	viper.SetDefault("pricing.currency", "CNY")
+	viper.SetDefault("budget.degrade_ratio", 0.8)
+	viper.SetDefault("tracing.exporter", TraceExporterJSONL)
+	viper.SetDefault("tracing.path", "./traces.jsonl")
+	viper.SetDefault("tracing.service_name", "mygo-chat")
+	viper.SetDefault("tracing.max_text_chars", 500)
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	if c.BaseURL == "" && len(c.MultiAPI.Sources) == 0 {
+		return errors.New("base_url or multi_api.sources is required")
+	}
+	if c.BaseURL != "" && c.ModelName == "" {
+		return errors.New("model_name is required when base_url is set")
+	}
+	if c.Temperature < 0 || c.Temperature > 2 {
+		return errors.Errorf("temperature must be between 0 and 2, got %v", c.Temperature)
+	}
+	switch c.StructuredOutput {
+	case StructuredJSONSchema, StructuredJSONObject, StructuredOff:
+	default:
+		return errors.Errorf("structured_output must be json_schema, json_object or off, got %q", c.StructuredOutput)
+	}
+
+	names := make(map[string]bool)
+	for i, s := range c.MultiAPI.Sources {
+		switch {
+		case s.Name == "":
+			return errors.Errorf("multi_api.sources[%d]: name is required", i)
+		case names[s.Name]:
+			return errors.Errorf("multi_api.sources[%d]: duplicate name %q", i, s.Name)
+		case s.BaseURL == "":
+			return errors.Errorf("multi_api.sources[%d] (%s): base_url is required", i, s.Name)
+		case s.ModelName == "":
+			return errors.Errorf("multi_api.sources[%d] (%s): model_name is required", i, s.Name)
+		case s.Timeout < 0:
+			return errors.Errorf("multi_api.sources[%d] (%s): timeout must not be negative", i, s.Name)
+		case s.MaxRetries < 0:
+			return errors.Errorf("multi_api.sources[%d] (%s): max_retries must not be negative", i, s.Name)
+		}
+		names[s.Name] = true
+	}
+	if cb := c.MultiAPI.CircuitBreaker; cb.FailureThreshold < 0 || cb.CoolDown < 0 || cb.HalfOpenMax < 0 {
+		return errors.New("multi_api.circuit_breaker values must not be negative")
+	}
+	if c.Routing.Enabled && c.Routing.LightModel == "" {
+		return errors.New("routing.light_model is required when routing is enabled")
+	}
+	if _, err := c.Routing.policies(); err != nil {
+		return err
+	}
+	if c.MultiAPI.Hedge.DelayMs < 0 {
+		return errors.Errorf("multi_api.hedge.delay_ms must not be negative, got %d", c.MultiAPI.Hedge.DelayMs)
+	}
+
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		return errors.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	if c.Server.RequestTimeout < 0 {
+		return errors.Errorf("server.request_timeout must not be negative, got %d", c.Server.RequestTimeout)
+	}
+	if c.Cache.MaxSize <= 0 {
+		return errors.Errorf("cache.max_size must be positive, got %d", c.Cache.MaxSize)
+	}
+	if c.Cache.ExpirationMinutes <= 0 {
+		return errors.Errorf("cache.expiration_minutes must be positive, got %d", c.Cache.ExpirationMinutes)
+	}
+	if c.Cache.SemanticThreshold < 0 || c.Cache.SemanticThreshold > 1 {
+		return errors.Errorf("cache.semantic_threshold must be between 0 and 1, got %v", c.Cache.SemanticThreshold)
+	}
+	if c.Sessions.Path == "" {
+		return errors.New("sessions.path is required")
+	}
+	if c.Sessions.IdleTTLMinutes < 0 {
+		return errors.Errorf("sessions.idle_ttl_minutes must not be negative, got %d", c.Sessions.IdleTTLMinutes)
+	}
+	if b := c.Budget; b.TurnTokens < 0 || b.SessionDailyTokens < 0 || b.DebateTokens < 0 || b.SessionDailyCost < 0 || b.DebateCost < 0 {
+		return errors.New("budget limits must not be negative")
+	}
+	if c.Budget.DegradeRatio <= 0 || c.Budget.DegradeRatio > 1 {
+		return errors.Errorf("budget.degrade_ratio must be in (0, 1], got %v", c.Budget.DegradeRatio)
+	}
+	switch c.Tracing.Exporter {
+	case TraceExporterJSONL, TraceExporterOTLP:
+	default:
+		return errors.Errorf("tracing.exporter must be jsonl or otlp, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.Enabled && c.Tracing.Exporter == TraceExporterJSONL && c.Tracing.Path == "" {
+		return errors.New("tracing.path is required for the jsonl exporter")
+	}
+	for model, price := range c.Pricing.Models {
+		if price.Input < 0 || price.Output < 0 {
+			return errors.Errorf("pricing.models.%s: price must not be negative", model)
+		}
+	}
+	return nil
+}
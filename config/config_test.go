@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  bool // 开始监听前本地覆盖文件已存在
+		change    func(main, local, other string) error
+		wantEvent bool
+	}{
+		{
+			name:      "local file created after startup",
+			change:    func(main, local, other string) error { return os.WriteFile(local, []byte("temperature: 0.3\n"), 0o644) },
+			wantEvent: true,
+		},
+		{
+			name:      "existing local file modified",
+			existing:  true,
+			change:    func(main, local, other string) error { return os.WriteFile(local, []byte("temperature: 0.5\n"), 0o644) },
+			wantEvent: true,
+		},
+		{
+			name:      "local file removed",
+			existing:  true,
+			change:    func(main, local, other string) error { return os.Remove(local) },
+			wantEvent: true,
+		},
+		{
+			// 编辑器保存时先写临时文件再改名覆盖
+			name: "main file replaced atomically",
+			change: func(main, local, other string) error {
+				if err := os.WriteFile(other, []byte("temperature: 0.9\n"), 0o644); err != nil {
+					return err
+				}
+				return os.Rename(other, main)
+			},
+			wantEvent: true,
+		},
+		{
+			name:   "unrelated file in the same directory",
+			change: func(main, local, other string) error { return os.WriteFile(other, []byte("x"), 0o644) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			main := filepath.Join(dir, "config.yaml")
+			local := filepath.Join(dir, localConfigName)
+			other := filepath.Join(dir, "notes.txt")
+			if err := os.WriteFile(main, []byte("temperature: 0.7\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.existing {
+				if err := os.WriteFile(local, []byte("temperature: 0.1\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			events := make(chan string, 16)
+			watcher, err := watchFiles([]string{main, local}, func(file string) { events <- file })
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer watcher.Close()
+
+			if err := tt.change(main, local, other); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case file := <-events:
+				if !tt.wantEvent {
+					t.Fatalf("unexpected reload for %s", file)
+				}
+				if file != main && file != local {
+					t.Errorf("reload for %s, want a config file", file)
+				}
+			case <-time.After(500 * time.Millisecond):
+				if tt.wantEvent {
+					t.Fatal("no reload after the change")
+				}
+			}
+		})
+	}
+}
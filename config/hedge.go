@@ -0,0 +1,191 @@
+package config
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ==================== 对冲请求 ====================
+
+// HedgeConfig 对冲请求配置
+// 当前源在 DelayMs 内没有答复时，把同一请求发给下一个源，先成功的胜出，其余取消
+type HedgeConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	DelayMs int  `yaml:"delay_ms" mapstructure:"delay_ms"` // 发起对冲前等待的时间（毫秒）
+}
+
+// hedgeDelay 对冲延迟，未启用时返回 0
+func (c HedgeConfig) hedgeDelay() time.Duration {
+	if !c.Enabled {
+		return 0
+	}
+	if c.DelayMs <= 0 {
+		return 2 * time.Second
+	}
+	return time.Duration(c.DelayMs) * time.Millisecond
+}
+
+// sourceCall 对单个源发起一次调用；流式调用在输出片段前需先通过 claim 抢占胜出权
+type sourceCall func(ctx context.Context, source APISource, claim func() bool) (string, []ToolCall, error)
+
+// hedgeResult 单个源的调用结果
+type hedgeResult struct {
+	idx       int
+	source    APISource
+	content   string
+	toolCalls []ToolCall
+	err       error
+}
+
+// hedgeRace 一次对冲调用中各个源的竞速状态
+type hedgeRace struct {
+	mu      sync.Mutex
+	winner  int // 最先输出片段的源，-1 表示尚未决出
+	cancels []context.CancelFunc
+}
+
+// claim 第 idx 个源抢占胜出权，成功时取消其余源
+func (r *hedgeRace) claim(idx int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.winner == -1 {
+		r.winner = idx
+		for i, cancel := range r.cancels {
+			if i != idx {
+				cancel()
+			}
+		}
+	}
+	return r.winner == idx
+}
+
+func (r *hedgeRace) currentWinner() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.winner
+}
+
+func (r *hedgeRace) add(cancel context.CancelFunc) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cancels = append(r.cancels, cancel)
+	return len(r.cancels) - 1
+}
+
+// invokeHedged 对冲调用：先调用第一个可用的源，delay 内没有答复就并发调用下一个源；
+// 某个源失败时立即启动下一个源。ok 为 false 表示所有源都失败，由调用方返回兜底消息
+func (m *FaultTolerantModel) invokeHedged(ctx context.Context, sources []APISource, delay time.Duration, call sourceCall) (content string, toolCalls []ToolCall, ok bool, err error) {
+	raceCtx, cancelAll := context.WithCancel(ctx)
+	defer cancelAll()
+
+	race := &hedgeRace{winner: -1}
+	results := make(chan hedgeResult, len(sources))
+	next, inflight := 0, 0
+
+	// launch 启动下一个未熔断的源，返回其序号；没有可用的源时返回 -1
+	launch := func(hedge bool) int {
+		for next < len(sources) {
+			source := sources[next]
+			next++
+			breaker := m.breaker(source.Name)
+			if !breaker.Allow() {
+				log.Debug().Str("source", source.Name).Msg("API 源熔断中，跳过")
+				continue
+			}
+			if hedge {
+				breaker.OnHedge()
+				log.Info().Str("source", source.Name).Dur("delay", delay).Msg("主源响应慢，发起对冲请求")
+			}
+
+			attemptCtx, cancel := context.WithCancel(raceCtx)
+			idx := race.add(cancel)
+			inflight++
+			go func() {
+				defer cancel()
+				start := time.Now()
+				var firstChunk time.Duration
+				c, calls, err := call(attemptCtx, source, func() bool {
+					won := race.claim(idx)
+					if won && firstChunk == 0 {
+						firstChunk = time.Since(start)
+					}
+					return won
+				})
+				latency := firstChunk
+				if latency == 0 {
+					latency = time.Since(start)
+				}
+				switch {
+				case err == nil:
+					breaker.OnSuccess(latency)
+				case attemptCtx.Err() != nil:
+					breaker.OnCancel()
+				default:
+					breaker.OnFailure(err)
+				}
+				results <- hedgeResult{idx: idx, source: source, content: c, toolCalls: calls, err: err}
+			}()
+			return idx
+		}
+		return -1
+	}
+
+	if launch(false) == -1 {
+		log.Error().Msg("所有 API 源都在熔断中，返回兜底消息")
+		return "", nil, false, nil
+	}
+	hedged := make(map[int]bool)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for inflight > 0 {
+		select {
+		case <-ctx.Done():
+			return "", nil, false, ctx.Err()
+
+		case <-timer.C:
+			// 已有源开始输出时不再对冲
+			if race.currentWinner() == -1 {
+				if idx := launch(true); idx != -1 {
+					hedged[idx] = true
+				}
+			}
+			timer.Reset(delay)
+
+		case r := <-results:
+			inflight--
+			winner := race.currentWinner()
+			switch {
+			case r.err == nil && (winner == -1 || winner == r.idx):
+				if hedged[r.idx] {
+					m.breaker(r.source.Name).OnHedgeWin()
+				}
+				return r.content, r.toolCalls, true, nil
+			case winner == r.idx:
+				// 胜出的源输出中途失败，已输出的内容无法撤回
+				if ctx.Err() != nil {
+					return r.content, r.toolCalls, true, ctx.Err()
+				}
+				log.Error().Str("source", r.source.Name).Err(r.err).Msg("流式输出中途失败")
+				return r.content, r.toolCalls, true, r.err
+			case winner != -1:
+				// 被取消的落败者
+				continue
+			}
+
+			if ctx.Err() != nil {
+				return "", nil, false, ctx.Err()
+			}
+			log.Warn().Str("source", r.source.Name).Err(r.err).Msg("API 调用失败，尝试下一个源")
+			lastErr = r.err
+			launch(false)
+		}
+	}
+
+	log.Error().Err(lastErr).Msg("所有 API 源都失败，返回兜底消息")
+	return "", nil, false, nil
+}
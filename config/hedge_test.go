@@ -0,0 +1,273 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSource 对冲测试中单个源的行为
+type fakeSource func(ctx context.Context, claim func() bool) (string, error)
+
+// answerAfter 等待 d 后成功返回（被取消时返回 ctx 的错误）
+func answerAfter(d time.Duration, content string) fakeSource {
+	return func(ctx context.Context, _ func() bool) (string, error) {
+		select {
+		case <-time.After(d):
+			return content, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// streamAfter 等待 d 后输出第一个片段，抢占失败时视为被取消
+func streamAfter(d time.Duration, content string) fakeSource {
+	return func(ctx context.Context, claim func() bool) (string, error) {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		if !claim() {
+			return "", context.Canceled
+		}
+		return content, nil
+	}
+}
+
+func failAfter(d time.Duration) fakeSource {
+	return func(ctx context.Context, _ func() bool) (string, error) {
+		select {
+		case <-time.After(d):
+			return "", &APIError{StatusCode: 500, Body: "boom"}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// hang 一直等到被取消
+func hang(ctx context.Context, _ func() bool) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestInvokeHedged(t *testing.T) {
+	const delay = 50 * time.Millisecond
+
+	tests := []struct {
+		name          string
+		sources       []fakeSource
+		wantContent   string
+		wantOK        bool
+		wantCalled    []bool
+		wantCancelled []bool // 调用结束时 ctx 已被取消（落败者）
+		wantHedges    []int
+		wantHedgeWins []int
+		wantFailures  []int
+	}{
+		{
+			name:          "primary answers before the hedge delay",
+			sources:       []fakeSource{answerAfter(0, "primary"), answerAfter(0, "backup")},
+			wantContent:   "primary",
+			wantOK:        true,
+			wantCalled:    []bool{true, false},
+			wantCancelled: []bool{false, false},
+			wantHedges:    []int{0, 0},
+			wantHedgeWins: []int{0, 0},
+			wantFailures:  []int{0, 0},
+		},
+		{
+			name:          "hedge wins and the slow primary is cancelled",
+			sources:       []fakeSource{hang, answerAfter(0, "backup")},
+			wantContent:   "backup",
+			wantOK:        true,
+			wantCalled:    []bool{true, true},
+			wantCancelled: []bool{true, false},
+			wantHedges:    []int{0, 1},
+			wantHedgeWins: []int{0, 1},
+			wantFailures:  []int{0, 0},
+		},
+		{
+			name:          "slow primary still wins over a slower hedge",
+			sources:       []fakeSource{answerAfter(2*delay, "primary"), hang},
+			wantContent:   "primary",
+			wantOK:        true,
+			wantCalled:    []bool{true, true},
+			wantCancelled: []bool{false, true},
+			wantHedges:    []int{0, 1},
+			wantHedgeWins: []int{0, 0},
+			wantFailures:  []int{0, 0},
+		},
+		{
+			name:          "first streamed chunk wins the race",
+			sources:       []fakeSource{streamAfter(delay*3/2, "primary"), hang, hang},
+			wantContent:   "primary",
+			wantOK:        true,
+			wantCalled:    []bool{true, true, false},
+			wantCancelled: []bool{false, true, false},
+			wantHedges:    []int{0, 1, 0},
+			wantHedgeWins: []int{0, 0, 0},
+			wantFailures:  []int{0, 0, 0},
+		},
+		{
+			name:          "failure launches the next source without waiting",
+			sources:       []fakeSource{failAfter(0), answerAfter(0, "backup")},
+			wantContent:   "backup",
+			wantOK:        true,
+			wantCalled:    []bool{true, true},
+			wantCancelled: []bool{false, false},
+			wantHedges:    []int{0, 0},
+			wantHedgeWins: []int{0, 0},
+			wantFailures:  []int{1, 0},
+		},
+		{
+			name:          "all sources fail",
+			sources:       []fakeSource{failAfter(0), failAfter(0)},
+			wantOK:        false,
+			wantCalled:    []bool{true, true},
+			wantCancelled: []bool{false, false},
+			wantHedges:    []int{0, 0},
+			wantHedgeWins: []int{0, 0},
+			wantFailures:  []int{1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sources []APISource
+			byName := make(map[string]int)
+			for i := range tt.sources {
+				name := fmt.Sprintf("s%d", i)
+				sources = append(sources, APISource{Name: name, Priority: i})
+				byName[name] = i
+			}
+			m := NewFaultTolerantModel(&MultiAPIConfig{Sources: sources})
+
+			var mu sync.Mutex
+			called := make([]bool, len(sources))
+			cancelled := make([]bool, len(sources))
+			call := func(ctx context.Context, source APISource, claim func() bool) (string, []ToolCall, error) {
+				i := byName[source.Name]
+				content, err := tt.sources[i](ctx, claim)
+				mu.Lock()
+				called[i] = true
+				cancelled[i] = ctx.Err() != nil
+				mu.Unlock()
+				return content, nil, err
+			}
+
+			content, _, ok, err := m.invokeHedged(context.Background(), sources, delay, call)
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if ok != tt.wantOK || content != tt.wantContent {
+				t.Fatalf("got (%q, %v), want (%q, %v)", content, ok, tt.wantContent, tt.wantOK)
+			}
+
+			// 落败者在 invokeHedged 返回后才退出并记账
+			eventually(t, func() error {
+				mu.Lock()
+				defer mu.Unlock()
+				for i, source := range sources {
+					stats := m.breaker(source.Name).Stats()
+					switch {
+					case called[i] != tt.wantCalled[i]:
+						return fmt.Errorf("%s called = %v, want %v", source.Name, called[i], tt.wantCalled[i])
+					case cancelled[i] != tt.wantCancelled[i]:
+						return fmt.Errorf("%s cancelled = %v, want %v", source.Name, cancelled[i], tt.wantCancelled[i])
+					case stats.Hedges != tt.wantHedges[i] || stats.HedgeWins != tt.wantHedgeWins[i]:
+						return fmt.Errorf("%s hedges/wins = %d/%d, want %d/%d", source.Name, stats.Hedges, stats.HedgeWins, tt.wantHedges[i], tt.wantHedgeWins[i])
+					case stats.Failure != tt.wantFailures[i]:
+						return fmt.Errorf("%s failures = %d, want %d", source.Name, stats.Failure, tt.wantFailures[i])
+					}
+				}
+				return nil
+			})
+		})
+	}
+}
+
+// eventually 在 1 秒内反复检查，直到 check 返回 nil
+func eventually(t *testing.T, check func() error) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := check()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestInvokeHedgedCallerCancelled(t *testing.T) {
+	m := NewFaultTolerantModel(&MultiAPIConfig{Sources: []APISource{{Name: "a"}, {Name: "b", Priority: 1}}})
+	sources, _, _ := m.snapshot()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, ok, err := m.invokeHedged(ctx, sources, 10*time.Millisecond, func(ctx context.Context, _ APISource, claim func() bool) (string, []ToolCall, error) {
+		content, err := hang(ctx, claim)
+		return content, nil, err
+	})
+	if ok || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got ok=%v err=%v, want context.DeadlineExceeded", ok, err)
+	}
+}
+
+// TestHedgedInvokeOverHTTP 通过真实的 HTTP 请求验证：慢的主源被取消，备用源的答复胜出
+func TestHedgedInvokeOverHTTP(t *testing.T) {
+	primaryDone := make(chan struct{})
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(primaryDone)
+		// 读完请求体后服务端才会察觉客户端断开
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-time.After(5 * time.Second):
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"primary"}}]}`)
+		case <-r.Context().Done():
+		}
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"backup"}}]}`)
+	}))
+	defer backup.Close()
+
+	m := NewFaultTolerantModel(&MultiAPIConfig{
+		Sources: []APISource{
+			{Name: "primary", BaseURL: primary.URL, Priority: 1},
+			{Name: "backup", BaseURL: backup.URL, Priority: 2},
+		},
+		Hedge: HedgeConfig{Enabled: true, DelayMs: 20},
+	})
+
+	content, _, err := m.Invoke(context.Background(), []Message{{Role: "user", Content: "你好"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "backup" {
+		t.Fatalf("content = %q, want backup", content)
+	}
+	select {
+	case <-primaryDone:
+	case <-time.After(time.Second):
+		t.Fatal("primary request was not cancelled")
+	}
+
+	stats := m.GetStats()
+	if stats["hedges"] != 1 || stats["hedge_wins"] != 1 {
+		t.Errorf("hedges/wins = %v/%v, want 1/1", stats["hedges"], stats["hedge_wins"])
+	}
+}
@@ -1,21 +1,27 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
+	"math"
+	"net/http"
+	"sync"
+	"time"
 
 	"agent/utils"
+
 	"github.com/go-resty/resty/v2"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
-
-	"net/http"
 )
 
 type ChatModel struct {
+	mu          sync.RWMutex // 保护以下配置字段，支持热更新
 	model       string
 	baseURL     string
 	apiKey      string
 	temperature float64
+	structured  string // 结构化输出模式（StructuredJSONSchema 等）
 	client      *http.Client
 }
 
@@ -25,27 +31,99 @@ func NewChatModel(cfg *Config) *ChatModel {
 		baseURL:     cfg.BaseURL,
 		apiKey:      cfg.Token,
 		temperature: cfg.Temperature,
+		structured:  cfg.StructuredOutput,
 		client:      &http.Client{},
 	}
 }
 
-func (m *ChatModel) Invoke(messages []Message, tools []map[string]interface{}) (string, []ToolCall, error) {
+// ApplyConfig 热更新模型配置（模型名、地址、Token、温度、结构化输出模式）
+func (m *ChatModel) ApplyConfig(cfg *Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.model = cfg.ModelName
+	m.baseURL = cfg.BaseURL
+	m.apiKey = cfg.Token
+	m.temperature = cfg.Temperature
+	m.structured = cfg.StructuredOutput
+}
+
+// CacheIdentity 模型名与温度（响应缓存的键）
+func (m *ChatModel) CacheIdentity(ctx context.Context, messages []Message) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return cacheIdentity(m.model, temperatureFrom(ctx, m.temperature))
+}
+
+type temperatureKey struct{}
+
+// WithTemperature 为后续调用临时指定温度（覆盖配置的 temperature），如重新生成回复时提高随机性
+func WithTemperature(ctx context.Context, temperature float64) context.Context {
+	return context.WithValue(ctx, temperatureKey{}, temperature)
+}
+
+// 重新生成回复时在配置的温度上提高多少，以及提高后的上限
+const (
+	regenerateBoost          = 0.3
+	maxRegenerateTemperature = 1.5
+)
+
+// RegenerateTemperature 重新生成回复时使用的温度：比 base 略高，让新回复与上一版拉开差异
+func RegenerateTemperature(base float64) float64 {
+	return math.Min(math.Max(base, 0)+regenerateBoost, math.Max(base, maxRegenerateTemperature))
+}
+
+// temperatureFrom ctx 上指定的温度，未指定时返回 def
+func temperatureFrom(ctx context.Context, def float64) float64 {
+	if t, ok := ctx.Value(temperatureKey{}).(float64); ok {
+		return t
+	}
+	return def
+}
+
+// snapshot 读取当前配置：请求体、地址与 Token
+func (m *ChatModel) snapshot(ctx context.Context, messages []Message, tools []map[string]interface{}) (map[string]interface{}, string, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	reqBody := map[string]interface{}{
-		"model":       m.model,       // 模型名称
-		"messages":    messages,      // 对话消息（注意：是 "messages" 不是 "data"）
-		"temperature": m.temperature, // 温度参数
+		"model":       m.model,                             // 模型名称
+		"messages":    messages,                            // 对话消息（注意：是 "messages" 不是 "data"）
+		"temperature": temperatureFrom(ctx, m.temperature), // 温度参数
 	}
 	if len(tools) > 0 {
 		reqBody["tools"] = tools
 	}
+	applyResponseFormat(ctx, reqBody, m.structured)
+	return reqBody, m.baseURL, m.apiKey
+}
+
+// source 单源模型在指标中的源名与模型名
+func (m *ChatModel) source(reqBody map[string]interface{}) APISource {
+	model, _ := reqBody["model"].(string)
+	return APISource{Name: "default", ModelName: model}
+}
+
+func (m *ChatModel) Invoke(ctx context.Context, messages []Message, tools []map[string]interface{}) (content string, toolCalls []ToolCall, err error) {
+	if err := CheckBudget(ctx); err != nil {
+		return "", nil, err
+	}
+	reqBody, baseURL, apiKey := m.snapshot(ctx, messages, tools)
+	source := m.source(reqBody)
+	ctx, span := startSourceCall(ctx, source, messages, false)
+	start := time.Now()
+	defer func() { endSourceCall(ctx, span, source, start, err) }()
 
 	client := resty.New()
 	resp, err := client.R().
+		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
-		SetHeader("Authorization", "Bearer "+m.apiKey).
+		SetHeader("Authorization", "Bearer "+apiKey).
 		SetBody(reqBody).
-		Post(m.baseURL + utils.ChatCompletionsPath)
+		Post(baseURL + utils.ChatCompletionsPath)
 
+	if ctx.Err() != nil {
+		return "", nil, ctx.Err()
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("调用 ChatModel 失败")
 		return "", nil, errors.New("Error calling ChatModel: " + err.Error())
@@ -63,6 +141,7 @@ func (m *ChatModel) Invoke(messages []Message, tools []map[string]interface{}) (
 				ToolCalls []ToolCall `json:"tool_calls"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage Usage `json:"usage"`
 	}
 
 	if err := json.Unmarshal(resp.Body(), &result); err != nil {
@@ -75,6 +154,7 @@ func (m *ChatModel) Invoke(messages []Message, tools []map[string]interface{}) (
 	}
 	// choices[0]: 默认只请求一个回复(n=1)，取第一个即可
 	msg := result.Choices[0].Message
+	recordUsage(ctx, reqBody["model"].(string), result.Usage, messages, msg.Content)
 	return msg.Content, msg.ToolCalls, nil
 
 }
@@ -1,46 +1,68 @@
 package config
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"sort"
+	"sync"
 	"time"
 
+	"agent/metrics"
 	"agent/utils"
+
 	"github.com/go-resty/resty/v2"
 	"github.com/rs/zerolog/log"
 )
 
 // APISource API 源配置
 type APISource struct {
-	Name       string `yaml:"name"`
-	BaseURL    string `yaml:"base_url"`
-	Token      string `yaml:"token"`
-	ModelName  string `yaml:"model_name"`
-	Priority   int    `yaml:"priority"`    // 优先级，数字越小优先级越高
-	Timeout    int    `yaml:"timeout"`     // 超时时间（秒）
-	MaxRetries int    `yaml:"max_retries"` // 最大重试次数
+	Name       string `yaml:"name" mapstructure:"name"`
+	BaseURL    string `yaml:"base_url" mapstructure:"base_url"`
+	Token      string `yaml:"token" mapstructure:"token"`
+	ModelName  string `yaml:"model_name" mapstructure:"model_name"`
+	Priority   int    `yaml:"priority" mapstructure:"priority"`       // 优先级，数字越小优先级越高
+	Timeout    int    `yaml:"timeout" mapstructure:"timeout"`         // 超时时间（秒）
+	MaxRetries int    `yaml:"max_retries" mapstructure:"max_retries"` // 最大重试次数
 }
 
 // MultiAPIConfig 多 API 源配置
 type MultiAPIConfig struct {
-	Sources         []APISource `yaml:"sources"`
-	FallbackMessage string      `yaml:"fallback_message"` // 所有 API 都失败时的兜底消息
+	Sources         []APISource          `yaml:"sources" mapstructure:"sources"`
+	FallbackMessage string               `yaml:"fallback_message" mapstructure:"fallback_message"` // 所有 API 都失败时的兜底消息
+	CircuitBreaker  CircuitBreakerConfig `yaml:"circuit_breaker" mapstructure:"circuit_breaker"`   // 每个源的熔断参数
+	Hedge           HedgeConfig          `yaml:"hedge" mapstructure:"hedge"`                       // 对冲请求（降低长尾延迟）
 }
 
 // FaultTolerantModel 容错模型
 // 实现三层容错机制：主 API -> 备用 API -> 静态回复
+// 每个源有独立的熔断器，熔断中的源直接跳过，不再每次都等满超时与重试
 type FaultTolerantModel struct {
+	mu              sync.RWMutex // 保护 sources / breakers / fallbackMessage / temperature / hedge，支持热更新
 	sources         []APISource
+	breakers        map[string]*CircuitBreaker // 按源名称，同时记录成功 / 失败次数、延迟与对冲次数
 	fallbackMessage string
+	temperature     float64
+	structured      string // 结构化输出模式（StructuredJSONSchema 等）
+	hedge           HedgeConfig
 	client          *http.Client
-
-	// 统计信息
-	successCount map[string]int
-	failureCount map[string]int
 }
 
 // NewFaultTolerantModel 创建容错模型
 func NewFaultTolerantModel(cfg *MultiAPIConfig) *FaultTolerantModel {
+	m := &FaultTolerantModel{
+		temperature: 0.7,
+		client:      &http.Client{},
+		breakers:    make(map[string]*CircuitBreaker),
+	}
+	m.UpdateSources(cfg)
+	return m
+}
+
+// UpdateSources 更新 API 源列表与兜底消息（按优先级重新排序）
+func (m *FaultTolerantModel) UpdateSources(cfg *MultiAPIConfig) {
 	// 按优先级排序
 	sources := make([]APISource, len(cfg.Sources))
 	copy(sources, cfg.Sources)
@@ -59,68 +81,348 @@ func NewFaultTolerantModel(cfg *MultiAPIConfig) *FaultTolerantModel {
 		fallback = "抱歉，系统暂时繁忙，请稍后再试。不过，真正的哲学家不会因为技术问题而停止思考。"
 	}
 
-	return &FaultTolerantModel{
-		sources:         sources,
-		fallbackMessage: fallback,
-		client:          &http.Client{},
-		successCount:    make(map[string]int),
-		failureCount:    make(map[string]int),
+	m.mu.Lock()
+	m.sources = sources
+	m.fallbackMessage = fallback
+	m.hedge = cfg.Hedge
+	// 保留已有源的熔断状态，只更新参数
+	breakers := make(map[string]*CircuitBreaker, len(sources))
+	for _, source := range sources {
+		b, ok := m.breakers[source.Name]
+		if ok {
+			b.SetConfig(cfg.CircuitBreaker)
+		} else {
+			b = NewCircuitBreaker(cfg.CircuitBreaker)
+		}
+		breakers[source.Name] = b
 	}
+	m.breakers = breakers
+	m.mu.Unlock()
+}
+
+// SetTemperature 设置温度参数
+func (m *FaultTolerantModel) SetTemperature(temperature float64) {
+	m.mu.Lock()
+	m.temperature = temperature
+	m.mu.Unlock()
+}
+
+// SetStructuredOutput 设置结构化输出模式（接口对 response_format 的支持程度）
+func (m *FaultTolerantModel) SetStructuredOutput(mode string) {
+	m.mu.Lock()
+	m.structured = mode
+	m.mu.Unlock()
+}
+
+// ApplyConfig 热更新：API 源（模型名、优先级等）、兜底消息、温度与结构化输出模式
+func (m *FaultTolerantModel) ApplyConfig(cfg *Config) {
+	m.UpdateSources(&cfg.MultiAPI)
+	m.SetTemperature(cfg.Temperature)
+	m.SetStructuredOutput(cfg.StructuredOutput)
+}
+
+// snapshot 读取当前的源列表、兜底消息与温度
+func (m *FaultTolerantModel) snapshot() ([]APISource, string, float64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sources, m.fallbackMessage, m.temperature
+}
+
+// CacheIdentity 优先级最高的源的模型名与温度（响应缓存的键）
+func (m *FaultTolerantModel) CacheIdentity(ctx context.Context, messages []Message) string {
+	sources, _, temperature := m.snapshot()
+	if len(sources) == 0 {
+		return ""
+	}
+	return cacheIdentity(sources[0].ModelName, temperatureFrom(ctx, temperature))
+}
+
+// IsFallback 回复是否为兜底消息
+func (m *FaultTolerantModel) IsFallback(content string) bool {
+	_, fallbackMessage, _ := m.snapshot()
+	return content == fallbackMessage
+}
+
+// hedgeDelay 对冲延迟，未启用对冲时返回 0
+func (m *FaultTolerantModel) hedgeDelay() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.hedge.hedgeDelay()
+}
+
+// breaker 获取源的熔断器（热更新后被移除的源返回新的熔断器）
+func (m *FaultTolerantModel) breaker(name string) *CircuitBreaker {
+	m.mu.RLock()
+	b, ok := m.breakers[name]
+	m.mu.RUnlock()
+	if !ok {
+		return NewCircuitBreaker(CircuitBreakerConfig{})
+	}
+	return b
+}
+
+// orderSources 调用顺序：按优先级，同优先级时平均延迟低的优先
+func (m *FaultTolerantModel) orderSources(sources []APISource) []APISource {
+	ordered := make([]APISource, len(sources))
+	copy(ordered, sources)
+	latency := make(map[string]time.Duration, len(sources))
+	for _, source := range sources {
+		latency[source.Name] = m.breaker(source.Name).AvgLatency()
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Priority != ordered[j].Priority {
+			return ordered[i].Priority < ordered[j].Priority
+		}
+		return latency[ordered[i].Name] < latency[ordered[j].Name]
+	})
+	return ordered
 }
 
 // Invoke 调用模型（带容错）
-func (m *FaultTolerantModel) Invoke(messages []Message, tools []map[string]interface{}) (string, []ToolCall, error) {
+// ctx 被取消或预算用尽时直接返回错误，不再尝试后续源，也不返回兜底消息
+func (m *FaultTolerantModel) Invoke(ctx context.Context, messages []Message, tools []map[string]interface{}) (string, []ToolCall, error) {
+	if err := CheckBudget(ctx); err != nil {
+		return "", nil, err
+	}
+	sources, fallbackMessage, temperature := m.snapshot()
+
+	if delay := m.hedgeDelay(); delay > 0 {
+		content, toolCalls, ok, err := m.invokeHedged(ctx, m.orderSources(sources), delay,
+			func(ctx context.Context, source APISource, _ func() bool) (string, []ToolCall, error) {
+				return m.invokeSource(ctx, source, temperature, messages, tools)
+			})
+		if ok || err != nil {
+			return content, toolCalls, err
+		}
+		metrics.LLMFallbacks.Inc()
+		return fallbackMessage, nil, nil
+	}
+
 	var lastErr error
 
-	// 依次尝试每个 API 源
-	for _, source := range m.sources {
-		content, toolCalls, err := m.invokeSource(source, messages, tools)
+	// 依次尝试每个 API 源，跳过熔断中的源
+	for _, source := range m.orderSources(sources) {
+		if err := ctx.Err(); err != nil {
+			return "", nil, err
+		}
+		breaker := m.breaker(source.Name)
+		if !breaker.Allow() {
+			log.Debug().Str("source", source.Name).Msg("API 源熔断中，跳过")
+			continue
+		}
+
+		start := time.Now()
+		content, toolCalls, err := m.invokeSource(ctx, source, temperature, messages, tools)
 		if err == nil {
-			m.successCount[source.Name]++
+			breaker.OnSuccess(time.Since(start))
 			return content, toolCalls, nil
 		}
+		if ctx.Err() != nil {
+			breaker.OnCancel()
+			return "", nil, ctx.Err()
+		}
 
 		log.Warn().
 			Str("source", source.Name).
 			Err(err).
 			Msg("API 调用失败，尝试下一个源")
 
-		m.failureCount[source.Name]++
+		breaker.OnFailure(err)
 		lastErr = err
 	}
 
 	// 所有 API 都失败，返回兜底消息
 	log.Error().Err(lastErr).Msg("所有 API 源都失败，返回兜底消息")
-	return m.fallbackMessage, nil, nil
+	metrics.LLMFallbacks.Inc()
+	return fallbackMessage, nil, nil
 }
 
-// invokeSource 调用单个 API 源
-func (m *FaultTolerantModel) invokeSource(source APISource, messages []Message, tools []map[string]interface{}) (string, []ToolCall, error) {
+// InvokeStream 流式调用模型（带容错）
+// 只有在尚未输出任何片段时才会切换到下一个源；输出中途失败则返回已生成的部分和错误
+func (m *FaultTolerantModel) InvokeStream(ctx context.Context, messages []Message, tools []map[string]interface{}, onChunk StreamHandler) (string, []ToolCall, error) {
+	if err := CheckBudget(ctx); err != nil {
+		return "", nil, err
+	}
+	sources, fallbackMessage, temperature := m.snapshot()
+
+	// 对冲模式下最先输出片段的源胜出，其余源立即取消
+	if delay := m.hedgeDelay(); delay > 0 {
+		content, toolCalls, ok, err := m.invokeHedged(ctx, m.orderSources(sources), delay,
+			func(ctx context.Context, source APISource, claim func() bool) (string, []ToolCall, error) {
+				return m.invokeSourceStream(ctx, source, temperature, messages, tools, func(chunk StreamChunk) {
+					if claim() && onChunk != nil {
+						onChunk(chunk)
+					}
+				})
+			})
+		if ok || err != nil {
+			return content, toolCalls, err
+		}
+		metrics.LLMFallbacks.Inc()
+		if onChunk != nil {
+			onChunk(StreamChunk{Content: fallbackMessage})
+		}
+		return fallbackMessage, nil, nil
+	}
+
+	var lastErr error
+
+	for _, source := range m.orderSources(sources) {
+		if err := ctx.Err(); err != nil {
+			return "", nil, err
+		}
+		breaker := m.breaker(source.Name)
+		if !breaker.Allow() {
+			log.Debug().Str("source", source.Name).Msg("API 源熔断中，跳过")
+			continue
+		}
+
+		// 流式调用的耗时取决于输出长度，延迟按首个片段到达的时间计
+		start := time.Now()
+		var firstChunk time.Duration
+		emitted := false
+		content, toolCalls, err := m.invokeSourceStream(ctx, source, temperature, messages, tools, func(chunk StreamChunk) {
+			if !emitted {
+				firstChunk = time.Since(start)
+			}
+			emitted = true
+			if onChunk != nil {
+				onChunk(chunk)
+			}
+		})
+		if err == nil {
+			if !emitted {
+				firstChunk = time.Since(start)
+			}
+			breaker.OnSuccess(firstChunk)
+			return content, toolCalls, nil
+		}
+		if ctx.Err() != nil {
+			breaker.OnCancel()
+			return content, toolCalls, ctx.Err()
+		}
+
+		breaker.OnFailure(err)
+		if emitted {
+			log.Error().Str("source", source.Name).Err(err).Msg("流式输出中途失败")
+			return content, toolCalls, err
+		}
+
+		log.Warn().
+			Str("source", source.Name).
+			Err(err).
+			Msg("API 调用失败，尝试下一个源")
+		lastErr = err
+	}
+
+	log.Error().Err(lastErr).Msg("所有 API 源都失败，返回兜底消息")
+	metrics.LLMFallbacks.Inc()
+	if onChunk != nil {
+		onChunk(StreamChunk{Content: fallbackMessage})
+	}
+	return fallbackMessage, nil, nil
+}
+
+// invokeSourceStream 以流式方式调用单个 API 源
+// 流式响应时间不可预估，超时只约束到收到响应头为止；调用方的 ctx 则约束整个过程
+func (m *FaultTolerantModel) invokeSourceStream(parent context.Context, source APISource, temperature float64, messages []Message, tools []map[string]interface{}, onChunk StreamHandler) (content string, calls []ToolCall, err error) {
+	parent, span := startSourceCall(parent, source, messages, true)
+	start := time.Now()
+	defer func() { endSourceCall(parent, span, source, start, err) }()
+
+	reqBody := m.buildSourceRequest(parent, source, temperature, messages, tools)
+	reqBody["stream"] = true
+	reqBody["stream_options"] = map[string]interface{}{"include_usage": true}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	timer := time.AfterFunc(sourceTimeout(source), cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, source.BaseURL+utils.ChatCompletionsPath, bytes.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+source.Token)
+
+	resp, err := m.client.Do(req)
+	timer.Stop()
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(respBody),
+		}
+	}
+
+	content, calls, usage, err := ReadChatStream(resp.Body, onChunk)
+	recordUsage(parent, source.ModelName, usage, messages, content)
+	return content, calls, err
+}
+
+// buildSourceRequest 构建单个 API 源的请求体
+func (m *FaultTolerantModel) buildSourceRequest(ctx context.Context, source APISource, temperature float64, messages []Message, tools []map[string]interface{}) map[string]interface{} {
 	reqBody := map[string]interface{}{
 		"model":       source.ModelName,
 		"messages":    messages,
-		"temperature": 0.7,
+		"temperature": temperatureFrom(ctx, temperature),
 	}
 	if len(tools) > 0 {
 		reqBody["tools"] = tools
 	}
+	m.mu.RLock()
+	applyResponseFormat(ctx, reqBody, m.structured)
+	m.mu.RUnlock()
+	return reqBody
+}
 
-	timeout := source.Timeout
-	if timeout == 0 {
-		timeout = 30
+// sourceTimeout 单个 API 源的超时时间（默认 30 秒）
+func sourceTimeout(source APISource) time.Duration {
+	if source.Timeout == 0 {
+		return 30 * time.Second
 	}
+	return time.Duration(source.Timeout) * time.Second
+}
+
+// invokeSource 调用单个 API 源
+func (m *FaultTolerantModel) invokeSource(ctx context.Context, source APISource, temperature float64, messages []Message, tools []map[string]interface{}) (content string, toolCalls []ToolCall, err error) {
+	ctx, span := startSourceCall(ctx, source, messages, false)
+	start := time.Now()
+	defer func() { endSourceCall(ctx, span, source, start, err) }()
+
+	reqBody := m.buildSourceRequest(ctx, source, temperature, messages, tools)
 
 	maxRetries := source.MaxRetries
 	if maxRetries == 0 {
 		maxRetries = 2
 	}
 
+	// 网络错误、超时、限流与 5xx 在同一个源上重试，其余状态码直接交给下一个源
 	client := resty.New().
-		SetTimeout(time.Duration(timeout) * time.Second).
+		SetTimeout(sourceTimeout(source)).
 		SetRetryCount(maxRetries).
-		SetRetryWaitTime(1 * time.Second)
+		SetRetryWaitTime(1 * time.Second).
+		AddRetryCondition(func(resp *resty.Response, err error) bool {
+			if err != nil {
+				return true
+			}
+			code := resp.StatusCode()
+			return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+		})
 
 	resp, err := client.R().
+		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetHeader("Authorization", "Bearer "+source.Token).
 		SetBody(reqBody).
@@ -144,6 +446,7 @@ func (m *FaultTolerantModel) invokeSource(source APISource, messages []Message,
 				ToolCalls []ToolCall `json:"tool_calls"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage Usage `json:"usage"`
 	}
 
 	if err := json.Unmarshal(resp.Body(), &result); err != nil {
@@ -155,14 +458,33 @@ func (m *FaultTolerantModel) invokeSource(source APISource, messages []Message,
 	}
 
 	msg := result.Choices[0].Message
+	recordUsage(ctx, source.ModelName, result.Usage, messages, msg.Content)
 	return msg.Content, msg.ToolCalls, nil
 }
 
-// GetStats 获取统计信息
+// GetStats 获取统计信息：各源的成功 / 失败次数、熔断状态与延迟，以及对冲请求次数（单独计数）
 func (m *FaultTolerantModel) GetStats() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	success := make(map[string]int, len(m.breakers))
+	failure := make(map[string]int, len(m.breakers))
+	sources := make(map[string]SourceStats, len(m.breakers))
+	var hedges, hedgeWins int
+	for name, b := range m.breakers {
+		stats := b.Stats()
+		success[name] = stats.Success
+		failure[name] = stats.Failure
+		sources[name] = stats
+		hedges += stats.Hedges
+		hedgeWins += stats.HedgeWins
+	}
 	return map[string]interface{}{
-		"success": m.successCount,
-		"failure": m.failureCount,
+		"success":    success,
+		"failure":    failure,
+		"sources":    sources,
+		"hedges":     hedges,
+		"hedge_wins": hedgeWins,
 	}
 }
 
@@ -179,138 +501,3 @@ func (e *APIError) Error() string {
 	}
 	return "API error: " + e.Body
 }
-
-// IntelligentModelRouter 智能模型路由器
-// 根据任务复杂度选择合适的模型
-type IntelligentModelRouter struct {
-	heavyModel *FaultTolerantModel // 重型模型（用于复杂任务）
-	lightModel *FaultTolerantModel // 轻型模型（用于简单任务）
-}
-
-// TaskComplexity 任务复杂度
-type TaskComplexity string
-
-const (
-	ComplexityHigh   TaskComplexity = "high"   // 高复杂度：辩论、深度分析
-	ComplexityMedium TaskComplexity = "medium" // 中复杂度：普通对话
-	ComplexityLow    TaskComplexity = "low"    // 低复杂度：简单任务
-)
-
-// NewIntelligentModelRouter 创建智能路由器
-func NewIntelligentModelRouter(heavyCfg, lightCfg *MultiAPIConfig) *IntelligentModelRouter {
-	return &IntelligentModelRouter{
-		heavyModel: NewFaultTolerantModel(heavyCfg),
-		lightModel: NewFaultTolerantModel(lightCfg),
-	}
-}
-
-// Route 根据复杂度路由到合适的模型
-func (r *IntelligentModelRouter) Route(complexity TaskComplexity) *FaultTolerantModel {
-	switch complexity {
-	case ComplexityHigh:
-		return r.heavyModel
-	case ComplexityLow:
-		return r.lightModel
-	default:
-		return r.heavyModel
-	}
-}
-
-// AnalyzeComplexity 分析任务复杂度
-func AnalyzeComplexity(task string) TaskComplexity {
-	// 简单的启发式规则
-	if len(task) > 500 {
-		return ComplexityHigh
-	}
-
-	// 包含辩论相关关键词
-	debateKeywords := []string{"辩论", "辩题", "正方", "反方", "立论", "质询"}
-	for _, kw := range debateKeywords {
-		if containsString(task, kw) {
-			return ComplexityHigh
-		}
-	}
-
-	// 包含深度分析关键词
-	analysisKeywords := []string{"分析", "为什么", "如何", "意义", "本质"}
-	for _, kw := range analysisKeywords {
-		if containsString(task, kw) {
-			return ComplexityMedium
-		}
-	}
-
-	return ComplexityLow
-}
-
-func containsString(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsString(s[1:], substr) || s[:len(substr)] == substr)
-}
-
-// ResponseCache 响应缓存
-type ResponseCache struct {
-	cache      map[string]CacheEntry
-	maxSize    int
-	expiration time.Duration
-}
-
-// CacheEntry 缓存条目
-type CacheEntry struct {
-	Response  string
-	Timestamp time.Time
-}
-
-// NewResponseCache 创建响应缓存
-func NewResponseCache(maxSize int, expiration time.Duration) *ResponseCache {
-	return &ResponseCache{
-		cache:      make(map[string]CacheEntry),
-		maxSize:    maxSize,
-		expiration: expiration,
-	}
-}
-
-// Get 获取缓存
-func (c *ResponseCache) Get(key string) (string, bool) {
-	entry, ok := c.cache[key]
-	if !ok {
-		return "", false
-	}
-
-	// 检查是否过期
-	if time.Since(entry.Timestamp) > c.expiration {
-		delete(c.cache, key)
-		return "", false
-	}
-
-	return entry.Response, true
-}
-
-// Set 设置缓存
-func (c *ResponseCache) Set(key, response string) {
-	// 如果缓存已满，删除最旧的条目
-	if len(c.cache) >= c.maxSize {
-		var oldestKey string
-		var oldestTime time.Time
-		for k, v := range c.cache {
-			if oldestKey == "" || v.Timestamp.Before(oldestTime) {
-				oldestKey = k
-				oldestTime = v.Timestamp
-			}
-		}
-		delete(c.cache, oldestKey)
-	}
-
-	c.cache[key] = CacheEntry{
-		Response:  response,
-		Timestamp: time.Now(),
-	}
-}
-
-// GenerateCacheKey 生成缓存键
-func GenerateCacheKey(messages []Message) string {
-	// 简单地将所有消息内容拼接
-	var key string
-	for _, m := range messages {
-		key += m.Role + ":" + m.Content + "|"
-	}
-	return key
-}
@@ -0,0 +1,81 @@
+package config
+
+import "context"
+
+// Provider 大模型提供方接口
+// ChatModel、FaultTolerantModel、IntelligentModelRouter 都实现了它，
+// 上层模块（philosopher / react / api）只依赖该接口，便于替换为容错、路由、缓存或测试替身
+// ctx 取消或超时后，进行中的 HTTP 请求会被中断并返回 ctx.Err()
+type Provider interface {
+	Invoke(ctx context.Context, messages []Message, tools []map[string]interface{}) (string, []ToolCall, error)
+}
+
+// StreamProvider 支持流式输出的 Provider
+type StreamProvider interface {
+	Provider
+	InvokeStream(ctx context.Context, messages []Message, tools []map[string]interface{}, onChunk StreamHandler) (string, []ToolCall, error)
+}
+
+// Reloadable 支持配置热更新的组件（见 WatchConfig）
+type Reloadable interface {
+	ApplyConfig(cfg *Config)
+}
+
+// StatsReporter 能报告各 API 源健康状况的组件（见 FaultTolerantModel.GetStats）
+type StatsReporter interface {
+	GetStats() map[string]interface{}
+}
+
+// CacheIdentifier 能给出本次调用实际使用的模型与温度的 Provider（用于响应缓存的键）
+type CacheIdentifier interface {
+	CacheIdentity(ctx context.Context, messages []Message) string
+}
+
+// FallbackReporter 会返回兜底消息的 Provider，兜底消息不应被缓存
+type FallbackReporter interface {
+	IsFallback(content string) bool
+}
+
+// InvokeStream 以流式方式调用任意 Provider
+// 不支持流式的 Provider 会退化为一次性调用，并把完整文本作为单个片段回调
+func InvokeStream(ctx context.Context, p Provider, messages []Message, tools []map[string]interface{}, onChunk StreamHandler) (string, []ToolCall, error) {
+	if sp, ok := p.(StreamProvider); ok {
+		return sp.InvokeStream(ctx, messages, tools, onChunk)
+	}
+
+	content, toolCalls, err := p.Invoke(ctx, messages, tools)
+	if err != nil {
+		return "", nil, err
+	}
+	if onChunk != nil && content != "" {
+		onChunk(StreamChunk{Content: content})
+	}
+	return content, toolCalls, nil
+}
+
+// 编译期检查：内置模型均实现 StreamProvider
+var (
+	_ StreamProvider = (*ChatModel)(nil)
+	_ StreamProvider = (*FaultTolerantModel)(nil)
+	_ StreamProvider = (*IntelligentModelRouter)(nil)
+	_ StreamProvider = (*ReplayModel)(nil)
+	_ StreamProvider = (*CachedModel)(nil)
+
+	_ Reloadable = (*ChatModel)(nil)
+	_ Reloadable = (*FaultTolerantModel)(nil)
+	_ Reloadable = (*IntelligentModelRouter)(nil)
+	_ Reloadable = (*CachedModel)(nil)
+
+	_ StatsReporter = (*FaultTolerantModel)(nil)
+	_ StatsReporter = (*IntelligentModelRouter)(nil)
+	_ StatsReporter = (*CachedModel)(nil)
+
+	_ CacheIdentifier = (*ChatModel)(nil)
+	_ CacheIdentifier = (*FaultTolerantModel)(nil)
+	_ CacheIdentifier = (*IntelligentModelRouter)(nil)
+	_ CacheIdentifier = (*ReplayModel)(nil)
+	_ CacheIdentifier = (*CachedModel)(nil)
+
+	_ FallbackReporter = (*FaultTolerantModel)(nil)
+	_ FallbackReporter = (*IntelligentModelRouter)(nil)
+)
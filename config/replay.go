@@ -0,0 +1,227 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// ==================== 录制 / 回放模型 ====================
+
+// ReplayMode 录制回放模式
+type ReplayMode string
+
+const (
+	ReplayModeRecord ReplayMode = "record" // 代理真实模型，并把请求 -> 响应保存为 cassette
+	ReplayModeReplay ReplayMode = "replay" // 只从 cassette 读取，未命中即报错（离线测试 / 演示）
+)
+
+// ErrCassetteNotFound 回放模式下找不到对应的 cassette
+var ErrCassetteNotFound = errors.New("cassette not found")
+
+// Cassette 一次模型调用的录制结果
+type Cassette struct {
+	Key      string           `json:"key"`
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest 归一化后的请求（用于生成 Key，也便于人工查看）
+type CassetteRequest struct {
+	Messages []Message                `json:"messages"`
+	Tools    []map[string]interface{} `json:"tools,omitempty"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"` // 结构化输出的格式要求
+}
+
+// CassetteResponse 录制的响应
+type CassetteResponse struct {
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Model     string     `json:"model,omitempty"` // 录制时实际使用的模型
+	Usage     *Usage     `json:"usage,omitempty"` // 录制时的 token 用量，回放时照常记账
+}
+
+// ReplayModel 确定性的录制 / 回放 Provider
+// 以归一化后的 messages + tools 作为 Key，每个 cassette 存为 dir 下的一个 JSON 文件
+type ReplayModel struct {
+	mode  ReplayMode
+	dir   string
+	inner Provider // 录制模式下被代理的真实模型
+	mu    sync.Mutex
+}
+
+// NewReplayModel 创建录制 / 回放模型；录制模式必须提供 inner
+func NewReplayModel(mode ReplayMode, dir string, inner Provider) (*ReplayModel, error) {
+	switch mode {
+	case ReplayModeRecord:
+		if inner == nil {
+			return nil, errors.New("record mode requires an inner provider")
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, errors.Wrap(err, "create cassette dir")
+		}
+	case ReplayModeReplay:
+		if _, err := os.Stat(dir); err != nil {
+			return nil, errors.Wrap(err, "open cassette dir")
+		}
+	default:
+		return nil, errors.Errorf("unknown replay mode %q", mode)
+	}
+
+	return &ReplayModel{mode: mode, dir: dir, inner: inner}, nil
+}
+
+// Invoke 录制模式下代理并保存，回放模式下读取 cassette
+func (m *ReplayModel) Invoke(ctx context.Context, messages []Message, tools []map[string]interface{}) (string, []ToolCall, error) {
+	req := normalizeRequest(messages, tools)
+	req.ResponseFormat = ResponseFormatFrom(ctx)
+	key, err := cassetteKey(req)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if m.mode == ReplayModeReplay {
+		if err := ctx.Err(); err != nil {
+			return "", nil, err
+		}
+		if err := CheckBudget(ctx); err != nil {
+			return "", nil, err
+		}
+		c, err := m.load(key)
+		if err != nil {
+			return "", nil, err
+		}
+		if c.Response.Usage != nil {
+			RecordUsage(ctx, c.Response.Model, *c.Response.Usage)
+		}
+		return c.Response.Content, c.Response.ToolCalls, nil
+	}
+
+	capture := NewUsageTracker(PricingConfig{})
+	content, toolCalls, err := m.inner.Invoke(WithUsageTracker(ctx, capture), messages, tools)
+	if err != nil {
+		return "", nil, err
+	}
+	return content, toolCalls, m.save(key, req, content, toolCalls, capture)
+}
+
+// InvokeStream 流式版本：回放时把完整内容作为单个片段回调
+func (m *ReplayModel) InvokeStream(ctx context.Context, messages []Message, tools []map[string]interface{}, onChunk StreamHandler) (string, []ToolCall, error) {
+	if m.mode == ReplayModeReplay {
+		content, toolCalls, err := m.Invoke(ctx, messages, tools)
+		if err != nil {
+			return "", nil, err
+		}
+		if onChunk != nil && content != "" {
+			onChunk(StreamChunk{Content: content})
+		}
+		return content, toolCalls, nil
+	}
+
+	req := normalizeRequest(messages, tools)
+	req.ResponseFormat = ResponseFormatFrom(ctx)
+	key, err := cassetteKey(req)
+	if err != nil {
+		return "", nil, err
+	}
+	capture := NewUsageTracker(PricingConfig{})
+	content, toolCalls, err := InvokeStream(WithUsageTracker(ctx, capture), m.inner, messages, tools, onChunk)
+	if err != nil {
+		return "", nil, err
+	}
+	return content, toolCalls, m.save(key, req, content, toolCalls, capture)
+}
+
+// CacheIdentity 回放模型的标识（响应缓存的键）
+// 录制模式下透传被代理模型的标识，回放模式下为 "replay"
+func (m *ReplayModel) CacheIdentity(ctx context.Context, messages []Message) string {
+	if m.inner == nil {
+		return string(ReplayModeReplay)
+	}
+	return identityOf(ctx, m.inner, messages)
+}
+
+// load 读取 cassette
+func (m *ReplayModel) load(key string) (*Cassette, error) {
+	data, err := os.ReadFile(m.path(key))
+	if os.IsNotExist(err) {
+		log.Error().Str("key", key).Str("dir", m.dir).Msg("回放未命中")
+		return nil, errors.Wrapf(ErrCassetteNotFound, "key %s", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.Wrapf(err, "decode cassette %s", key)
+	}
+	return &c, nil
+}
+
+// save 保存 cassette（同一 Key 重复录制时覆盖），capture 为录制期间捕获的用量
+func (m *ReplayModel) save(key string, req CassetteRequest, content string, toolCalls []ToolCall, capture *UsageTracker) error {
+	resp := CassetteResponse{Content: content, ToolCalls: toolCalls}
+	if summary := capture.Summary(); summary.Calls > 0 {
+		resp.Usage = &summary.Usage
+		for model := range summary.ByModel {
+			resp.Model = model
+		}
+	}
+
+	data, err := json.MarshalIndent(Cassette{
+		Key:      key,
+		Request:  req,
+		Response: resp,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return os.WriteFile(m.path(key), data, 0o644)
+}
+
+func (m *ReplayModel) path(key string) string {
+	return filepath.Join(m.dir, key+".json")
+}
+
+// normalizeRequest 归一化请求：去掉内容首尾空白、统一换行
+// 工具调用 ID 由模型随机生成，不参与 Key 计算
+func normalizeRequest(messages []Message, tools []map[string]interface{}) CassetteRequest {
+	normalized := make([]Message, len(messages))
+	for i, msg := range messages {
+		msg.Content = strings.TrimSpace(strings.ReplaceAll(msg.Content, "\r\n", "\n"))
+		msg.ToolCallID = ""
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]ToolCall, len(msg.ToolCalls))
+			copy(calls, msg.ToolCalls)
+			for j := range calls {
+				calls[j].ID = ""
+			}
+			msg.ToolCalls = calls
+		}
+		normalized[i] = msg
+	}
+	return CassetteRequest{Messages: normalized, Tools: tools}
+}
+
+// cassetteKey 对归一化请求做 SHA-256（json.Marshal 对 map 按键排序，结果稳定）
+func cassetteKey(req CassetteRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:24], nil
+}
@@ -0,0 +1,45 @@
+// Package replaytest 测试用的回放模型：默认回放 cassette，带 -record 运行时代理真实接口并重新录制
+//
+// 重新录制某个包的 cassette：启动 fakellm 后带 -record 运行
+//
+//	go run ./cmd/fakellm -script <pkg>/testdata/fakellm.yaml &
+//	go test ./<pkg> -record
+package replaytest
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"testing"
+
+	"agent/config"
+)
+
+var record = flag.Bool("record", false, "record cassettes against MYGO_RECORD_BASE_URL (default fakellm on :9090)")
+
+// Model 回放 dir 中的 cassette；-record 时代理 MYGO_RECORD_BASE_URL（默认 :9090 上的 fakellm）并重新录制
+func Model(t *testing.T, dir string) *config.ReplayModel {
+	t.Helper()
+	if !*record {
+		m, err := config.NewReplayModel(config.ReplayModeReplay, dir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	baseURL := os.Getenv("MYGO_RECORD_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:9090"
+	}
+	// fakellm 的规则按命中次数推进，每个用例从第一步开始
+	if resp, err := http.Post(baseURL+"/__reset", "", nil); err == nil {
+		resp.Body.Close()
+	}
+	inner := config.NewChatModel(&config.Config{BaseURL: baseURL, Token: os.Getenv("MYGO_RECORD_TOKEN"), ModelName: "fakellm"})
+	m, err := config.NewReplayModel(config.ReplayModeRecord, dir, inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
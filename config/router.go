@@ -0,0 +1,306 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"agent/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ==================== 智能模型路由 ====================
+
+// RoutePolicy 路由策略
+type RoutePolicy string
+
+const (
+	RouteLight RoutePolicy = "light" // 轻型模型
+	RouteHeavy RoutePolicy = "heavy" // 重型模型
+	RouteAuto  RoutePolicy = "auto"  // 按任务复杂度自动选择
+)
+
+// ParseRoutePolicy 解析路由策略，空串视为 auto
+func ParseRoutePolicy(s string) (RoutePolicy, error) {
+	switch p := RoutePolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return RouteAuto, nil
+	case RouteLight, RouteHeavy, RouteAuto:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown route %q (want light / heavy / auto)", s)
+	}
+}
+
+// RoutingConfig 模型路由配置
+// 轻重两个模型共用 multi_api 的 API 源（未配置 multi_api 时使用 base_url），只替换模型名
+type RoutingConfig struct {
+	Enabled    bool              `yaml:"enabled" mapstructure:"enabled"`
+	LightModel string            `yaml:"light_model" mapstructure:"light_model"` // 轻型模型名
+	HeavyModel string            `yaml:"heavy_model" mapstructure:"heavy_model"` // 重型模型名，为空时沿用各源原本的模型
+	Policies   map[string]string `yaml:"policies" mapstructure:"policies"`       // 任务（用量子系统，如 emotion / debate）-> light / heavy / auto
+}
+
+// defaultRoutePolicies 默认的任务路由：情绪分析与主持人决策走轻型模型，辩论发言、评判与反思走重型模型
+var defaultRoutePolicies = map[string]RoutePolicy{
+	ScopeEmotion:    RouteLight,
+	ScopeModerator:  RouteLight,
+	ScopeDebate:     RouteHeavy,
+	ScopeJudge:      RouteHeavy,
+	ScopeReflection: RouteHeavy,
+	ScopeRefinement: RouteHeavy,
+}
+
+// policies 合并默认策略与配置中的策略
+func (c RoutingConfig) policies() (map[string]RoutePolicy, error) {
+	merged := make(map[string]RoutePolicy, len(defaultRoutePolicies)+len(c.Policies))
+	for task, p := range defaultRoutePolicies {
+		merged[task] = p
+	}
+	for task, s := range c.Policies {
+		p, err := ParseRoutePolicy(s)
+		if err != nil {
+			return nil, fmt.Errorf("routing.policies.%s: %w", task, err)
+		}
+		merged[strings.ToLower(task)] = p
+	}
+	return merged, nil
+}
+
+// sourcesFor 生成轻 / 重模型使用的源配置：复制 multi_api 的源并替换模型名
+func (c RoutingConfig) sourcesFor(cfg *Config, modelName string) *MultiAPIConfig {
+	multi := cfg.MultiAPI
+	if len(multi.Sources) == 0 {
+		multi.Sources = []APISource{{
+			Name:      "default",
+			BaseURL:   cfg.BaseURL,
+			Token:     cfg.Token,
+			ModelName: cfg.ModelName,
+			Priority:  1,
+		}}
+	}
+	sources := make([]APISource, len(multi.Sources))
+	copy(sources, multi.Sources)
+	if modelName != "" {
+		for i := range sources {
+			sources[i].ModelName = modelName
+		}
+	}
+	multi.Sources = sources
+	return &multi
+}
+
+type routeKey struct{}
+
+// WithRoute 为本次请求指定路由（覆盖按任务的策略），RouteAuto 表示不覆盖
+func WithRoute(ctx context.Context, route RoutePolicy) context.Context {
+	if route == "" || route == RouteAuto {
+		return ctx
+	}
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+func routeFrom(ctx context.Context) RoutePolicy {
+	route, _ := ctx.Value(routeKey{}).(RoutePolicy)
+	return route
+}
+
+// IntelligentModelRouter 智能模型路由器
+// 根据任务类型与复杂度选择合适的模型；任务类型沿用用量统计的子系统标注（WithUsageScope）
+type IntelligentModelRouter struct {
+	heavyModel *FaultTolerantModel // 重型模型（用于复杂任务）
+	lightModel *FaultTolerantModel // 轻型模型（用于简单任务）
+
+	mu       sync.RWMutex
+	policies map[string]RoutePolicy
+}
+
+// TaskComplexity 任务复杂度
+type TaskComplexity string
+
+const (
+	ComplexityHigh   TaskComplexity = "high"   // 高复杂度：辩论、深度分析
+	ComplexityMedium TaskComplexity = "medium" // 中复杂度：普通对话
+	ComplexityLow    TaskComplexity = "low"    // 低复杂度：简单任务
+)
+
+// NewIntelligentModelRouter 创建智能路由器（使用默认的任务策略）
+func NewIntelligentModelRouter(heavyCfg, lightCfg *MultiAPIConfig) *IntelligentModelRouter {
+	r := &IntelligentModelRouter{
+		heavyModel: NewFaultTolerantModel(heavyCfg),
+		lightModel: NewFaultTolerantModel(lightCfg),
+	}
+	r.policies, _ = RoutingConfig{}.policies()
+	return r
+}
+
+// NewRouterFromConfig 按 routing 配置创建路由器
+func NewRouterFromConfig(cfg *Config) (*IntelligentModelRouter, error) {
+	policies, err := cfg.Routing.policies()
+	if err != nil {
+		return nil, err
+	}
+	r := NewIntelligentModelRouter(
+		cfg.Routing.sourcesFor(cfg, cfg.Routing.HeavyModel),
+		cfg.Routing.sourcesFor(cfg, cfg.Routing.LightModel),
+	)
+	r.policies = policies
+	for _, m := range []*FaultTolerantModel{r.heavyModel, r.lightModel} {
+		m.SetTemperature(cfg.Temperature)
+		m.SetStructuredOutput(cfg.StructuredOutput)
+	}
+	return r, nil
+}
+
+// ApplyConfig 热更新：两个模型的源、温度、结构化输出模式与任务策略
+func (r *IntelligentModelRouter) ApplyConfig(cfg *Config) {
+	policies, err := cfg.Routing.policies()
+	if err != nil {
+		log.Error().Err(err).Msg("路由策略无效，保留原有策略")
+	} else {
+		r.mu.Lock()
+		r.policies = policies
+		r.mu.Unlock()
+	}
+
+	r.heavyModel.UpdateSources(cfg.Routing.sourcesFor(cfg, cfg.Routing.HeavyModel))
+	r.lightModel.UpdateSources(cfg.Routing.sourcesFor(cfg, cfg.Routing.LightModel))
+	for _, m := range []*FaultTolerantModel{r.heavyModel, r.lightModel} {
+		m.SetTemperature(cfg.Temperature)
+		m.SetStructuredOutput(cfg.StructuredOutput)
+	}
+}
+
+// Route 根据复杂度路由到合适的模型
+func (r *IntelligentModelRouter) Route(complexity TaskComplexity) *FaultTolerantModel {
+	switch complexity {
+	case ComplexityHigh:
+		return r.heavyModel
+	case ComplexityLow:
+		return r.lightModel
+	default:
+		return r.heavyModel
+	}
+}
+
+// Invoke 按路由结果选择模型后调用，使路由器本身可作为 Provider 使用
+func (r *IntelligentModelRouter) Invoke(ctx context.Context, messages []Message, tools []map[string]interface{}) (string, []ToolCall, error) {
+	return r.pick(ctx, messages).Invoke(ctx, messages, tools)
+}
+
+// InvokeStream 流式版本的 Invoke
+func (r *IntelligentModelRouter) InvokeStream(ctx context.Context, messages []Message, tools []map[string]interface{}, onChunk StreamHandler) (string, []ToolCall, error) {
+	return r.pick(ctx, messages).InvokeStream(ctx, messages, tools, onChunk)
+}
+
+// CacheIdentity 本次调用将被路由到的模型的标识（响应缓存的键）
+func (r *IntelligentModelRouter) CacheIdentity(ctx context.Context, messages []Message) string {
+	route, _ := r.decide(ctx, UsageScopeFrom(ctx), messages)
+	if route == RouteLight {
+		return r.lightModel.CacheIdentity(ctx, messages)
+	}
+	return r.heavyModel.CacheIdentity(ctx, messages)
+}
+
+// IsFallback 回复是否为任一模型的兜底消息
+func (r *IntelligentModelRouter) IsFallback(content string) bool {
+	return r.heavyModel.IsFallback(content) || r.lightModel.IsFallback(content)
+}
+
+// GetStats 两个模型各自的统计；sources 中的源名加上 heavy/ light/ 前缀
+func (r *IntelligentModelRouter) GetStats() map[string]interface{} {
+	heavy, light := r.heavyModel.GetStats(), r.lightModel.GetStats()
+	sources := make(map[string]SourceStats)
+	for prefix, stats := range map[string]map[string]interface{}{"heavy": heavy, "light": light} {
+		for name, s := range stats["sources"].(map[string]SourceStats) {
+			sources[prefix+"/"+name] = s
+		}
+	}
+	return map[string]interface{}{
+		"heavy":   heavy,
+		"light":   light,
+		"sources": sources,
+	}
+}
+
+// pick 选择本次调用的模型，优先级：预算降级 > 请求指定 > 任务策略 > 复杂度分析
+func (r *IntelligentModelRouter) pick(ctx context.Context, messages []Message) *FaultTolerantModel {
+	task := UsageScopeFrom(ctx)
+	route, reason := r.decide(ctx, task, messages)
+
+	model := r.heavyModel
+	if route == RouteLight {
+		model = r.lightModel
+	}
+	log.Debug().
+		Str("task", task).
+		Str("route", string(route)).
+		Str("reason", reason).
+		Msg("模型路由")
+	return model
+}
+
+// decide 决定路由（light / heavy）及原因
+func (r *IntelligentModelRouter) decide(ctx context.Context, task string, messages []Message) (RoutePolicy, string) {
+	if BudgetLevelFrom(ctx) >= BudgetDegraded {
+		return RouteLight, "budget"
+	}
+	if route := routeFrom(ctx); route != "" {
+		return route, "override"
+	}
+
+	r.mu.RLock()
+	policy := r.policies[task]
+	r.mu.RUnlock()
+	if policy == RouteLight || policy == RouteHeavy {
+		return policy, "policy"
+	}
+
+	complexity := AnalyzeComplexity(lastUserContent(messages))
+	if r.Route(complexity) == r.lightModel {
+		return RouteLight, "complexity:" + string(complexity)
+	}
+	return RouteHeavy, "complexity:" + string(complexity)
+}
+
+// lastUserContent 取最后一条用户消息的内容
+func lastUserContent(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == utils.RoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// complexTaskRunes 超过该字数（按字符而非字节）的任务视为高复杂度
+const complexTaskRunes = 200
+
+// AnalyzeComplexity 分析任务复杂度
+func AnalyzeComplexity(task string) TaskComplexity {
+	// 简单的启发式规则
+	if utf8.RuneCountInString(task) > complexTaskRunes {
+		return ComplexityHigh
+	}
+
+	// 包含辩论相关关键词
+	debateKeywords := []string{"辩论", "辩题", "正方", "反方", "立论", "质询"}
+	for _, kw := range debateKeywords {
+		if strings.Contains(task, kw) {
+			return ComplexityHigh
+		}
+	}
+
+	// 包含深度分析关键词
+	analysisKeywords := []string{"分析", "为什么", "如何", "意义", "本质"}
+	for _, kw := range analysisKeywords {
+		if strings.Contains(task, kw) {
+			return ComplexityMedium
+		}
+	}
+
+	return ComplexityLow
+}
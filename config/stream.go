@@ -0,0 +1,201 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"agent/tracing"
+	"agent/utils"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// StreamChunk 流式响应中的一个增量片段
+type StreamChunk struct {
+	Content   string          `json:"content,omitempty"`    // 本次新增的文本
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"` // 本次新增的工具调用片段
+}
+
+// ToolCallDelta 工具调用的增量片段（OpenAI 流式格式，按 Index 拼接）
+type ToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments,omitempty"`
+	} `json:"function"`
+}
+
+// StreamHandler 接收流式片段的回调
+type StreamHandler func(chunk StreamChunk)
+
+// ChannelHandler 把流式片段转发到 channel，便于调用方用 range 消费
+// 注意：channel 由调用方在 InvokeStream 返回后关闭
+func ChannelHandler(ch chan<- StreamChunk) StreamHandler {
+	return func(chunk StreamChunk) {
+		ch <- chunk
+	}
+}
+
+// InvokeStream 以流式方式调用模型（stream: true）
+// 每收到一个 delta 就回调 onChunk，返回值与 Invoke 相同：完整文本 + 拼接好的工具调用
+func (m *ChatModel) InvokeStream(ctx context.Context, messages []Message, tools []map[string]interface{}, onChunk StreamHandler) (content string, toolCalls []ToolCall, err error) {
+	if err := CheckBudget(ctx); err != nil {
+		return "", nil, err
+	}
+	reqBody, baseURL, apiKey := m.snapshot(ctx, messages, tools)
+	source := m.source(reqBody)
+	ctx, span := startSourceCall(ctx, source, messages, true)
+	start := time.Now()
+	defer func() { endSourceCall(ctx, span, source, start, err) }()
+	reqBody["stream"] = true
+	reqBody["stream_options"] = map[string]interface{}{"include_usage": true}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+utils.ChatCompletionsPath, bytes.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := m.client.Do(req)
+	if ctx.Err() != nil {
+		return "", nil, ctx.Err()
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("调用 ChatModel 流式接口失败")
+		return "", nil, errors.New("Error calling ChatModel: " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		log.Error().Int("status", resp.StatusCode).Str("body", string(respBody)).Msg("API 返回错误")
+		return "", nil, errors.New("API error: " + string(respBody))
+	}
+
+	content, calls, usage, err := ReadChatStream(resp.Body, onChunk)
+	recordUsage(ctx, reqBody["model"].(string), usage, messages, content)
+	if ctx.Err() != nil {
+		// 读取中途被取消：返回已生成的部分和取消原因（已消耗的用量照常记账）
+		return content, calls, ctx.Err()
+	}
+	return content, calls, err
+}
+
+// ReadChatStream 解析 OpenAI 风格的 SSE 流（data: {...} / data: [DONE]）
+// 文本片段与工具调用片段会实时回调，并在结束时拼接成完整结果返回
+// 请求带 stream_options.include_usage 时，最后一个事件携带 usage
+func ReadChatStream(r io.Reader, onChunk StreamHandler) (string, []ToolCall, Usage, error) {
+	var content strings.Builder
+	var calls []ToolCall
+	var usage Usage
+
+	scanner := bufio.NewScanner(r)
+	// 单行 SSE 数据可能较长（工具参数等），放宽缓冲区
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			// 空行为事件分隔符，":" 开头为注释，其余字段（event/id）忽略
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var event struct {
+			Choices []struct {
+				Delta StreamChunk `json:"delta"`
+			} `json:"choices"`
+			Usage *Usage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			log.Error().Err(err).Str("data", data).Msg("解析流式响应失败")
+			return content.String(), calls, usage, err
+		}
+		if event.Usage != nil {
+			usage = *event.Usage
+		}
+		if len(event.Choices) == 0 {
+			continue
+		}
+
+		delta := event.Choices[0].Delta
+		content.WriteString(delta.Content)
+		for _, d := range delta.ToolCalls {
+			var err error
+			if calls, err = mergeToolCallDelta(calls, d); err != nil {
+				log.Error().Err(err).Str("data", data).Msg("流式工具调用片段无效")
+				return content.String(), calls, usage, err
+			}
+		}
+
+		if onChunk != nil && (delta.Content != "" || len(delta.ToolCalls) > 0) {
+			onChunk(delta)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return content.String(), calls, usage, err
+	}
+
+	return content.String(), calls, usage, nil
+}
+
+// recordUsage 记账；接口未返回 usage 时按文本长度估算
+func recordUsage(ctx context.Context, model string, usage Usage, messages []Message, completion string) {
+	if usage.IsZero() {
+		usage = EstimateUsage(messages, completion)
+	}
+	RecordUsage(ctx, model, usage)
+
+	span := tracing.SpanFrom(ctx)
+	span.SetAttr("llm.prompt_tokens", usage.PromptTokens)
+	span.SetAttr("llm.completion_tokens", usage.CompletionTokens)
+	span.SetAttr("llm.total_tokens", usage.TotalTokens)
+	span.SetText("llm.completion", completion)
+}
+
+// maxStreamToolCalls 单次流式响应中允许的工具调用数
+const maxStreamToolCalls = 64
+
+// mergeToolCallDelta 将一个工具调用片段合并到已有结果中
+// 同一个 Index 的首个片段携带 id/name，后续片段只追加 arguments；
+// Index 来自上游，只接受已有的调用或紧随其后的新调用
+func mergeToolCallDelta(calls []ToolCall, d ToolCallDelta) ([]ToolCall, error) {
+	if d.Index < 0 || d.Index > len(calls) || d.Index >= maxStreamToolCalls {
+		return calls, errors.Errorf("invalid tool call index %d (have %d calls)", d.Index, len(calls))
+	}
+	if d.Index == len(calls) {
+		calls = append(calls, ToolCall{})
+	}
+	tc := &calls[d.Index]
+	if d.ID != "" {
+		tc.ID = d.ID
+	}
+	if d.Type != "" {
+		tc.Type = d.Type
+	}
+	if tc.Type == "" {
+		tc.Type = "function"
+	}
+	tc.Function.Name += d.Function.Name
+	tc.Function.Arguments += d.Function.Arguments
+	return calls, nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadChatStreamToolCalls(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   string
+		wantErr  bool
+		wantArgs []string
+	}{
+		{
+			name: "fragments merged by index",
+			stream: `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"a","function":{"name":"f","arguments":"{\"x\""}}]}}]}
+data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":":1}"}}]}}]}
+data: {"choices":[{"delta":{"tool_calls":[{"index":1,"id":"b","function":{"name":"g","arguments":"{}"}}]}}]}
+data: [DONE]
+`,
+			wantArgs: []string{`{"x":1}`, `{}`},
+		},
+		{
+			name:    "negative index",
+			stream:  `data: {"choices":[{"delta":{"tool_calls":[{"index":-1,"function":{"name":"f"}}]}}]}` + "\n",
+			wantErr: true,
+		},
+		{
+			name:    "index skips ahead",
+			stream:  `data: {"choices":[{"delta":{"tool_calls":[{"index":1073741824,"function":{"name":"f"}}]}}]}` + "\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, calls, _, err := ReadChatStream(strings.NewReader(tt.stream), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(calls) != len(tt.wantArgs) {
+				t.Fatalf("got %d calls, want %d", len(calls), len(tt.wantArgs))
+			}
+			for i, want := range tt.wantArgs {
+				if got := calls[i].Function.Arguments; got != want {
+					t.Errorf("calls[%d].arguments = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"agent/tracing"
+	"agent/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ==================== 结构化输出（JSON） ====================
+
+// 结构化输出模式：接口对 response_format 的支持程度不同，按配置降级
+const (
+	StructuredJSONSchema = "json_schema" // 发送完整 JSON schema（OpenAI / 新版兼容接口）
+	StructuredJSONObject = "json_object" // 只要求输出 JSON 对象，字段靠 Prompt 约束
+	StructuredOff        = "off"         // 不发送 response_format，只靠 Prompt + 解析 + 修复重试
+)
+
+// ResponseFormat 请求体中的 response_format 字段
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+// JSONSchema json_schema 模式下的 schema 描述
+type JSONSchema struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+	Strict bool                   `json:"strict,omitempty"`
+}
+
+// Validator 结构化输出解码后的校验，返回错误时会要求模型修正
+type Validator interface {
+	Validate() error
+}
+
+// StructuredOutputError 修复重试后仍无法得到合法的结构化输出
+type StructuredOutputError struct {
+	Schema   string // schema 名称
+	Raw      string // 最后一次的模型输出
+	Attempts int    // 调用次数
+	Err      error  // 最后一次的解码 / 校验错误
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("structured output %s invalid after %d attempts: %v", e.Schema, e.Attempts, e.Err)
+}
+
+func (e *StructuredOutputError) Unwrap() error {
+	return e.Err
+}
+
+// maxStructuredRepairs JSON 无效时要求模型修正的最多次数
+const maxStructuredRepairs = 2
+
+type responseFormatKey struct{}
+
+// WithResponseFormat 要求后续调用按给定格式输出；模型客户端据此设置 response_format
+func WithResponseFormat(ctx context.Context, format *ResponseFormat) context.Context {
+	return context.WithValue(ctx, responseFormatKey{}, format)
+}
+
+// ResponseFormatFrom ctx 上的输出格式，未设置时返回 nil
+func ResponseFormatFrom(ctx context.Context) *ResponseFormat {
+	format, _ := ctx.Value(responseFormatKey{}).(*ResponseFormat)
+	return format
+}
+
+// applyResponseFormat 按结构化输出模式把 ctx 上的格式写入请求体
+func applyResponseFormat(ctx context.Context, reqBody map[string]interface{}, mode string) {
+	format := ResponseFormatFrom(ctx)
+	if format == nil {
+		return
+	}
+	switch mode {
+	case StructuredOff:
+	case StructuredJSONObject:
+		reqBody["response_format"] = map[string]string{"type": "json_object"}
+	default:
+		reqBody["response_format"] = format
+	}
+}
+
+// InvokeStructured 以 JSON schema 模式调用模型，把输出解码到 out（指针）并校验；
+// 输出不是合法 JSON 或校验失败时，把错误反馈给模型要求修正，最多重试 maxStructuredRepairs 次。
+// out 只在校验通过后才被写入；响应缓存只保存首次调用且校验通过的输出，修正调用不读写缓存
+func InvokeStructured(ctx context.Context, p Provider, messages []Message, schema *JSONSchema, out interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, "structured."+schema.Name)
+	defer func() { span.End(err) }()
+
+	ctx = WithResponseFormat(ctx, &ResponseFormat{Type: StructuredJSONSchema, JSONSchema: schema})
+	attempt := append([]Message(nil), messages...)
+
+	var (
+		raw     string
+		lastErr error
+	)
+	for i := 0; i <= maxStructuredRepairs; i++ {
+		callCtx, pending := withPendingStore(ctx)
+		if i > 0 {
+			callCtx, pending = withoutCache(ctx), nil
+		}
+		content, _, err := p.Invoke(callCtx, attempt, nil)
+		if err != nil {
+			return err
+		}
+		raw = content
+		span.SetAttr("structured.attempts", i+1)
+
+		if lastErr = decodeStructured(content, out); lastErr == nil {
+			pending.Commit()
+			return nil
+		}
+		log.Warn().
+			Str("schema", schema.Name).
+			Int("attempt", i+1).
+			Err(lastErr).
+			Msg("结构化输出无效，要求模型修正")
+
+		attempt = append(attempt,
+			Message{Role: utils.RoleAssistant, Content: content},
+			Message{Role: utils.RoleUser, Content: repairPrompt(schema, lastErr)},
+		)
+	}
+
+	return &StructuredOutputError{
+		Schema:   schema.Name,
+		Raw:      raw,
+		Attempts: maxStructuredRepairs + 1,
+		Err:      lastErr,
+	}
+}
+
+// decodeStructured 从模型输出中取出 JSON 对象，解码到 out 的副本并校验，通过后才复制回 out
+// 每次尝试都从调用方给的初始值开始（保留校验用的未导出字段），失败的尝试不会在 out 里留下字段
+func decodeStructured(content string, out interface{}) error {
+	text := extractJSON(content)
+	if text == "" {
+		return fmt.Errorf("no JSON object found in output")
+	}
+	fresh := reflect.New(reflect.TypeOf(out).Elem())
+	fresh.Elem().Set(reflect.ValueOf(out).Elem())
+	if err := json.Unmarshal([]byte(text), fresh.Interface()); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if v, ok := fresh.Interface().(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+	}
+	reflect.ValueOf(out).Elem().Set(fresh.Elem())
+	return nil
+}
+
+// extractJSON 去掉 ```json 代码块等包装，取第一个 { 到最后一个 } 之间的内容
+func extractJSON(content string) string {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return ""
+	}
+	return content[start : end+1]
+}
+
+// repairPrompt 要求模型修正输出的提示
+func repairPrompt(schema *JSONSchema, err error) string {
+	schemaJSON, _ := json.Marshal(schema.Schema)
+	return fmt.Sprintf("你的上一次输出无法使用：%v\n请只输出一个符合以下 JSON Schema 的 JSON 对象，不要包含任何其他文字：\n%s", err, schemaJSON)
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// scriptedProvider 依次返回预设回复的假模型，用完后重复最后一条
+type scriptedProvider struct {
+	replies []string
+	calls   int
+}
+
+func (p *scriptedProvider) Invoke(ctx context.Context, messages []Message, tools []map[string]interface{}) (string, []ToolCall, error) {
+	reply := p.replies[min(p.calls, len(p.replies)-1)]
+	p.calls++
+	return reply, nil, nil
+}
+
+type scoreOutput struct {
+	Speaker string `json:"speaker"`
+	Score   int    `json:"score"`
+	Note    string `json:"note,omitempty"`
+
+	limit int // 调用方设置的分数上限，校验时使用
+}
+
+func (o *scoreOutput) Validate() error {
+	limit := o.limit
+	if limit == 0 {
+		limit = 10
+	}
+	if o.Score < 0 || o.Score > limit {
+		return fmt.Errorf("score must be between 0 and %d, got %d", limit, o.Score)
+	}
+	return nil
+}
+
+var scoreSchema = &JSONSchema{Name: "score", Schema: map[string]interface{}{"type": "object"}}
+
+func TestInvokeStructured(t *testing.T) {
+	tests := []struct {
+		name      string
+		replies   []string
+		limit     int
+		want      scoreOutput
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "valid first reply",
+			replies:   []string{"```json\n{\"speaker\": \"tomori\", \"score\": 8}\n```"},
+			want:      scoreOutput{Speaker: "tomori", Score: 8},
+			wantCalls: 1,
+		},
+		{
+			name:      "invalid JSON is repaired",
+			replies:   []string{"score: 8", `{"speaker": "tomori", "score": 8}`},
+			want:      scoreOutput{Speaker: "tomori", Score: 8},
+			wantCalls: 2,
+		},
+		{
+			// 失败的尝试里的 note 不能留到最终结果中
+			name:      "failed attempt leaves no fields behind",
+			replies:   []string{`{"speaker": "soyo", "score": 11, "note": "太高了"}`, `{"speaker": "tomori", "score": 8}`},
+			want:      scoreOutput{Speaker: "tomori", Score: 8},
+			wantCalls: 2,
+		},
+		{
+			name:      "validation keeps the caller's unexported fields",
+			replies:   []string{`{"speaker": "tomori", "score": 8}`, `{"speaker": "tomori", "score": 4}`},
+			limit:     5,
+			want:      scoreOutput{Speaker: "tomori", Score: 4, limit: 5},
+			wantCalls: 2,
+		},
+		{
+			name:      "out is untouched when every attempt fails",
+			replies:   []string{`{"speaker": "soyo", "score": 11, "note": "太高了"}`},
+			want:      scoreOutput{Speaker: "original"},
+			wantCalls: maxStructuredRepairs + 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &scriptedProvider{replies: tt.replies}
+			out := scoreOutput{Speaker: "original", limit: tt.limit}
+
+			err := InvokeStructured(context.Background(), p, []Message{{Role: "user", Content: "打分"}}, scoreSchema, &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			var structErr *StructuredOutputError
+			if tt.wantErr && (!errors.As(err, &structErr) || structErr.Attempts != tt.wantCalls) {
+				t.Errorf("err = %#v, want StructuredOutputError after %d attempts", err, tt.wantCalls)
+			}
+			if out != tt.want {
+				t.Errorf("out = %+v, want %+v", out, tt.want)
+			}
+			if p.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", p.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestInvokeStructuredCache(t *testing.T) {
+	const valid = `{"speaker": "tomori", "score": 8}`
+	const invalid = `{"speaker": "tomori", "score": 11}`
+
+	tests := []struct {
+		name      string
+		replies   []string
+		wantCalls int // 两次相同的结构化调用一共访问模型的次数
+		wantSize  int
+	}{
+		{
+			name:      "validated first reply is cached",
+			replies:   []string{valid},
+			wantCalls: 1,
+			wantSize:  1,
+		},
+		{
+			name:      "invalid first reply is not cached and repairs bypass the cache",
+			replies:   []string{invalid, valid},
+			wantCalls: 3, // 第一次：无效 + 修正；第二次：仍需调用模型，拿到上次修正后的回复
+			wantSize:  1, // 第二次的首次回复校验通过，才被缓存
+		},
+		{
+			name:      "nothing is cached when every attempt fails",
+			replies:   []string{invalid},
+			wantCalls: 2 * (maxStructuredRepairs + 1),
+			wantSize:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &scriptedProvider{replies: tt.replies}
+			cache := NewResponseCache(10, 0)
+			m := NewCachedModel(p, cache)
+			ctx := WithCacheable(context.Background())
+			messages := []Message{{Role: "user", Content: "打分"}}
+
+			for i := 0; i < 2; i++ {
+				var out scoreOutput
+				InvokeStructured(ctx, m, messages, scoreSchema, &out)
+			}
+			if p.calls != tt.wantCalls {
+				t.Errorf("model calls = %d, want %d", p.calls, tt.wantCalls)
+			}
+			if size := cache.Stats().Size; size != tt.wantSize {
+				t.Errorf("cache size = %d, want %d", size, tt.wantSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"context"
+	"time"
+
+	"agent/metrics"
+	"agent/tracing"
+)
+
+// ==================== 指标与链路追踪 ====================
+
+// TracingConfig 链路追踪配置（修改需重启）
+type TracingConfig struct {
+	Enabled      bool              `yaml:"enabled" mapstructure:"enabled"`
+	Exporter     string            `yaml:"exporter" mapstructure:"exporter"`             // jsonl（本地文件）/ otlp（OTLP/HTTP 采集器）
+	Path         string            `yaml:"path" mapstructure:"path"`                     // jsonl 输出文件
+	Endpoint     string            `yaml:"endpoint" mapstructure:"endpoint"`             // otlp 地址，默认 http://localhost:4318/v1/traces
+	Headers      map[string]string `yaml:"headers" mapstructure:"headers"`               // otlp 请求头（鉴权等）
+	ServiceName  string            `yaml:"service_name" mapstructure:"service_name"`     // 服务名
+	MaxTextChars int               `yaml:"max_text_chars" mapstructure:"max_text_chars"` // Prompt / 回复截断长度（字符）
+}
+
+// 追踪导出方式
+const (
+	TraceExporterJSONL = "jsonl"
+	TraceExporterOTLP  = "otlp"
+)
+
+// NewTracer 按配置创建 Tracer，未启用时返回 nil
+func (c TracingConfig) NewTracer() (*tracing.Tracer, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+
+	var exporter tracing.Exporter
+	switch c.Exporter {
+	case TraceExporterOTLP:
+		exporter = tracing.NewOTLPExporter(c.Endpoint, c.ServiceName, c.Headers)
+	default:
+		jsonl, err := tracing.NewJSONLExporter(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		exporter = jsonl
+	}
+	return tracing.NewTracer(exporter, tracing.Options{
+		ServiceName:  c.ServiceName,
+		MaxTextChars: c.MaxTextChars,
+	}), nil
+}
+
+// startSourceCall 为一次 API 源调用开启 span（模型、源、子系统、最后一条消息）
+func startSourceCall(ctx context.Context, source APISource, messages []Message, stream bool) (context.Context, *tracing.Span) {
+	ctx, span := tracing.StartKind(ctx, "llm.call", tracing.KindClient)
+	if span != nil {
+		span.SetAttr("llm.source", source.Name)
+		span.SetAttr("llm.model", source.ModelName)
+		span.SetAttr("llm.stream", stream)
+		span.SetAttr("llm.scope", UsageScopeFrom(ctx))
+		span.SetAttr("llm.messages", len(messages))
+		if len(messages) > 0 {
+			span.SetText("llm.prompt", messages[len(messages)-1].Content)
+		}
+	}
+	return ctx, span
+}
+
+// endSourceCall 结束 API 源调用：记录指标（/metrics）并结束 span
+func endSourceCall(ctx context.Context, span *tracing.Span, source APISource, start time.Time, err error) {
+	metrics.ObserveLLMCall(source.Name, source.ModelName, start, err, err != nil && ctx.Err() != nil)
+	span.End(err)
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ==================== Token 用量与费用统计 ====================
+
+// Usage 一次调用的 token 用量（OpenAI 响应中的 usage 字段）
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add 累加用量
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// IsZero 是否没有任何用量（部分兼容接口不返回 usage）
+func (u Usage) IsZero() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0 && u.TotalTokens == 0
+}
+
+// 用量归属的子系统
+const (
+	ScopeChat       = "chat"       // 一对一对话
+	ScopeEmotion    = "emotion"    // AI 情绪分析
+	ScopeReAct      = "react"      // Agent 的 ReAct 循环
+	ScopeReflection = "reflection" // 反思
+	ScopeRefinement = "refinement" // 迭代优化（自我评估 + 反思）
+	ScopeDebate     = "debate"     // 辩论发言
+	ScopeModerator  = "moderator"  // 主持人决策
+	ScopeJudge      = "judge"      // 辩论评判
+	ScopeOther      = "other"      // 未标注
+)
+
+// ModelPrice 单个模型的价格（每百万 token）
+type ModelPrice struct {
+	Input  float64 `yaml:"input" mapstructure:"input"`   // 输入（prompt）单价
+	Output float64 `yaml:"output" mapstructure:"output"` // 输出（completion）单价
+}
+
+// PricingConfig 价格表，模型名不区分大小写；未配置的模型费用记为 0
+type PricingConfig struct {
+	Currency string                `yaml:"currency" mapstructure:"currency"`
+	Models   map[string]ModelPrice `yaml:"models" mapstructure:"models"`
+}
+
+// Cost 计算一次调用的费用
+func (p PricingConfig) Cost(model string, u Usage) float64 {
+	price, ok := p.Models[strings.ToLower(model)]
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*price.Input + float64(u.CompletionTokens)*price.Output) / 1e6
+}
+
+// UsageTotal 一组调用的用量合计
+type UsageTotal struct {
+	Calls int `json:"calls"`
+	Usage
+	Cost float64 `json:"cost"`
+}
+
+func (t *UsageTotal) add(u Usage, cost float64) {
+	t.Calls++
+	t.Usage.Add(u)
+	t.Cost += cost
+}
+
+// UsageSummary 用量汇总：总计 + 按子系统 / 模型 / 发言者拆分
+type UsageSummary struct {
+	Currency string `json:"currency,omitempty"`
+	UsageTotal
+	ByScope   map[string]UsageTotal `json:"by_scope,omitempty"`
+	ByModel   map[string]UsageTotal `json:"by_model,omitempty"`
+	BySpeaker map[string]UsageTotal `json:"by_speaker,omitempty"`
+}
+
+// UsageTracker 线程安全的用量累计器
+// 通过 WithUsageTracker 挂到 context 上，模型调用完成后自动记账
+type UsageTracker struct {
+	mu        sync.Mutex
+	pricing   PricingConfig
+	total     UsageTotal
+	byScope   map[string]*UsageTotal
+	byModel   map[string]*UsageTotal
+	bySpeaker map[string]*UsageTotal
+}
+
+// NewUsageTracker 创建用量累计器
+func NewUsageTracker(pricing PricingConfig) *UsageTracker {
+	return &UsageTracker{
+		pricing:   pricing,
+		byScope:   make(map[string]*UsageTotal),
+		byModel:   make(map[string]*UsageTotal),
+		bySpeaker: make(map[string]*UsageTotal),
+	}
+}
+
+// SetPricing 更新价格表（只影响之后的调用）
+func (t *UsageTracker) SetPricing(pricing PricingConfig) {
+	t.mu.Lock()
+	t.pricing = pricing
+	t.mu.Unlock()
+}
+
+// Record 记录一次调用，返回按价格表计算的费用
+func (t *UsageTracker) Record(model, scope, speaker string, u Usage) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cost := t.pricing.Cost(model, u)
+
+	t.total.add(u, cost)
+	bucket(t.byScope, scope).add(u, cost)
+	bucket(t.byModel, model).add(u, cost)
+	if speaker != "" {
+		bucket(t.bySpeaker, speaker).add(u, cost)
+	}
+	return cost
+}
+
+// Total 当前总计
+func (t *UsageTracker) Total() UsageTotal {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total
+}
+
+// Summary 生成汇总快照（nil 累计器返回 nil）
+func (t *UsageTracker) Summary() *UsageSummary {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &UsageSummary{
+		Currency:   t.pricing.Currency,
+		UsageTotal: t.total,
+		ByScope:    snapshotTotals(t.byScope),
+		ByModel:    snapshotTotals(t.byModel),
+		BySpeaker:  snapshotTotals(t.bySpeaker),
+	}
+}
+
+func bucket(m map[string]*UsageTotal, key string) *UsageTotal {
+	t, ok := m[key]
+	if !ok {
+		t = &UsageTotal{}
+		m[key] = t
+	}
+	return t
+}
+
+func snapshotTotals(m map[string]*UsageTotal) map[string]UsageTotal {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]UsageTotal, len(m))
+	for k, v := range m {
+		out[k] = *v
+	}
+	return out
+}
+
+// ==================== context 传递 ====================
+
+type usageKey struct{}
+
+// usageLabels context 中的记账信息：累计器列表与归属标签
+type usageLabels struct {
+	trackers []*UsageTracker
+	scope    string
+	speaker  string
+}
+
+func labelsFrom(ctx context.Context) usageLabels {
+	if l, ok := ctx.Value(usageKey{}).(usageLabels); ok {
+		return l
+	}
+	return usageLabels{}
+}
+
+// WithUsageTracker 追加累计器；同一次调用会记入 context 上的所有累计器（如本轮 + 会话 + 全局）
+func WithUsageTracker(ctx context.Context, trackers ...*UsageTracker) context.Context {
+	l := labelsFrom(ctx)
+	l.trackers = append(append([]*UsageTracker(nil), l.trackers...), trackers...)
+	return context.WithValue(ctx, usageKey{}, l)
+}
+
+// WithUsageScope 标注后续调用所属的子系统（ScopeEmotion / ScopeReAct ...）
+func WithUsageScope(ctx context.Context, scope string) context.Context {
+	l := labelsFrom(ctx)
+	l.scope = scope
+	return context.WithValue(ctx, usageKey{}, l)
+}
+
+// WithUsageSpeaker 标注后续调用所属的发言者（辩论）
+func WithUsageSpeaker(ctx context.Context, speaker string) context.Context {
+	l := labelsFrom(ctx)
+	l.speaker = speaker
+	return context.WithValue(ctx, usageKey{}, l)
+}
+
+// UsageScopeFrom 当前调用所属的子系统，未标注时返回空串（模型路由据此选择策略）
+func UsageScopeFrom(ctx context.Context) string {
+	return labelsFrom(ctx).scope
+}
+
+// RecordUsage 由 Provider 在调用完成后调用，把用量记入 context 上的所有累计器
+func RecordUsage(ctx context.Context, model string, u Usage) {
+	l := labelsFrom(ctx)
+	if len(l.trackers) == 0 || u.IsZero() {
+		return
+	}
+	scope := l.scope
+	if scope == "" {
+		scope = ScopeOther
+	}
+	for _, t := range l.trackers {
+		t.Record(model, scope, l.speaker, u)
+	}
+}
+
+// ==================== 用量估算 ====================
+
+// EstimateUsage 接口未返回 usage 时的粗略估算
+func EstimateUsage(messages []Message, completion string) Usage {
+	var prompt int
+	for _, m := range messages {
+		prompt += EstimateTokens(m.Content) + 4 // 每条消息的角色等固定开销
+	}
+	u := Usage{PromptTokens: prompt, CompletionTokens: EstimateTokens(completion)}
+	u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	return u
+}
+
+// EstimateTokens 粗略估算 token 数：中日韩字符约 1 个 token，其余约 4 个字符 1 个 token
+func EstimateTokens(text string) int {
+	var cjk, other int
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
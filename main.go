@@ -2,14 +2,23 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"agent/api"
 	"agent/config"
 	"agent/philosopher"
+	"agent/tracing"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -21,33 +30,172 @@ func main() {
 
 	// 命令行参数
 	mode := flag.String("mode", "cli", "运行模式: cli(命令行) / server(API服务器) / debate(讨论模式)")
-	port := flag.String("port", ":8080", "API 服务器端口")
+	port := flag.String("port", "", "API 服务器端口（默认使用配置文件中的 server.port）")
 	philosopherType := flag.String("member", "tomori", "选择成员: tomori/anon/rana/soyo/taki")
+	cassetteMode := flag.String("cassette-mode", "", "模型录制回放: record(录制) / replay(离线回放)，默认关闭")
+	cassetteDir := flag.String("cassette-dir", "testdata/cassettes", "录制回放的 cassette 目录")
+	bill := flag.Bool("bill", false, "每次回复后打印 token 用量与费用")
+	routeFlag := flag.String("route", "", "模型路由: light / heavy / auto（需在配置中启用 routing）")
+	formatFlag := flag.String("format", "", "讨论赛制: standard / oxford / british_parliamentary / lincoln_douglas / band_meeting")
+	judges := flag.Int("judges", 0, "讨论结束后评分的评委人数（0~3），默认 0 不评判")
+	humanSide := flag.String("human", "", "以人类身份加入讨论的一方: pro / con，默认不加入")
+	humanTimeout := flag.Int("human-timeout", 180, "等待你发言的秒数")
+	autoPass := flag.Bool("auto-pass", false, "等待发言超时后自动跳过（否则结束讨论）")
 	flag.Parse()
 
+	route, err := config.ParseRoutePolicy(*routeFlag)
+	if err != nil {
+		log.Fatal().Err(err).Msg("无效的 -route 参数")
+	}
+
 	// 加载配置
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatal().Err(err).Msg("加载配置失败")
 	}
 
+	// 链路追踪
+	tracer, err := cfg.Tracing.NewTracer()
+	if err != nil {
+		log.Fatal().Err(err).Msg("创建链路追踪失败")
+	}
+	if tracer != nil {
+		tracing.SetTracer(tracer)
+		defer tracer.Shutdown(context.Background())
+		log.Info().Str("exporter", cfg.Tracing.Exporter).Msg("启用链路追踪")
+	}
+
+	// 自定义赛制
+	if cfg.Debate.FormatsPath != "" {
+		formats, err := philosopher.LoadDebateFormats(cfg.Debate.FormatsPath)
+		if err != nil {
+			log.Fatal().Err(err).Msg("加载赛制失败")
+		}
+		log.Info().Int("count", len(formats)).Str("path", cfg.Debate.FormatsPath).Msg("已加载自定义赛制")
+	}
+
 	// 创建模型
-	model := config.NewChatModel(cfg)
+	model := newModel(cfg)
+
+	// 监听配置文件变化，热更新模型参数（温度、模型名、API 源优先级等）；服务器模式下再注册服务器设置
+	if r, ok := model.(config.Reloadable); ok {
+		onReload(r.ApplyConfig)
+	}
+	watchConfig(cfg)
+
+	// 录制回放：录制真实调用，或离线从 cassette 回放
+	if *cassetteMode != "" {
+		replay, err := config.NewReplayModel(config.ReplayMode(*cassetteMode), *cassetteDir, model)
+		if err != nil {
+			log.Fatal().Err(err).Msg("创建录制回放模型失败")
+		}
+		log.Info().Str("mode", *cassetteMode).Str("dir", *cassetteDir).Msg("启用模型录制回放")
+		model = replay
+	}
 
 	switch *mode {
 	case "cli":
-		runCLI(model, philosopher.PhilosopherType(*philosopherType))
+		runCLI(model, cfg, philosopher.PhilosopherType(*philosopherType), *bill, route)
 	case "server":
-		runServer(model, *port)
+		addr := *port
+		if addr == "" {
+			addr = fmt.Sprintf(":%d", cfg.Server.Port)
+		}
+		runServer(model, cfg, addr)
 	case "debate":
-		runDebateDemo(model)
+		var human *philosopher.HumanConfig
+		if *humanSide != "" {
+			human = &philosopher.HumanConfig{
+				Side:           philosopher.DebateSide(*humanSide),
+				TimeoutSeconds: *humanTimeout,
+				AutoPass:       *autoPass,
+				Input:          newCLIHumanInput(),
+			}
+			if err := human.Validate(); err != nil {
+				log.Fatal().Err(err).Msg("无效的 -human 参数")
+			}
+		}
+		runDebateDemo(model, cfg, *formatFlag, *judges, human, route)
 	default:
 		log.Fatal().Str("mode", *mode).Msg("未知的运行模式")
 	}
 }
 
+// 配置热更新时依次调用的回调
+var (
+	reloadMu  sync.Mutex
+	reloaders []func(cfg *config.Config)
+)
+
+// onReload 注册配置热更新回调
+func onReload(fn func(cfg *config.Config)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	reloaders = append(reloaders, fn)
+}
+
+// watchConfig 监听配置文件变化并调用已注册的回调：
+// 模型参数、温度、预算、价格表、请求时限、情绪分析、缓存容量与会话清除时限会热更新；
+// 启动时才读取的字段（见 restartRequired）变化时记录警告，需要重启生效
+func watchConfig(cfg *config.Config) {
+	current := cfg
+	config.WatchConfig(func(next *config.Config) {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+		for _, fn := range reloaders {
+			fn(next)
+		}
+		if fields := restartRequired(current, next); len(fields) > 0 {
+			log.Warn().Strs("fields", fields).Msg("以下配置需要重启才能生效")
+		}
+		current = next
+	})
+}
+
+// restartRequired 新旧配置中变化了、但只在启动时读取的字段
+func restartRequired(old, next *config.Config) []string {
+	var fields []string
+	changed := func(name string, differ bool) {
+		if differ {
+			fields = append(fields, name)
+		}
+	}
+	changed("server.port", old.Server.Port != next.Server.Port)
+	changed("server.cors_enabled", old.Server.CORSEnabled != next.Server.CORSEnabled)
+	changed("sessions.path", old.Sessions.Path != next.Sessions.Path)
+	changed("cache.path", old.Cache.Path != next.Cache.Path)
+	changed("debate.formats_path", old.Debate.FormatsPath != next.Debate.FormatsPath)
+	changed("routing.enabled", old.Routing.Enabled != next.Routing.Enabled)
+	changed("multi_api.sources", (len(old.MultiAPI.Sources) > 0) != (len(next.MultiAPI.Sources) > 0))
+	changed("tracing", !reflect.DeepEqual(old.Tracing, next.Tracing))
+	return fields
+}
+
+// newModel 根据配置创建模型：启用路由时使用轻 / 重模型路由器，配置了 multi_api.sources 时使用容错模型，否则使用单一模型
+func newModel(cfg *config.Config) config.Provider {
+	if cfg.Routing.Enabled {
+		router, err := config.NewRouterFromConfig(cfg)
+		if err != nil {
+			log.Fatal().Err(err).Msg("创建模型路由器失败")
+		}
+		log.Info().
+			Str("light", cfg.Routing.LightModel).
+			Str("heavy", cfg.Routing.HeavyModel).
+			Msg("启用轻 / 重模型路由")
+		return router
+	}
+	if len(cfg.MultiAPI.Sources) > 0 {
+		log.Info().Int("sources", len(cfg.MultiAPI.Sources)).Msg("使用多 API 源容错模型")
+		ft := config.NewFaultTolerantModel(&cfg.MultiAPI)
+		ft.SetTemperature(cfg.Temperature)
+		ft.SetStructuredOutput(cfg.StructuredOutput)
+		return ft
+	}
+	return config.NewChatModel(cfg)
+}
+
 // runCLI 运行命令行交互模式
-func runCLI(model *config.ChatModel, pType philosopher.PhilosopherType) {
+func runCLI(model config.Provider, cfg *config.Config, pType philosopher.PhilosopherType, bill bool, route config.RoutePolicy) {
 	fmt.Println("╔══════════════════════════════════════════════════════════════╗")
 	fmt.Println("║                     MyGO!!!!! Chat                           ║")
 	fmt.Println("║                   迷子でもいい v1.0                          ║")
@@ -60,12 +208,17 @@ func runCLI(model *config.ChatModel, pType philosopher.PhilosopherType) {
 	// 创建角色
 	p := philosopher.NewPhilosopher(pType, model)
 	fmt.Printf("🎸 你正在与 %s 对话\n", p.Name)
-	fmt.Println("输入 'quit' 退出，输入 'switch' 切换成员")
+	fmt.Println("输入 'quit' 退出，输入 'switch' 切换成员，输入 'bill' 查看账单，输入 'regen [温度]' 重新生成上一条回复，回复过程中按 Ctrl+C 可打断")
 	fmt.Println("────────────────────────────────────────────────────────────────")
 	fmt.Println()
 
 	// 创建情绪分析器
 	emotionAnalyzer := philosopher.NewEmotionAnalyzer(model)
+	emotionAnalyzer.SetAIAnalysis(cfg.Emotion.EnableAIAnalysis)
+	onReload(func(next *config.Config) { emotionAnalyzer.SetAIAnalysis(next.Emotion.EnableAIAnalysis) })
+
+	// 用量累计（整个 CLI 会话）
+	usage := config.NewUsageTracker(cfg.Pricing)
 
 	// 对话历史
 	var messages []config.Message
@@ -87,6 +240,11 @@ func runCLI(model *config.ChatModel, pType philosopher.PhilosopherType) {
 			break
 		}
 
+		if input == "bill" {
+			printBill(usage.Summary())
+			continue
+		}
+
 		if input == "switch" {
 			fmt.Println("\nMyGO!!!!! 成员:")
 			fmt.Println("  1. tomori - 高松灯（主唱·感性怪女生）")
@@ -104,8 +262,52 @@ func runCLI(model *config.ChatModel, pType philosopher.PhilosopherType) {
 			continue
 		}
 
+		// 出错或被打断时恢复到本轮之前的历史
+		previous := messages
+		baseCtx := config.WithRoute(context.Background(), route)
+
+		// regen：丢掉上一条回复，用同一条用户消息重新生成，可指定温度
+		if input == "regen" || strings.HasPrefix(input, "regen ") {
+			last := -1
+			for i := len(messages) - 1; i >= 0; i-- {
+				if messages[i].Role == "user" {
+					last = i
+					break
+				}
+			}
+			if last < 0 {
+				fmt.Println("（还没有可以重新生成的回复）")
+				continue
+			}
+			if arg := strings.TrimSpace(strings.TrimPrefix(input, "regen")); arg != "" {
+				temperature, err := strconv.ParseFloat(arg, 64)
+				if err != nil || temperature < 0 || temperature > 2 {
+					fmt.Println("（温度应为 0~2 之间的数字）")
+					continue
+				}
+				baseCtx = config.WithTemperature(baseCtx, temperature)
+			}
+			input = messages[last].Content
+			messages = messages[:last:last]
+		}
+
+		// 本轮回复期间 Ctrl+C 只打断当前回复，不退出程序
+		ctx, stop := signal.NotifyContext(baseCtx, os.Interrupt)
+		turn := config.NewUsageTracker(cfg.Pricing)
+		ctx = config.WithUsageTracker(ctx, turn, usage)
+		ctx = config.WithBudget(ctx,
+			config.NewBudget(config.BudgetScopeTurn, turn, cfg.Budget.TurnTokens, 0, cfg.Budget.DegradeRatio),
+			config.NewBudget(config.BudgetScopeSession, usage, cfg.Budget.SessionDailyTokens, cfg.Budget.SessionDailyCost, cfg.Budget.DegradeRatio),
+		)
+		if err := config.CheckBudget(ctx); err != nil {
+			stop()
+			messages = previous
+			fmt.Printf("（今天的预算已经用完了：%v）\n\n", err)
+			continue
+		}
+
 		// 分析情绪
-		emotionLevel := emotionAnalyzer.Analyze(input)
+		emotionLevel := emotionAnalyzer.Analyze(ctx, input)
 
 		// 添加用户消息
 		messages = append(messages, config.Message{
@@ -113,16 +315,35 @@ func runCLI(model *config.ChatModel, pType philosopher.PhilosopherType) {
 			Content: input,
 		})
 
-		// 获取响应
+		// 获取响应（流式输出，边生成边打印）
 		fmt.Printf("\n%s: ", p.Name)
-		response, err := p.Chat(messages, emotionLevel)
+		response, err := p.ChatStream(ctx, messages, emotionLevel, func(token string) {
+			fmt.Print(token)
+		})
+		stop()
+		if errors.Is(err, context.Canceled) {
+			// 打断的这一轮不计入历史
+			messages = previous
+			fmt.Print("（已打断）\n\n")
+			continue
+		}
+		var budgetErr *config.BudgetExceededError
+		if errors.As(err, &budgetErr) {
+			messages = previous
+			fmt.Printf("（预算用完了：%v）\n\n", err)
+			continue
+		}
 		if err != nil {
 			log.Error().Err(err).Msg("对话失败")
+			messages = previous
 			fmt.Println("（系统错误，请重试）")
 			continue
 		}
 
-		fmt.Println(response)
+		fmt.Println()
+		if bill {
+			printTurnBill(turn.Total(), usage.Total(), cfg.Pricing.Currency)
+		}
 		fmt.Println()
 
 		// 添加助手消息
@@ -134,18 +355,33 @@ func runCLI(model *config.ChatModel, pType philosopher.PhilosopherType) {
 }
 
 // runServer 运行 API 服务器
-func runServer(model *config.ChatModel, port string) {
+func runServer(model config.Provider, cfg *config.Config, port string) {
 	fmt.Println("╔══════════════════════════════════════════════════════════════╗")
 	fmt.Println("║              MyGO!!!!! Chat API Server v1.0                  ║")
 	fmt.Println("╚══════════════════════════════════════════════════════════════╝")
 	fmt.Println()
 
+	sessions, err := api.NewSQLiteSessionStore(cfg.Sessions.Path)
+	if err != nil {
+		log.Fatal().Err(err).Str("path", cfg.Sessions.Path).Msg("打开会话存储失败")
+	}
+	defer sessions.Close()
+
 	server := api.NewServer(model)
+	server.ApplyConfig(cfg)
+	onReload(server.ApplyConfig)
+	server.SetSessionStore(sessions)
 	fmt.Printf("🚀 API 服务器启动于 http://localhost%s\n", port)
 	fmt.Println()
 	fmt.Println("可用接口:")
 	fmt.Println("  POST /api/chat          - 一对一对话")
+	fmt.Println("  POST /api/chat/stream   - 一对一对话（SSE 流式）")
+	fmt.Println("  GET  /api/sessions      - 会话列表")
 	fmt.Println("  POST /api/debate/start  - 开始辩论")
+	fmt.Println("  GET  /api/debate/events - 订阅辩论事件（SSE）")
+	fmt.Println("  POST /api/debate/{id}/speak - 人类参与者提交发言")
+	fmt.Println("  POST /api/debate/{id}/pause|resume|cancel - 暂停 / 继续 / 取消辩论")
+	fmt.Println("  POST /api/debate/{id}/note - 插入主持人提示")
 	fmt.Println("  GET  /api/philosophers  - 获取哲学家列表")
 	fmt.Println("  GET  /api/health        - 健康检查")
 	fmt.Println()
@@ -156,7 +392,12 @@ func runServer(model *config.ChatModel, port string) {
 }
 
 // runDebateDemo 运行讨论演示
-func runDebateDemo(model *config.ChatModel) {
+func runDebateDemo(model config.Provider, cfg *config.Config, formatName string, judges int, human *philosopher.HumanConfig, route config.RoutePolicy) {
+	format, err := philosopher.GetDebateFormat(formatName)
+	if err != nil {
+		log.Fatal().Err(err).Msg("无效的 -format 参数")
+	}
+
 	fmt.Println("╔══════════════════════════════════════════════════════════════╗")
 	fmt.Println("║                   MyGO!!!!! 乐队讨论会                       ║")
 	fmt.Println("║                   Band Meeting Time                          ║")
@@ -178,11 +419,29 @@ func runDebateDemo(model *config.ChatModel) {
 			philosopher.ShiinaTaki,
 			philosopher.NagasakiSoyo,
 		},
+		Format: format,
+		Judges: judges,
+		Human:  human,
+	}
+	// 一对一等限制人数的赛制只保留每方靠前的成员（人类参与者占一个位置）
+	if n := format.MaxPerSide; n > 0 {
+		proMax, conMax := n, n
+		if human != nil && human.Side == philosopher.SideCon {
+			conMax--
+		} else if human != nil {
+			proMax--
+		}
+		debateConfig.ProPhilosophers = debateConfig.ProPhilosophers[:min(proMax, len(debateConfig.ProPhilosophers))]
+		debateConfig.ConPhilosophers = debateConfig.ConPhilosophers[:min(conMax, len(debateConfig.ConPhilosophers))]
 	}
 
 	fmt.Printf("📜 辩题: %s\n", debateConfig.Topic)
+	fmt.Printf("📋 赛制: %s（%s）\n", format.Name, format.Description)
 	fmt.Printf("✅ 正方: %s\n", debateConfig.ProStance)
 	fmt.Printf("❌ 反方: %s\n", debateConfig.ConStance)
+	if human != nil {
+		fmt.Printf("🙋 你加入了%s，轮到你时输入发言后回车（直接回车或输入 pass 跳过，限时 %d 秒）\n", human.Side.Title(), human.TimeoutSeconds)
+	}
 	fmt.Println()
 	fmt.Println("════════════════════════════════════════════════════════════════")
 
@@ -191,25 +450,158 @@ func runDebateDemo(model *config.ChatModel) {
 
 	// 设置发言回调
 	engine.SetOnSpeech(func(speaker string, content string, phase philosopher.DebatePhase) {
-		phaseNames := map[philosopher.DebatePhase]string{
-			philosopher.PhaseOpening:     "【开篇立论】",
-			philosopher.PhaseQuestioning: "【质询交锋】",
-			philosopher.PhaseFreeDebate:  "【自由辩论】",
-			philosopher.PhaseClosing:     "【总结陈词】",
-		}
-		fmt.Printf("\n%s %s:\n", phaseNames[phase], speaker)
+		fmt.Printf("\n【%s】 %s:\n", format.PhaseTitle(phase), speaker)
 		fmt.Println("────────────────────────────────────────")
 		fmt.Println(content)
 		fmt.Println()
 	})
 
-	// 运行讨论
-	fmt.Println("\n🎬 讨论开始！\n")
-	result, err := engine.Run()
+	// 运行讨论（Ctrl+C 中止）
+	ctx, stop := signal.NotifyContext(config.WithRoute(context.Background(), route), os.Interrupt)
+	defer stop()
+	usage := config.NewUsageTracker(cfg.Pricing)
+	ctx = config.WithUsageTracker(ctx, usage)
+	ctx = config.WithBudget(ctx,
+		config.NewBudget(config.BudgetScopeDebate, usage, cfg.Budget.DebateTokens, cfg.Budget.DebateCost, cfg.Budget.DegradeRatio))
+
+	fmt.Print("\n🎬 讨论开始！\n\n")
+	result, err := engine.Run(ctx)
+	if errors.Is(err, context.Canceled) {
+		fmt.Println("\n⏹ 讨论已中止")
+		printBill(usage.Summary())
+		return
+	}
+	var budgetErr *config.BudgetExceededError
+	if errors.As(err, &budgetErr) {
+		fmt.Printf("\n⏹ 预算用完，讨论提前结束：%v\n", err)
+		printBill(usage.Summary())
+		return
+	}
 	if err != nil {
 		log.Fatal().Err(err).Msg("讨论失败")
 	}
 
 	fmt.Println("════════════════════════════════════════════════════════════════")
 	fmt.Printf("🏁 讨论结束！共 %d 轮发言\n", len(result.Records))
+	if result.Judgement != nil {
+		printJudgement(result.Judgement)
+	}
+	printBill(usage.Summary())
+}
+
+// cliHumanInput 从标准输入读取人类参与者的发言
+type cliHumanInput struct {
+	lines chan string
+}
+
+// newCLIHumanInput 在后台逐行读取标准输入，等待超时后未读完的输入留给下一次发言
+func newCLIHumanInput() *cliHumanInput {
+	in := &cliHumanInput{lines: make(chan string)}
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			in.lines <- scanner.Text()
+		}
+		close(in.lines)
+	}()
+	return in
+}
+
+// Await 实现 philosopher.HumanInput
+func (in *cliHumanInput) Await(ctx context.Context, turn philosopher.HumanTurn) (string, error) {
+	fmt.Printf("\n🙋 轮到你了（%d 字以内，%s 前）\n", turn.WordLimit, turn.Deadline.Format("15:04:05"))
+	fmt.Println(turn.Instruction)
+	fmt.Print("你> ")
+
+	select {
+	case line, ok := <-in.lines:
+		if !ok {
+			return "", io.EOF
+		}
+		if line = strings.TrimSpace(line); line == "pass" {
+			return "", nil
+		}
+		return line, nil
+	case <-ctx.Done():
+		fmt.Println("\n⏰ 发言时间到")
+		return "", ctx.Err()
+	}
+}
+
+// printJudgement 打印评委的判定、双方与每位成员的得分，以及评委之间的分歧
+func printJudgement(j *philosopher.Judgement) {
+	fmt.Println("────────────────────────── 评判 ──────────────────────────")
+	fmt.Printf("⚖️  判定：%s\n", j.Winner.Verdict())
+	fmt.Println(j.Reasoning)
+	fmt.Println("   论证   反驳   角色   回应   总分")
+	for _, side := range []philosopher.DebateSide{philosopher.SidePro, philosopher.SideCon} {
+		if score, ok := j.BySide[side]; ok {
+			printRubric(side.Title(), score)
+		}
+	}
+
+	prompts := philosopher.GetPhilosopherPrompts()
+	speakers := make([]philosopher.PhilosopherType, 0, len(j.BySpeaker))
+	for pType := range j.BySpeaker {
+		speakers = append(speakers, pType)
+	}
+	sort.Slice(speakers, func(a, b int) bool { return j.BySpeaker[speakers[a]].Total > j.BySpeaker[speakers[b]].Total })
+	for _, pType := range speakers {
+		name := string(pType)
+		if p, ok := prompts[pType]; ok {
+			name = p.Name
+		}
+		printRubric(name, j.BySpeaker[pType])
+	}
+
+	if len(j.FailedJudges) > 0 {
+		fmt.Printf("⚠️  %s 评判失败，结果只汇总其余 %d 位评委\n", strings.Join(j.FailedJudges, "、"), len(j.Verdicts))
+	}
+	if d := j.Disagreement; d != nil {
+		if d.Unanimous {
+			fmt.Printf("评委意见一致，成员得分最大分歧 %.1f 分\n", d.MaxSpread)
+		} else {
+			fmt.Printf("评委意见不一（持不同意见：%s），成员得分最大分歧 %.1f 分\n", strings.Join(d.Dissenters, "、"), d.MaxSpread)
+		}
+	}
+}
+
+func printRubric(name string, r philosopher.RubricScores) {
+	fmt.Printf("  %5.1f  %5.1f  %5.1f  %5.1f  %5.1f  %s\n",
+		r.ArgumentStrength, r.RebuttalQuality, r.Character, r.Responsiveness, r.Total, name)
+}
+
+// printTurnBill 打印本轮与累计的用量（-bill）
+func printTurnBill(turn, total config.UsageTotal, currency string) {
+	fmt.Printf("💰 本轮 %d tokens（输入 %d / 输出 %d）%.4f %s ｜ 累计 %d tokens %.4f %s\n",
+		turn.TotalTokens, turn.PromptTokens, turn.CompletionTokens, turn.Cost, currency,
+		total.TotalTokens, total.Cost, currency)
+}
+
+// printBill 打印账单：总计 + 按子系统 / 模型 / 发言者拆分
+func printBill(s *config.UsageSummary) {
+	fmt.Println("────────────────────────── 账单 ──────────────────────────")
+	fmt.Printf("共 %d 次调用，%d tokens（输入 %d / 输出 %d），费用 %.4f %s\n",
+		s.Calls, s.TotalTokens, s.PromptTokens, s.CompletionTokens, s.Cost, s.Currency)
+	printBillSection("按子系统", s.ByScope, s.Currency)
+	printBillSection("按模型", s.ByModel, s.Currency)
+	printBillSection("按发言者", s.BySpeaker, s.Currency)
+	fmt.Println("──────────────────────────────────────────────────────────")
+}
+
+func printBillSection(title string, totals map[string]config.UsageTotal, currency string) {
+	if len(totals) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(totals))
+	for k := range totals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("%s:\n", title)
+	for _, k := range keys {
+		t := totals[k]
+		fmt.Printf("  %-12s %3d 次  %7d tokens  %.4f %s\n", k, t.Calls, t.TotalTokens, t.Cost, currency)
+	}
 }
@@ -0,0 +1,67 @@
+package metrics
+
+import "time"
+
+// ==================== 应用指标 ====================
+
+// Default 默认注册表，/metrics 输出的就是它
+var Default = NewRegistry()
+
+// 延迟直方图的桶（秒）
+var (
+	httpBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	llmBuckets  = []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60}
+	stepBuckets = []float64{1, 2, 3, 4, 5, 6, 8, 10}
+)
+
+var (
+	// HTTPRequests 按路由、方法与状态码统计的请求数
+	HTTPRequests = Default.NewCounterVec("mygo_http_requests_total",
+		"HTTP requests by route, method and status code.", "route", "method", "status")
+	// HTTPDuration 按路由统计的请求耗时（SSE 请求为整个连接的时长）
+	HTTPDuration = Default.NewHistogramVec("mygo_http_request_duration_seconds",
+		"HTTP request duration by route.", httpBuckets, "route")
+
+	// LLMRequests 单个 API 源的调用次数，result 为 success / failure / cancelled
+	LLMRequests = Default.NewCounterVec("mygo_llm_requests_total",
+		"LLM calls per API source and model by result.", "source", "model", "result")
+	// LLMDuration 单个 API 源的调用耗时
+	LLMDuration = Default.NewHistogramVec("mygo_llm_request_duration_seconds",
+		"LLM call latency per API source and model.", llmBuckets, "source", "model")
+	// LLMFallbacks 所有源都失败、返回兜底消息的次数
+	LLMFallbacks = Default.NewCounterVec("mygo_llm_fallbacks_total",
+		"Times every API source failed and the static fallback message was returned.")
+
+	// ToolInvocations 按工具名统计的调用次数，result 为 ok / error
+	ToolInvocations = Default.NewCounterVec("mygo_tool_invocations_total",
+		"Tool invocations by tool name and result.", "tool", "result")
+	// ReActSteps 每轮 ReAct 循环产生的步骤数
+	ReActSteps = Default.NewHistogramVec("mygo_react_steps_per_turn",
+		"ReAct steps (Thought/Action/Observation) per agent turn.", stepBuckets)
+	// Reflections 反思结果，result 为 accepted / revised / failed
+	Reflections = Default.NewCounterVec("mygo_reflections_total",
+		"Reflection outcomes; result=revised counts responses rewritten by reflection.", "result")
+	// EmotionLevels 情绪分析结果的分布
+	EmotionLevels = Default.NewCounterVec("mygo_emotion_level_total",
+		"Detected user emotion levels.", "level")
+
+	// ActiveSessions 当前会话数
+	ActiveSessions = Default.NewGaugeFunc("mygo_active_sessions",
+		"Chat sessions currently held by the server.")
+	// ActiveDebates 进行中的辩论数
+	ActiveDebates = Default.NewGaugeFunc("mygo_active_debates",
+		"Debates currently pending or running.")
+)
+
+// ObserveLLMCall 记录一次 API 源调用的结果与耗时
+func ObserveLLMCall(source, model string, start time.Time, err error, cancelled bool) {
+	result := "success"
+	switch {
+	case cancelled:
+		result = "cancelled"
+	case err != nil:
+		result = "failure"
+	}
+	LLMRequests.Inc(source, model, result)
+	LLMDuration.Observe(time.Since(start).Seconds(), source, model)
+}
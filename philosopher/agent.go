@@ -1,11 +1,16 @@
 package philosopher
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
 	"agent/config"
+	"agent/metrics"
 	"agent/react"
+	"agent/tracing"
+
+	"github.com/rs/zerolog/log"
 )
 
 // ==================== 完整 Agent 实现 ====================
@@ -16,7 +21,7 @@ type Agent struct {
 	Type   PhilosopherType
 	Name   string
 	Prompt *PhilosopherPrompt
-	Model  *config.ChatModel
+	Model  config.Provider
 
 	// Agent 上下文
 	Context *AgentContext
@@ -42,6 +47,7 @@ type AgentConfig struct {
 	EnableRefinement bool
 	MaxToolCalls     int
 	MemoryStorePath  string // SQLite 数据库路径
+	DisableAIEmotion bool   // 关闭 AI 深度情绪分析（只用关键词规则）
 }
 
 // DefaultAgentConfig 默认配置
@@ -56,7 +62,7 @@ func DefaultAgentConfig() *AgentConfig {
 }
 
 // NewAgent 创建完整的 Agent
-func NewAgent(pType PhilosopherType, model *config.ChatModel, cfg *AgentConfig) (*Agent, error) {
+func NewAgent(pType PhilosopherType, model config.Provider, cfg *AgentConfig) (*Agent, error) {
 	if cfg == nil {
 		cfg = DefaultAgentConfig()
 	}
@@ -94,14 +100,44 @@ func NewAgent(pType PhilosopherType, model *config.ChatModel, cfg *AgentConfig)
 		MaxToolCalls:     cfg.MaxToolCalls,
 	}
 
+	agent.EmotionAnalyzer.SetAIAnalysis(!cfg.DisableAIEmotion)
+
 	return agent, nil
 }
 
 // Chat Agent 的主对话方法
-func (a *Agent) Chat(userMessage string, history []config.Message) (*AgentResponse, error) {
+func (a *Agent) Chat(ctx context.Context, userMessage string, history []config.Message) (*AgentResponse, error) {
+	return a.ChatStream(ctx, userMessage, history, nil)
+}
+
+// AgentCallbacks Agent 对话过程中的实时回调（均可为空）
+type AgentCallbacks struct {
+	OnToken      func(token string)             // 模型输出的增量文本（含 ReAct 中间轮的 Thought）
+	OnStep       func(step react.Step)          // 每完成一个 ReAct 步骤
+	OnReflection func(result *ReflectionResult) // 反思完成
+}
+
+// ChatStream 与 Chat 相同，但会通过回调实时推送 token、ReAct 步骤和反思结果
+// 注意：启用反思/迭代优化时最终回复可能被改写，以返回的 AgentResponse.Content 为准
+// ctx 被取消时尽快中止（包括反思与迭代优化），返回 ctx.Err()，且不写入记忆
+func (a *Agent) ChatStream(ctx context.Context, userMessage string, history []config.Message, callbacks *AgentCallbacks) (resp *AgentResponse, err error) {
+	if callbacks == nil {
+		callbacks = &AgentCallbacks{}
+	}
+
+	// 整轮对话作为一个 trace 的根 span（或挂在调用方的 span 下）
+	ctx, span := tracing.Start(ctx, "agent.turn")
+	span.SetAttr("agent", string(a.Type))
+	span.SetAttr("session_id", a.Context.SessionID)
+	span.SetAttr("tools", a.EnableTools)
+	span.SetAttr("reflection", a.EnableReflection)
+	span.SetText("user_message", userMessage)
+	defer func() { span.End(err) }()
+
 	// 1. 情绪分析
-	emotionLevel := a.EmotionAnalyzer.Analyze(userMessage)
+	emotionLevel := a.EmotionAnalyzer.Analyze(ctx, userMessage)
 	a.Context.CurrentMood = string(emotionLevel)
+	span.SetAttr("emotion", string(emotionLevel))
 
 	// 2. 构建系统 Prompt
 	systemPrompt := a.buildAgentPrompt(emotionLevel)
@@ -126,13 +162,15 @@ func (a *Agent) Chat(userMessage string, history []config.Message) (*AgentRespon
 		Messages:    messages,
 		MaxSteps:    a.MaxToolCalls,
 		ReActPrompt: react.DefaultReActInstruction,
+		OnToken:     callbacks.OnToken,
+		OnStep:      callbacks.OnStep,
 	}
 	// 未启用工具时仍走 ReAct，但 tools 为空，模型会直接回复
 	if !a.EnableTools {
 		reactInput.Tools = nil
 	}
 
-	runResult, err := react.Run(reactInput)
+	runResult, err := react.Run(config.WithUsageScope(ctx, config.ScopeReAct), reactInput)
 	if err != nil {
 		return nil, err
 	}
@@ -141,23 +179,49 @@ func (a *Agent) Chat(userMessage string, history []config.Message) (*AgentRespon
 	// 从 ReAct 步骤中提取工具调用记录，供兼容原有 ToolResults 字段
 	toolResults := a.toolResultsFromReActSteps(runResult.Steps)
 
+	// 预算紧张时跳过反思与迭代优化（二者都会额外调用模型）
+	degraded := config.BudgetLevelFrom(ctx) >= config.BudgetDegraded
+
 	// 6. 反思（如果启用）
 	var reflectionResult *ReflectionResult
-	if a.EnableReflection {
+	if a.EnableReflection && !degraded {
+		reflectCtx, reflectSpan := tracing.Start(config.WithUsageScope(ctx, config.ScopeReflection), "reflection")
 		response, reflectionResult, err = a.ReflectionEngine.ReflectAndRefine(
-			response, a.Type, a.Context, userMessage)
+			reflectCtx, response, a.Type, a.Context, userMessage)
+		reflectSpan.End(err)
 		if err != nil {
 			// 反思失败不影响主流程，记录日志即可
+			log.Warn().Err(err).Str("agent", string(a.Type)).Msg("反思失败，使用原始回复")
+			metrics.Reflections.Inc("failed")
 			reflectionResult = nil
+		} else {
+			if response != reflectionResult.OriginalResponse {
+				metrics.Reflections.Inc("revised")
+			} else {
+				metrics.Reflections.Inc("accepted")
+			}
+			if callbacks.OnReflection != nil {
+				callbacks.OnReflection(reflectionResult)
+			}
 		}
 	}
 
 	// 7. 迭代优化（如果启用）
 	var evaluations []SelfEvaluationResult
-	if a.EnableRefinement {
-		response, evaluations, _ = a.Refiner.RefineResponse(
-			response, a.Type, a.Context, userMessage)
+	if a.EnableRefinement && !degraded {
+		refineCtx, refineSpan := tracing.Start(config.WithUsageScope(ctx, config.ScopeRefinement), "refinement")
+		var refineErr error
+		response, evaluations, refineErr = a.Refiner.RefineResponse(
+			refineCtx, response, a.Type, a.Context, userMessage)
+		refineSpan.SetAttr("iterations", len(evaluations))
+		refineSpan.End(refineErr)
+	}
+
+	// 反思 / 优化失败会被吞掉，这里统一确认请求仍然有效
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	span.SetText("response", response)
 
 	// 8. 保存对话到记忆系统（持久化）
 	if a.MemoryManager != nil && a.Context.SessionID != "" {
@@ -0,0 +1,144 @@
+package philosopher
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ==================== 辩论控制 ====================
+// 运行中的辩论可以暂停、继续与取消，也可以插入主持人提示；
+// 引擎在每次发言前检查控制状态：暂停时等到继续，取消时结束辩论，提示交给下一位发言者
+
+// ErrDebateCancelled 辩论被取消
+var ErrDebateCancelled = errors.New("辩论已取消")
+
+// DebateControl 辩论控制器，可以在其他 goroutine 中调用
+type DebateControl struct {
+	mu        sync.Mutex
+	paused    bool
+	resumed   chan struct{} // 暂停期间有效，继续时关闭
+	changed   chan struct{} // 每次暂停 / 继续时关闭并重建
+	cancelled chan struct{} // 取消时关闭
+	notes     []string      // 尚未交给发言者的主持人提示
+}
+
+// NewDebateControl 创建辩论控制器
+func NewDebateControl() *DebateControl {
+	return &DebateControl{cancelled: make(chan struct{}), changed: make(chan struct{})}
+}
+
+// Pause 暂停辩论：正在进行的发言结束后生效（正在等待人类发言时立即停表）；已暂停或已取消时返回 false
+func (c *DebateControl) Pause() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused || c.isCancelled() {
+		return false
+	}
+	c.paused = true
+	c.resumed = make(chan struct{})
+	c.notifyChanged()
+	return true
+}
+
+// Resume 继续暂停中的辩论；未暂停时返回 false
+func (c *DebateControl) Resume() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		return false
+	}
+	c.paused = false
+	close(c.resumed)
+	c.notifyChanged()
+	return true
+}
+
+// Cancel 取消辩论：正在进行的模型调用会被中断；已取消时返回 false
+func (c *DebateControl) Cancel() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.isCancelled() {
+		return false
+	}
+	close(c.cancelled)
+	return true
+}
+
+// Paused 是否处于暂停状态
+func (c *DebateControl) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// AddNote 插入一条主持人提示（如"请围绕 X 展开"），写入下一位发言者的上下文
+func (c *DebateControl) AddNote(note string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notes = append(c.notes, note)
+}
+
+// pauseState 是否处于暂停状态，以及下一次暂停 / 继续时关闭的通道
+func (c *DebateControl) pauseState() (bool, <-chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused, c.changed
+}
+
+// notifyChanged 通知暂停状态的变化（调用方需持有 mu）
+func (c *DebateControl) notifyChanged() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// isCancelled 是否已取消（调用方需持有 mu）
+func (c *DebateControl) isCancelled() bool {
+	select {
+	case <-c.cancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkpoint 发言前的检查点：暂停时阻塞到继续，取消时返回 ErrDebateCancelled；
+// 返回积压的主持人提示
+func (c *DebateControl) checkpoint(ctx context.Context) (string, error) {
+	for {
+		c.mu.Lock()
+		if c.isCancelled() {
+			c.mu.Unlock()
+			return "", ErrDebateCancelled
+		}
+		if !c.paused {
+			note := strings.Join(c.notes, "\n")
+			c.notes = nil
+			c.mu.Unlock()
+			return note, nil
+		}
+		resumed := c.resumed
+		c.mu.Unlock()
+
+		select {
+		case <-resumed:
+		case <-c.cancelled:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// watch 取消时中断 ctx（原因为 ErrDebateCancelled），返回的 stop 用于结束监听
+func (c *DebateControl) watch(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-c.cancelled:
+			cancel(ErrDebateCancelled)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() { cancel(nil) }
+}
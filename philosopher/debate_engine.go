@@ -1,10 +1,17 @@
 package philosopher
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"agent/config"
+	"agent/tracing"
+
+	"github.com/rs/zerolog/log"
 )
 
 // DebatePhase 辩论阶段
@@ -15,6 +22,7 @@ const (
 	PhaseQuestioning DebatePhase = "questioning" // 质询交锋
 	PhaseFreeDebate  DebatePhase = "free_debate" // 自由辩论
 	PhaseClosing     DebatePhase = "closing"     // 总结陈词
+	PhaseJudging     DebatePhase = "judging"     // 评委评判
 )
 
 // DebateConfig 辩论配置
@@ -25,14 +33,88 @@ type DebateConfig struct {
 	ProPhilosophers []PhilosopherType          // 正方哲学家
 	ConPhilosophers []PhilosopherType          // 反方哲学家
 	ForcedStances   map[PhilosopherType]string // 强制立场（操纵阵营）
+	Format          *DebateFormat              // 赛制，nil 使用默认赛制
+	FreeDebate      FreeDebateConfig           // 自由辩论阶段（非零的设置覆盖赛制中的值）
+	Judges          int                        // 评委人数（最多 MaxJudges），0 表示不评判
+	Human           *HumanConfig               // 人类参与者，nil 表示只有 AI 成员
+	Control         *DebateControl             // 暂停 / 继续 / 取消与主持人提示，nil 表示不受控制
+}
+
+// Sides 双方成员，人类参与者作为其所在一方的第一位成员
+func (c *DebateConfig) Sides() (pro, con []PhilosopherType) {
+	pro = append([]PhilosopherType(nil), c.ProPhilosophers...)
+	con = append([]PhilosopherType(nil), c.ConPhilosophers...)
+	if c.Human != nil {
+		if c.Human.Side == SideCon {
+			con = append([]PhilosopherType{HumanSpeaker}, con...)
+		} else {
+			pro = append([]PhilosopherType{HumanSpeaker}, pro...)
+		}
+	}
+	return pro, con
+}
+
+// FreeDebateConfig 自由辩论配置：正反方交替发言，每方由谁发言按 TurnPolicy 决定
+type FreeDebateConfig struct {
+	Rounds     int        `json:"rounds,omitempty"`      // 轮数（每轮正反方各发言一次），0 使用赛制中的轮数，负数跳过本阶段
+	MaxTokens  int        `json:"max_tokens,omitempty"`  // 本阶段的 token 上限，达到后不再开始新的发言，0 表示不限
+	TurnPolicy TurnPolicy `json:"turn_policy,omitempty"` // 发言人选择策略，为空使用赛制中的策略（默认 round_robin）
+}
+
+// DefaultFreeDebateRounds 赛制未指定轮数时自由辩论的轮数
+const DefaultFreeDebateRounds = 2
+
+// TurnPolicy 自由辩论中一方内部由谁发言
+type TurnPolicy string
+
+const (
+	TurnRoundRobin  TurnPolicy = "round_robin"  // 按顺序轮流
+	TurnLeastSpoken TurnPolicy = "least_spoken" // 本场发言最少的人优先
+	TurnModerator   TurnPolicy = "moderator"    // 由主持人（模型）点名，并给出发言方向
+)
+
+// Validate 校验自由辩论配置
+func (c FreeDebateConfig) Validate() error {
+	switch c.TurnPolicy {
+	case "", TurnRoundRobin, TurnLeastSpoken, TurnModerator:
+	default:
+		return fmt.Errorf("unknown turn policy %q", c.TurnPolicy)
+	}
+	if c.MaxTokens < 0 {
+		return fmt.Errorf("free debate max_tokens must not be negative, got %d", c.MaxTokens)
+	}
+	return nil
+}
+
+// rounds 实际轮数，0 表示跳过
+func (c FreeDebateConfig) rounds(spec PhaseSpec) int {
+	switch {
+	case c.Rounds < 0:
+		return 0
+	case c.Rounds > 0:
+		return c.Rounds
+	case spec.Rounds > 0:
+		return spec.Rounds
+	default:
+		return DefaultFreeDebateRounds
+	}
+}
+
+// turnPolicy 实际的发言人选择策略
+func (c FreeDebateConfig) turnPolicy(spec PhaseSpec) TurnPolicy {
+	if c.TurnPolicy != "" {
+		return c.TurnPolicy
+	}
+	return spec.TurnPolicy
 }
 
 // DebateEngine 辩论流程引擎
 type DebateEngine struct {
 	config       *DebateConfig
+	format       *DebateFormat
 	context      *DebateContext
 	philosophers map[PhilosopherType]*Philosopher
-	model        *config.ChatModel
+	model        config.Provider
 
 	// 发言队列管理
 	speakingQueue []PhilosopherType
@@ -41,6 +123,7 @@ type DebateEngine struct {
 
 	// 回调函数
 	onSpeech func(speaker string, content string, phase DebatePhase)
+	onPhase  func(phase DebatePhase)
 }
 
 // DebateContext 辩论上下文（全局辩论纪要）
@@ -77,16 +160,28 @@ type QuestionRecord struct {
 }
 
 // NewDebateEngine 创建辩论引擎
-func NewDebateEngine(cfg *DebateConfig, model *config.ChatModel) *DebateEngine {
+func NewDebateEngine(cfg *DebateConfig, model config.Provider) *DebateEngine {
+	// 人类参与者并入所在一方
+	c := *cfg
+	c.ProPhilosophers, c.ConPhilosophers = cfg.Sides()
+	cfg = &c
+
 	engine := &DebateEngine{
 		config:       cfg,
+		format:       cfg.Format,
 		model:        model,
 		philosophers: make(map[PhilosopherType]*Philosopher),
 	}
+	if engine.format == nil {
+		engine.format, _ = GetDebateFormat(DefaultDebateFormat)
+	}
 
 	// 创建所有参与的哲学家
 	allPhilosophers := append(cfg.ProPhilosophers, cfg.ConPhilosophers...)
 	for _, pType := range allPhilosophers {
+		if pType == HumanSpeaker {
+			continue
+		}
 		p := NewPhilosopher(pType, model)
 
 		// 设置立场
@@ -108,7 +203,7 @@ func NewDebateEngine(cfg *DebateConfig, model *config.ChatModel) *DebateEngine {
 	// 初始化上下文
 	engine.context = &DebateContext{
 		Topic:              cfg.Topic,
-		CurrentPhase:       PhaseOpening,
+		CurrentPhase:       engine.format.Phases[0].Phase,
 		History:            []DebateRecord{},
 		OpeningStatements:  make(map[PhilosopherType]string),
 		QuestioningRecords: []QuestionRecord{},
@@ -119,96 +214,282 @@ func NewDebateEngine(cfg *DebateConfig, model *config.ChatModel) *DebateEngine {
 	return engine
 }
 
+// Format 本场辩论的赛制
+func (e *DebateEngine) Format() *DebateFormat {
+	return e.format
+}
+
 // SetOnSpeech 设置发言回调
 func (e *DebateEngine) SetOnSpeech(callback func(speaker string, content string, phase DebatePhase)) {
 	e.onSpeech = callback
 }
 
-// Run 运行完整辩论
-func (e *DebateEngine) Run() (*DebateResult, error) {
+// SetOnPhase 设置阶段切换回调
+func (e *DebateEngine) SetOnPhase(callback func(phase DebatePhase)) {
+	e.onPhase = callback
+}
+
+// enterPhase 进入新阶段并触发回调
+func (e *DebateEngine) enterPhase(phase DebatePhase) {
+	e.context.CurrentPhase = phase
+	if e.onPhase != nil {
+		e.onPhase(phase)
+	}
+}
+
+// Run 运行完整辩论：按赛制依次执行各阶段
+// ctx 被取消时当前发言的模型调用会被中断，不再开始后续发言；通过 Control 取消时返回 ErrDebateCancelled
+func (e *DebateEngine) Run(ctx context.Context) (_ *DebateResult, err error) {
+	ctx, span := tracing.Start(ctx, "debate")
+	span.SetText("topic", e.config.Topic)
+	span.SetAttr("format", e.format.Name)
+	defer func() { span.End(err) }()
+
+	if c := e.config.Control; c != nil {
+		var stop func()
+		ctx, stop = c.watch(ctx)
+		defer stop()
+		defer func() {
+			if err != nil && errors.Is(context.Cause(ctx), ErrDebateCancelled) {
+				err = ErrDebateCancelled
+			}
+		}()
+	}
+
 	result := &DebateResult{
 		Topic:   e.config.Topic,
 		Records: []DebateRecord{},
 	}
 
-	// 第一幕：开篇立论
-	if err := e.runOpeningPhase(); err != nil {
-		return nil, fmt.Errorf("开篇立论失败: %w", err)
+	for _, spec := range e.format.Phases {
+		if err := e.runPhase(ctx, spec); err != nil {
+			return nil, fmt.Errorf("%s失败: %w", e.format.PhaseTitle(spec.Phase), err)
+		}
 	}
 
-	// 第二幕：质询交锋
-	if err := e.runQuestioningPhase(); err != nil {
-		return nil, fmt.Errorf("质询交锋失败: %w", err)
-	}
+	result.Records = e.context.History
 
-	// 第三幕：总结陈词
-	if err := e.runClosingPhase(); err != nil {
-		return nil, fmt.Errorf("总结陈词失败: %w", err)
+	// 评委评判：失败时只记录日志，不影响已完成的辩论
+	if e.config.Judges > 0 {
+		if _, err := e.checkpoint(ctx); err != nil {
+			return nil, err
+		}
+		e.enterPhase(PhaseJudging)
+		judgement, err := NewJudgePanel(e.model, e.config.Judges).Judge(ctx, e.config, e.format, result.Records)
+		if err != nil {
+			log.Warn().Err(err).Msg("评判失败")
+		}
+		result.Judgement = judgement
+	}
+	if errors.Is(context.Cause(ctx), ErrDebateCancelled) {
+		return nil, ErrDebateCancelled
 	}
 
-	result.Records = e.context.History
 	return result, nil
 }
 
-// runOpeningPhase 运行开篇立论阶段
-func (e *DebateEngine) runOpeningPhase() error {
-	e.context.CurrentPhase = PhaseOpening
-
-	// 正方先发言，然后反方
-	order := append(e.config.ProPhilosophers, e.config.ConPhilosophers...)
+// runPhase 运行一个阶段
+func (e *DebateEngine) runPhase(ctx context.Context, spec PhaseSpec) error {
+	pro := e.format.members(e.config.ProPhilosophers, spec)
+	con := e.format.members(e.config.ConPhilosophers, spec)
 
-	for _, pType := range order {
-		p := e.philosophers[pType]
+	switch spec.Task {
+	case TaskQuestion:
+		return e.runQuestioningPhase(ctx, spec, pro, con)
+	case TaskFreeDebate:
+		return e.runFreeDebatePhase(ctx, spec, pro, con)
+	}
 
-		task := DebateTask{
-			Type:        TaskOpening,
-			Instruction: "请进行开篇立论",
+	e.enterPhase(spec.Phase)
+	order := speakingOrder(spec.Order, pro, con)
+	for round := 0; round < max(spec.Rounds, 1); round++ {
+		for _, pType := range order {
+			if err := e.speak(ctx, spec, pType); err != nil {
+				return err
+			}
 		}
+	}
 
-		content, err := p.Debate(e.context, task)
-		if err != nil {
-			return err
-		}
+	return nil
+}
 
-		// 记录
-		record := DebateRecord{
-			Speaker:     pType,
-			SpeakerName: p.Name,
-			Content:     content,
-			Phase:       PhaseOpening,
-			TaskType:    TaskOpening,
+// speakingOrder 按发言顺序规则排出发言队列
+func speakingOrder(order SpeakingOrder, pro, con []PhilosopherType) []PhilosopherType {
+	switch order {
+	case OrderConFirst:
+		return append(con[:len(con):len(con)], pro...)
+	case OrderProOnly:
+		return pro
+	case OrderConOnly:
+		return con
+	case OrderAlternate:
+		var queue []PhilosopherType
+		for i := 0; i < max(len(pro), len(con)); i++ {
+			if i < len(pro) {
+				queue = append(queue, pro[i])
+			}
+			if i < len(con) {
+				queue = append(queue, con[i])
+			}
 		}
-		e.context.History = append(e.context.History, record)
-		e.context.OpeningStatements[pType] = content
+		return queue
+	default:
+		return append(pro[:len(pro):len(pro)], con...)
+	}
+}
 
-		// 回调
-		if e.onSpeech != nil {
-			e.onSpeech(p.Name, content, PhaseOpening)
+// speak 一次立论 / 反驳 / 总结发言
+func (e *DebateEngine) speak(ctx context.Context, spec PhaseSpec, pType PhilosopherType) error {
+	task := DebateTask{
+		Type:        spec.Task,
+		Instruction: spec.Instruction,
+		WordLimit:   spec.WordLimit,
+	}
+	var target PhilosopherType
+	if spec.Task == TaskRebuttal {
+		// 反驳对方最近的发言者
+		task.TargetName = "对方"
+		if last, ok := e.lastOpponentRecord(pType); ok {
+			target = last.Speaker
+			task.TargetName = last.SpeakerName
+		}
+	}
+	if task.Instruction == "" {
+		switch spec.Task {
+		case TaskOpening:
+			task.Instruction = "请进行开篇立论"
+		case TaskRebuttal:
+			task.Instruction = "请回应 " + task.TargetName + " 的观点"
+		default:
+			task.Instruction = "请进行总结陈词"
 		}
 	}
 
+	content, err := e.say(ctx, pType, task)
+	if err != nil {
+		return err
+	}
+
+	e.record(DebateRecord{
+		Speaker:       pType,
+		SpeakerName:   e.speakerName(pType),
+		Content:       content,
+		Phase:         spec.Phase,
+		TaskType:      spec.Task,
+		TargetSpeaker: target,
+	})
 	return nil
 }
 
-// runQuestioningPhase 运行质询交锋阶段
-func (e *DebateEngine) runQuestioningPhase() error {
-	e.context.CurrentPhase = PhaseQuestioning
-
-	// 交叉质询：正方质询反方，反方质询正方
-	// 每个正方哲学家质询一个反方哲学家
-	for i, proType := range e.config.ProPhilosophers {
-		// 确定质询对象
-		conIndex := i % len(e.config.ConPhilosophers)
-		conType := e.config.ConPhilosophers[conIndex]
-
-		// 正方提问
-		if err := e.runQuestionExchange(proType, conType); err != nil {
-			return err
+// say 让成员完成一次发言任务：AI 成员调用模型，人类参与者等待输入
+// 发言前经过控制检查点，积压的主持人提示随任务交给发言者
+func (e *DebateEngine) say(ctx context.Context, pType PhilosopherType, task DebateTask) (string, error) {
+	note, err := e.checkpoint(ctx)
+	if err != nil {
+		return "", err
+	}
+	task.ModeratorNote = note
+
+	if pType == HumanSpeaker {
+		return e.humanSpeak(ctx, task)
+	}
+	return e.philosophers[pType].Debate(ctx, e.context, task)
+}
+
+// checkpoint 发言前检查暂停 / 取消，返回主持人提示；没有控制器时直接通过
+func (e *DebateEngine) checkpoint(ctx context.Context) (string, error) {
+	if e.config.Control == nil {
+		return "", nil
+	}
+	return e.config.Control.checkpoint(ctx)
+}
+
+// pauseState 暂停状态及其变化通知；没有控制器时永远不会暂停
+func (e *DebateEngine) pauseState() (bool, <-chan struct{}) {
+	if e.config.Control == nil {
+		return false, nil
+	}
+	return e.config.Control.pauseState()
+}
+
+// speakerName 成员的显示名称
+func (e *DebateEngine) speakerName(pType PhilosopherType) string {
+	if pType == HumanSpeaker && e.config.Human != nil {
+		return e.config.Human.DisplayName()
+	}
+	if p, ok := e.philosophers[pType]; ok {
+		return p.Name
+	}
+	return string(pType)
+}
+
+// record 记录一次发言，更新按任务索引的纪要并触发回调
+func (e *DebateEngine) record(record DebateRecord) {
+	e.context.History = append(e.context.History, record)
+	switch record.TaskType {
+	case TaskOpening:
+		e.context.OpeningStatements[record.Speaker] = record.Content
+	case TaskFreeDebate:
+		e.context.FreeDebateRecords = append(e.context.FreeDebateRecords, record)
+	case TaskClosing:
+		e.context.ClosingStatements[record.Speaker] = record.Content
+	}
+
+	if e.onSpeech != nil {
+		e.onSpeech(record.SpeakerName, record.Content, record.Phase)
+	}
+}
+
+// lastOpponentRecord 对方最近的一次发言
+func (e *DebateEngine) lastOpponentRecord(pType PhilosopherType) (DebateRecord, bool) {
+	for i := len(e.context.History) - 1; i >= 0; i-- {
+		if r := e.context.History[i]; !e.sameSide(r.Speaker, pType) {
+			return r, true
 		}
+	}
+	return DebateRecord{}, false
+}
 
-		// 反方反问
-		if err := e.runQuestionExchange(conType, proType); err != nil {
-			return err
+// runQuestioningPhase 运行质询交锋阶段
+func (e *DebateEngine) runQuestioningPhase(ctx context.Context, spec PhaseSpec, pro, con []PhilosopherType) error {
+	if len(pro) == 0 || len(con) == 0 {
+		return nil
+	}
+	e.enterPhase(spec.Phase)
+
+	for round := 0; round < max(spec.Rounds, 1); round++ {
+		switch spec.Order {
+		case OrderProOnly:
+			// 正方依次质询反方
+			for i, proType := range pro {
+				if err := e.runQuestionExchange(ctx, spec, proType, con[i%len(con)]); err != nil {
+					return err
+				}
+			}
+
+		case OrderConOnly:
+			// 反方依次质询正方
+			for i, conType := range con {
+				if err := e.runQuestionExchange(ctx, spec, conType, pro[i%len(pro)]); err != nil {
+					return err
+				}
+			}
+
+		default:
+			// 交叉质询：每个正方成员质询一个反方成员，反方随即反问
+			for i, proType := range pro {
+				conType := con[i%len(con)]
+
+				// 正方提问
+				if err := e.runQuestionExchange(ctx, spec, proType, conType); err != nil {
+					return err
+				}
+
+				// 反方反问
+				if err := e.runQuestionExchange(ctx, spec, conType, proType); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
@@ -216,116 +497,235 @@ func (e *DebateEngine) runQuestioningPhase() error {
 }
 
 // runQuestionExchange 运行一次质询交换
-func (e *DebateEngine) runQuestionExchange(questioner, answerer PhilosopherType) error {
-	qp := e.philosophers[questioner]
-	ap := e.philosophers[answerer]
+func (e *DebateEngine) runQuestionExchange(ctx context.Context, spec PhaseSpec, questioner, answerer PhilosopherType) error {
+	questionerName := e.speakerName(questioner)
+	answererName := e.speakerName(answerer)
 
 	// 提问
 	questionTask := DebateTask{
 		Type:        TaskQuestion,
-		TargetName:  ap.Name,
-		Instruction: "请向 " + ap.Name + " 提出质询",
+		TargetName:  answererName,
+		Instruction: "请向 " + answererName + " 提出质询",
+		WordLimit:   spec.WordLimit,
+	}
+	if spec.Instruction != "" {
+		questionTask.Instruction = spec.Instruction
 	}
 
-	question, err := qp.Debate(e.context, questionTask)
+	question, err := e.say(ctx, questioner, questionTask)
 	if err != nil {
 		return err
 	}
 
 	// 记录提问
-	questionRecord := DebateRecord{
+	e.record(DebateRecord{
 		Speaker:       questioner,
-		SpeakerName:   qp.Name,
+		SpeakerName:   questionerName,
 		Content:       question,
-		Phase:         PhaseQuestioning,
+		Phase:         spec.Phase,
 		TaskType:      TaskQuestion,
 		TargetSpeaker: answerer,
-	}
-	e.context.History = append(e.context.History, questionRecord)
-
-	if e.onSpeech != nil {
-		e.onSpeech(qp.Name, question, PhaseQuestioning)
-	}
+	})
 
 	// 回答
 	answerTask := DebateTask{
 		Type:        TaskAnswer,
-		TargetName:  qp.Name,
-		Instruction: qp.Name + " 问你：" + question,
+		TargetName:  questionerName,
+		Instruction: questionerName + " 问你：" + question,
+		WordLimit:   spec.WordLimit,
 	}
 
-	answer, err := ap.Debate(e.context, answerTask)
+	answer, err := e.say(ctx, answerer, answerTask)
 	if err != nil {
 		return err
 	}
 
-	// 记录回答
-	answerRecord := DebateRecord{
-		Speaker:       answerer,
-		SpeakerName:   ap.Name,
-		Content:       answer,
-		Phase:         PhaseQuestioning,
-		TaskType:      TaskAnswer,
-		TargetSpeaker: questioner,
-	}
-	e.context.History = append(e.context.History, answerRecord)
-
 	// 记录质询对
 	e.context.QuestioningRecords = append(e.context.QuestioningRecords, QuestionRecord{
 		Questioner:     questioner,
-		QuestionerName: qp.Name,
+		QuestionerName: questionerName,
 		Question:       question,
 		Answerer:       answerer,
-		AnswererName:   ap.Name,
+		AnswererName:   answererName,
 		Answer:         answer,
 	})
 
-	if e.onSpeech != nil {
-		e.onSpeech(ap.Name, answer, PhaseQuestioning)
-	}
+	// 记录回答
+	e.record(DebateRecord{
+		Speaker:       answerer,
+		SpeakerName:   answererName,
+		Content:       answer,
+		Phase:         spec.Phase,
+		TaskType:      TaskAnswer,
+		TargetSpeaker: questioner,
+	})
 
 	return nil
 }
 
-// runClosingPhase 运行总结陈词阶段
-func (e *DebateEngine) runClosingPhase() error {
-	e.context.CurrentPhase = PhaseClosing
+// runFreeDebatePhase 运行自由辩论阶段
+// 正方先开始，正反方交替发言；达到轮数或本阶段 token 上限后结束
+func (e *DebateEngine) runFreeDebatePhase(ctx context.Context, spec PhaseSpec, pro, con []PhilosopherType) error {
+	fd := e.config.FreeDebate
+	rounds := fd.rounds(spec)
+	if rounds == 0 || len(pro) == 0 || len(con) == 0 {
+		return nil
+	}
+	e.enterPhase(spec.Phase)
+
+	// 单独累计本阶段的用量，用于 token 上限
+	usage := config.NewUsageTracker(config.PricingConfig{})
+	ctx = config.WithUsageTracker(ctx, usage)
 
-	// 反方先总结，正方最后
-	order := append(e.config.ConPhilosophers, e.config.ProPhilosophers...)
+	policy := fd.turnPolicy(spec)
+	sides := [2][]PhilosopherType{pro, con}
+	for turn := 0; turn < 2*rounds; turn++ {
+		if fd.MaxTokens > 0 && usage.Total().TotalTokens >= fd.MaxTokens {
+			break
+		}
 
-	for _, pType := range order {
-		p := e.philosophers[pType]
+		pType, instruction := e.nextFreeSpeaker(ctx, policy, sides[turn%2], turn/2)
 
 		task := DebateTask{
-			Type:        TaskClosing,
-			Instruction: "请进行总结陈词",
+			Type:        TaskFreeDebate,
+			Instruction: instruction,
+			WordLimit:   spec.WordLimit,
+		}
+		var target PhilosopherType
+		if n := len(e.context.History); n > 0 {
+			if last := e.context.History[n-1]; last.Phase == spec.Phase && !e.sameSide(last.Speaker, pType) {
+				target = last.Speaker
+				task.TargetName = last.SpeakerName
+				if instruction == "" {
+					task.Instruction = "请回应 " + last.SpeakerName + " 刚才的发言"
+				}
+			}
+		}
+		if task.Instruction == "" {
+			task.Instruction = spec.Instruction
+		}
+		if task.Instruction == "" {
+			task.Instruction = "请自由发言"
 		}
 
-		content, err := p.Debate(e.context, task)
+		content, err := e.say(ctx, pType, task)
 		if err != nil {
 			return err
 		}
 
-		// 记录
-		record := DebateRecord{
-			Speaker:     pType,
-			SpeakerName: p.Name,
-			Content:     content,
-			Phase:       PhaseClosing,
-			TaskType:    TaskClosing,
+		e.record(DebateRecord{
+			Speaker:       pType,
+			SpeakerName:   e.speakerName(pType),
+			Content:       content,
+			Phase:         spec.Phase,
+			TaskType:      TaskFreeDebate,
+			TargetSpeaker: target,
+		})
+	}
+
+	return nil
+}
+
+// nextFreeSpeaker 按轮转策略从一方中选出发言人；主持人点名时还会给出发言方向
+func (e *DebateEngine) nextFreeSpeaker(ctx context.Context, policy TurnPolicy, side []PhilosopherType, round int) (PhilosopherType, string) {
+	switch policy {
+	case TurnLeastSpoken:
+		return e.leastSpoken(side), ""
+	case TurnModerator:
+		pick, err := e.moderatorPick(ctx, side)
+		if err == nil {
+			return pick.Speaker, pick.Instruction
 		}
-		e.context.History = append(e.context.History, record)
-		e.context.ClosingStatements[pType] = content
+		// 点名失败时退回到发言最少者，不中断辩论
+		log.Warn().Err(err).Msg("主持人点名失败，改为发言最少者")
+		return e.leastSpoken(side), ""
+	default:
+		return side[round%len(side)], ""
+	}
+}
 
-		if e.onSpeech != nil {
-			e.onSpeech(p.Name, content, PhaseClosing)
+// leastSpoken 本场发言次数最少的成员（相同时取靠前的）
+func (e *DebateEngine) leastSpoken(side []PhilosopherType) PhilosopherType {
+	counts := make(map[PhilosopherType]int)
+	for _, r := range e.context.History {
+		counts[r.Speaker]++
+	}
+	best := side[0]
+	for _, pType := range side[1:] {
+		if counts[pType] < counts[best] {
+			best = pType
 		}
 	}
+	return best
+}
+
+// sameSide 两人是否属于同一方
+func (e *DebateEngine) sameSide(a, b PhilosopherType) bool {
+	return contains(e.config.ProPhilosophers, a) == contains(e.config.ProPhilosophers, b)
+}
+
+// freeDebatePickSchema 主持人在自由辩论中点名的 JSON Schema
+var freeDebatePickSchema = &config.JSONSchema{
+	Name:   "free_debate_pick",
+	Strict: true,
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"speaker":     map[string]interface{}{"type": "string"},
+			"instruction": map[string]interface{}{"type": "string"},
+		},
+		"required":             []string{"speaker", "instruction"},
+		"additionalProperties": false,
+	},
+}
+
+// freeDebatePick 主持人点名的结构化输出
+type freeDebatePick struct {
+	Speaker     PhilosopherType `json:"speaker"`
+	Instruction string          `json:"instruction"`
 
+	candidates []PhilosopherType // 用于校验发言者
+}
+
+// Validate 发言者必须是本方成员
+func (o *freeDebatePick) Validate() error {
+	if !contains(o.candidates, o.Speaker) {
+		return fmt.Errorf("speaker %q is not one of %v", o.Speaker, o.candidates)
+	}
 	return nil
 }
 
+// moderatorPick 让主持人从一方中点名下一位发言者
+func (e *DebateEngine) moderatorPick(ctx context.Context, side []PhilosopherType) (*freeDebatePick, error) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("【辩题】%s\n\n【最近的发言】\n", e.config.Topic))
+	start := len(e.context.History) - 5
+	if start < 0 {
+		start = 0
+	}
+	for _, h := range e.context.History[start:] {
+		sb.WriteString(fmt.Sprintf("- [%s] %s\n", h.SpeakerName, h.Content))
+	}
+	sb.WriteString("\n【可以点名的成员】\n")
+	for _, pType := range side {
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", pType, e.speakerName(pType)))
+	}
+
+	messages := []config.Message{
+		{Role: "system", Content: `你是讨论会的主持人，现在是自由辩论环节。
+请从本方可以点名的成员中选出最适合接下来发言的人（例如最能回应对方刚才观点的人），并给一句简短的发言方向。
+请只输出一个 JSON 对象：{"speaker": "成员代号", "instruction": "发言方向"}`},
+		{Role: "user", Content: sb.String()},
+	}
+
+	pick := &freeDebatePick{candidates: side}
+	ctx = config.WithUsageScope(ctx, config.ScopeModerator)
+	if err := config.InvokeStructured(ctx, e.model, messages, freeDebatePickSchema, pick); err != nil {
+		return nil, err
+	}
+	return pick, nil
+}
+
 // GetRelevantHistory 获取与当前任务相关的历史记录
 // 这是"动态上下文构建"的核心实现
 func (c *DebateContext) GetRelevantHistory(speaker PhilosopherType, taskType DebateTaskType) []DebateRecord {
@@ -337,24 +737,29 @@ func (c *DebateContext) GetRelevantHistory(speaker PhilosopherType, taskType Deb
 		return relevant
 
 	case TaskQuestion:
-		// 质询：需要知道对方的开篇立论
-		for pType, statement := range c.OpeningStatements {
+		// 质询：需要知道对方的开篇立论（按代号排序，保证 Prompt 稳定）
+		speakers := make([]PhilosopherType, 0, len(c.OpeningStatements))
+		for pType := range c.OpeningStatements {
 			if pType != speaker {
-				relevant = append(relevant, DebateRecord{
-					Speaker:     pType,
-					SpeakerName: GetPhilosopherPrompts()[pType].Name,
-					Content:     statement,
-					Phase:       PhaseOpening,
-				})
+				speakers = append(speakers, pType)
 			}
 		}
+		sort.Slice(speakers, func(i, j int) bool { return speakers[i] < speakers[j] })
+		for _, pType := range speakers {
+			relevant = append(relevant, DebateRecord{
+				Speaker:     pType,
+				SpeakerName: c.speakerName(pType),
+				Content:     c.OpeningStatements[pType],
+				Phase:       PhaseOpening,
+			})
+		}
 
 	case TaskAnswer:
 		// 回应质询：需要知道自己的立论 + 刚才的质询
 		if statement, ok := c.OpeningStatements[speaker]; ok {
 			relevant = append(relevant, DebateRecord{
 				Speaker:     speaker,
-				SpeakerName: GetPhilosopherPrompts()[speaker].Name,
+				SpeakerName: c.speakerName(speaker),
 				Content:     statement,
 				Phase:       PhaseOpening,
 			})
@@ -373,7 +778,7 @@ func (c *DebateContext) GetRelevantHistory(speaker PhilosopherType, taskType Deb
 		if statement, ok := c.OpeningStatements[speaker]; ok {
 			relevant = append(relevant, DebateRecord{
 				Speaker:     speaker,
-				SpeakerName: GetPhilosopherPrompts()[speaker].Name,
+				SpeakerName: c.speakerName(speaker),
 				Content:     statement,
 				Phase:       PhaseOpening,
 			})
@@ -396,6 +801,24 @@ func (c *DebateContext) GetRelevantHistory(speaker PhilosopherType, taskType Deb
 			}
 		}
 
+	case TaskRebuttal:
+		// 反驳：自己的立论 + 其他人最近的3次发言
+		if statement, ok := c.OpeningStatements[speaker]; ok {
+			relevant = append(relevant, DebateRecord{
+				Speaker:     speaker,
+				SpeakerName: c.speakerName(speaker),
+				Content:     statement,
+				Phase:       PhaseOpening,
+			})
+		}
+		var recent []DebateRecord
+		for i := len(c.History) - 1; i >= 0 && len(recent) < 3; i-- {
+			if c.History[i].Speaker != speaker {
+				recent = append([]DebateRecord{c.History[i]}, recent...)
+			}
+		}
+		relevant = append(relevant, recent...)
+
 	case TaskFreeDebate:
 		// 自由辩论：最近3条记录
 		start := len(c.History) - 3
@@ -408,10 +831,24 @@ func (c *DebateContext) GetRelevantHistory(speaker PhilosopherType, taskType Deb
 	return relevant
 }
 
+// speakerName 成员的显示名称：角色取 Prompt 中的名字，其他成员（如人类参与者）取纪要中的名字
+func (c *DebateContext) speakerName(pType PhilosopherType) string {
+	if prompt, ok := GetPhilosopherPrompts()[pType]; ok {
+		return prompt.Name
+	}
+	for _, r := range c.History {
+		if r.Speaker == pType {
+			return r.SpeakerName
+		}
+	}
+	return string(pType)
+}
+
 // DebateResult 辩论结果
 type DebateResult struct {
-	Topic   string
-	Records []DebateRecord
+	Topic     string
+	Records   []DebateRecord
+	Judgement *Judgement // 评委的评分与判定，未评判或评判失败时为 nil
 }
 
 // helper function
@@ -0,0 +1,217 @@
+package philosopher
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"agent/config"
+	"agent/config/replaytest"
+)
+
+// wantRecord 期望的发言记录（不比较显示名称）
+type wantRecord struct {
+	speaker PhilosopherType
+	phase   DebatePhase
+	task    DebateTaskType
+	target  PhilosopherType
+	content string
+}
+
+func checkRecords(t *testing.T, got []DebateRecord, want []wantRecord) {
+	t.Helper()
+	if len(got) != len(want) {
+		for i, r := range got {
+			t.Logf("record %d: %s %s %s -> %s: %s", i, r.Speaker, r.Phase, r.TaskType, r.TargetSpeaker, r.Content)
+		}
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		r := got[i]
+		if r.Speaker != w.speaker || r.Phase != w.phase || r.TaskType != w.task || r.TargetSpeaker != w.target || r.Content != w.content {
+			t.Errorf("record %d = {%s %s %s %s %q}, want {%s %s %s %s %q}",
+				i, r.Speaker, r.Phase, r.TaskType, r.TargetSpeaker, r.Content,
+				w.speaker, w.phase, w.task, w.target, w.content)
+		}
+	}
+}
+
+// 录制脚本中各类发言的回复
+const (
+	replyOpening  = "乐队，是大家一起才能发出的声音。"
+	replyQuestion = "你真的觉得，技术不重要吗？"
+	replyAnswer   = "重要。但是，先要有想传达的心情。"
+	replyRebuttal = "可是，只靠技术，是没办法一直走下去的。"
+	replyFree     = "我……还是想和大家一起。"
+	replyClosing  = "所以，我们要一起，继续走下去。"
+)
+
+// scriptedHuman 按任务类型给出固定发言的人类输入端
+type scriptedHuman struct {
+	turns []HumanTurn
+}
+
+func (h *scriptedHuman) Await(ctx context.Context, turn HumanTurn) (string, error) {
+	h.turns = append(h.turns, turn)
+	return "人类的" + string(turn.Task), nil
+}
+
+// capturingModel 记录每次调用的消息
+type capturingModel struct {
+	config.Provider
+	mu    sync.Mutex
+	calls [][]config.Message
+}
+
+func (m *capturingModel) Invoke(ctx context.Context, messages []config.Message, tools []map[string]interface{}) (string, []config.ToolCall, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, messages)
+	m.mu.Unlock()
+	return m.Provider.Invoke(ctx, messages, tools)
+}
+
+func TestDebateEngineRun(t *testing.T) {
+	human := &scriptedHuman{}
+	tests := []struct {
+		name   string
+		format string
+		con    []PhilosopherType
+		human  *HumanConfig
+		want   []wantRecord
+	}{
+		{
+			name:   "standard",
+			format: "standard",
+			con:    []PhilosopherType{NagasakiSoyo},
+			want: []wantRecord{
+				{TakamatsuTomori, PhaseOpening, TaskOpening, "", replyOpening},
+				{NagasakiSoyo, PhaseOpening, TaskOpening, "", replyOpening},
+				{TakamatsuTomori, PhaseQuestioning, TaskQuestion, NagasakiSoyo, replyQuestion},
+				{NagasakiSoyo, PhaseQuestioning, TaskAnswer, TakamatsuTomori, replyAnswer},
+				{NagasakiSoyo, PhaseQuestioning, TaskQuestion, TakamatsuTomori, replyQuestion},
+				{TakamatsuTomori, PhaseQuestioning, TaskAnswer, NagasakiSoyo, replyAnswer},
+				{TakamatsuTomori, PhaseFreeDebate, TaskFreeDebate, "", replyFree},
+				{NagasakiSoyo, PhaseFreeDebate, TaskFreeDebate, TakamatsuTomori, replyFree},
+				{TakamatsuTomori, PhaseFreeDebate, TaskFreeDebate, NagasakiSoyo, replyFree},
+				{NagasakiSoyo, PhaseFreeDebate, TaskFreeDebate, TakamatsuTomori, replyFree},
+				{NagasakiSoyo, PhaseClosing, TaskClosing, "", replyClosing},
+				{TakamatsuTomori, PhaseClosing, TaskClosing, "", replyClosing},
+			},
+		},
+		{
+			name:   "lincoln douglas with human",
+			format: "lincoln_douglas",
+			human:  &HumanConfig{Side: SideCon, Name: "小明", Input: human},
+			want: []wantRecord{
+				{TakamatsuTomori, "affirmative_constructive", TaskOpening, "", replyOpening},
+				{HumanSpeaker, "negative_cross_examination", TaskQuestion, TakamatsuTomori, "人类的question"},
+				{TakamatsuTomori, "negative_cross_examination", TaskAnswer, HumanSpeaker, replyAnswer},
+				{HumanSpeaker, "negative_cross_examination", TaskQuestion, TakamatsuTomori, "人类的question"},
+				{TakamatsuTomori, "negative_cross_examination", TaskAnswer, HumanSpeaker, replyAnswer},
+				{HumanSpeaker, "negative_constructive", TaskOpening, "", "人类的opening"},
+				{TakamatsuTomori, "affirmative_cross_examination", TaskQuestion, HumanSpeaker, replyQuestion},
+				{HumanSpeaker, "affirmative_cross_examination", TaskAnswer, TakamatsuTomori, "人类的answer"},
+				{TakamatsuTomori, "affirmative_cross_examination", TaskQuestion, HumanSpeaker, replyQuestion},
+				{HumanSpeaker, "affirmative_cross_examination", TaskAnswer, TakamatsuTomori, "人类的answer"},
+				{TakamatsuTomori, "first_affirmative_rebuttal", TaskRebuttal, HumanSpeaker, replyRebuttal},
+				{HumanSpeaker, "negative_rebuttal", TaskRebuttal, TakamatsuTomori, "人类的rebuttal"},
+				{TakamatsuTomori, "second_affirmative_rebuttal", TaskClosing, "", replyClosing},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := GetDebateFormat(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var phases []DebatePhase
+			var speeches int
+			engine := NewDebateEngine(&DebateConfig{
+				Topic:           "乐队应该优先考虑技术还是感情",
+				ProStance:       "感情更重要",
+				ConStance:       "技术更重要",
+				ProPhilosophers: []PhilosopherType{TakamatsuTomori},
+				ConPhilosophers: tt.con,
+				Format:          format,
+				Human:           tt.human,
+			}, replaytest.Model(t, filepath.Join("testdata", "cassettes")))
+			engine.SetOnPhase(func(phase DebatePhase) { phases = append(phases, phase) })
+			engine.SetOnSpeech(func(string, string, DebatePhase) { speeches++ })
+
+			result, err := engine.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			checkRecords(t, result.Records, tt.want)
+			if speeches != len(tt.want) {
+				t.Errorf("onSpeech called %d times, want %d", speeches, len(tt.want))
+			}
+			if len(phases) != len(format.Phases) {
+				t.Errorf("entered %d phases, want %d", len(phases), len(format.Phases))
+			}
+		})
+	}
+
+	// 人类回应质询时能看到对方的问题
+	for _, turn := range human.turns {
+		if turn.Task == TaskAnswer && turn.Question != replyQuestion {
+			t.Errorf("human answer turn question = %q, want %q", turn.Question, replyQuestion)
+		}
+	}
+}
+
+func TestDebateEngineModeratorNote(t *testing.T) {
+	format, _ := GetDebateFormat("standard")
+	control := NewDebateControl()
+	control.AddNote("请围绕练习时间展开")
+	model := &capturingModel{Provider: replaytest.Model(t, filepath.Join("testdata", "cassettes"))}
+	engine := NewDebateEngine(&DebateConfig{
+		Topic:           "乐队应该优先考虑技术还是感情",
+		ProStance:       "感情更重要",
+		ConStance:       "技术更重要",
+		ProPhilosophers: []PhilosopherType{TakamatsuTomori},
+		ConPhilosophers: []PhilosopherType{NagasakiSoyo},
+		Format:          format,
+		Control:         control,
+	}, model)
+
+	if _, err := engine.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(model.calls) == 0 {
+		t.Fatal("model was never called")
+	}
+	// 提示只交给下一位发言者
+	for i, messages := range model.calls {
+		hasNote := false
+		for _, msg := range messages {
+			if strings.Contains(msg.Content, "[主持人] 请围绕练习时间展开") {
+				hasNote = true
+			}
+		}
+		if hasNote != (i == 0) {
+			t.Errorf("call %d: has moderator note = %v, want %v", i, hasNote, i == 0)
+		}
+	}
+}
+
+func TestDebateEngineCancelled(t *testing.T) {
+	control := NewDebateControl()
+	control.Cancel()
+	engine := NewDebateEngine(&DebateConfig{
+		Topic:           "乐队应该优先考虑技术还是感情",
+		ProPhilosophers: []PhilosopherType{TakamatsuTomori},
+		ConPhilosophers: []PhilosopherType{NagasakiSoyo},
+		Control:         control,
+	}, replaytest.Model(t, filepath.Join("testdata", "cassettes")))
+
+	if _, err := engine.Run(context.Background()); !errors.Is(err, ErrDebateCancelled) {
+		t.Fatalf("err = %v, want ErrDebateCancelled", err)
+	}
+}
@@ -0,0 +1,349 @@
+package philosopher
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// ==================== 赛制 ====================
+// 赛制把辩论描述为有序的阶段列表：每个阶段指定任务类型、发言顺序、轮数与字数上限，
+// 引擎按顺序执行，不再硬编码"开场 → 质询 → 自由辩论 → 总结"
+
+// SpeakingOrder 阶段内的发言顺序
+type SpeakingOrder string
+
+const (
+	OrderProFirst  SpeakingOrder = "pro_first" // 正方全部发言后反方发言
+	OrderConFirst  SpeakingOrder = "con_first" // 反方全部发言后正方发言
+	OrderAlternate SpeakingOrder = "alternate" // 正反方交替（正方先）
+	OrderProOnly   SpeakingOrder = "pro_only"  // 只有正方发言（质询时为正方提问）
+	OrderConOnly   SpeakingOrder = "con_only"  // 只有反方发言（质询时为反方提问）
+	OrderCross     SpeakingOrder = "cross"     // 交叉质询：正方提问后反方反问
+	OrderFree      SpeakingOrder = "free"      // 自由辩论：正反方交替，每方由谁发言按 TurnPolicy 决定
+)
+
+// DefaultDebateFormat 未指定赛制时使用的赛制
+const DefaultDebateFormat = "standard"
+
+// PhaseSpec 赛制中的一个阶段
+type PhaseSpec struct {
+	Phase       DebatePhase    `json:"phase" mapstructure:"phase"`                       // 阶段标识，在赛制内唯一
+	Title       string         `json:"title" mapstructure:"title"`                       // 展示名称
+	Task        DebateTaskType `json:"task" mapstructure:"task"`                         // 任务类型：opening / question / rebuttal / free_debate / closing
+	Order       SpeakingOrder  `json:"order" mapstructure:"order"`                       // 发言顺序
+	Rounds      int            `json:"rounds,omitempty" mapstructure:"rounds"`           // 轮数，0 为 1 轮（自由辩论为默认轮数）
+	WordLimit   int            `json:"word_limit,omitempty" mapstructure:"word_limit"`   // 每次发言字数上限，0 使用任务类型的默认值
+	Teams       []int          `json:"teams,omitempty" mapstructure:"teams"`             // 参与的队伍（每方多队时，0 为上院、1 为下院），为空表示全部
+	TurnPolicy  TurnPolicy     `json:"turn_policy,omitempty" mapstructure:"turn_policy"` // 自由辩论的发言人选择策略
+	Instruction string         `json:"instruction,omitempty" mapstructure:"instruction"` // 发言指令，为空按任务类型生成
+}
+
+// DebateFormat 赛制
+type DebateFormat struct {
+	Name         string      `json:"name" mapstructure:"name"`
+	Description  string      `json:"description" mapstructure:"description"`
+	TeamsPerSide int         `json:"teams_per_side,omitempty" mapstructure:"teams_per_side"` // 每方的队伍数，0 为 1；多队时按顺序平分本方成员
+	MinPerSide   int         `json:"min_per_side,omitempty" mapstructure:"min_per_side"`     // 每方最少人数，0 为 1（且不少于队伍数）
+	MaxPerSide   int         `json:"max_per_side,omitempty" mapstructure:"max_per_side"`     // 每方最多人数，0 表示不限
+	Phases       []PhaseSpec `json:"phases" mapstructure:"phases"`
+}
+
+// Validate 校验赛制定义
+func (f *DebateFormat) Validate() error {
+	if f.Name == "" {
+		return errors.New("format name is required")
+	}
+	if len(f.Phases) == 0 {
+		return fmt.Errorf("format %s: phases is empty", f.Name)
+	}
+	if f.TeamsPerSide < 0 || f.MinPerSide < 0 || f.MaxPerSide < 0 {
+		return fmt.Errorf("format %s: teams_per_side / min_per_side / max_per_side must not be negative", f.Name)
+	}
+	if f.MaxPerSide > 0 && f.MaxPerSide < f.minPerSide() {
+		return fmt.Errorf("format %s: max_per_side %d is less than min_per_side %d", f.Name, f.MaxPerSide, f.minPerSide())
+	}
+
+	seen := make(map[DebatePhase]bool)
+	for i, p := range f.Phases {
+		if p.Phase == "" {
+			return fmt.Errorf("format %s: phase %d has no name", f.Name, i)
+		}
+		if seen[p.Phase] {
+			return fmt.Errorf("format %s: duplicate phase %s", f.Name, p.Phase)
+		}
+		seen[p.Phase] = true
+
+		if err := p.validate(f.teamsPerSide()); err != nil {
+			return fmt.Errorf("format %s: phase %s: %w", f.Name, p.Phase, err)
+		}
+	}
+	return nil
+}
+
+// validate 校验阶段的任务类型与发言顺序是否搭配
+func (p PhaseSpec) validate(teams int) error {
+	switch p.Order {
+	case OrderProFirst, OrderConFirst, OrderAlternate, OrderProOnly, OrderConOnly, OrderCross, OrderFree:
+	default:
+		return fmt.Errorf("unknown order %q", p.Order)
+	}
+
+	switch p.Task {
+	case TaskQuestion:
+		if p.Order != OrderCross && p.Order != OrderProOnly && p.Order != OrderConOnly {
+			return fmt.Errorf("question phase needs order cross / pro_only / con_only, got %s", p.Order)
+		}
+	case TaskFreeDebate:
+		if p.Order != OrderFree {
+			return fmt.Errorf("free_debate phase needs order free, got %s", p.Order)
+		}
+	case TaskOpening, TaskRebuttal, TaskClosing:
+		if p.Order == OrderCross || p.Order == OrderFree {
+			return fmt.Errorf("%s phase does not support order %s", p.Task, p.Order)
+		}
+	default:
+		return fmt.Errorf("unknown task %q", p.Task)
+	}
+
+	if err := (FreeDebateConfig{TurnPolicy: p.TurnPolicy}).Validate(); err != nil {
+		return err
+	}
+	if p.Rounds < 0 || p.WordLimit < 0 {
+		return errors.New("rounds / word_limit must not be negative")
+	}
+	for _, t := range p.Teams {
+		if t < 0 || t >= teams {
+			return fmt.Errorf("team %d out of range (teams_per_side is %d)", t, teams)
+		}
+	}
+	return nil
+}
+
+// CheckSides 检查双方人数是否符合赛制
+func (f *DebateFormat) CheckSides(pro, con int) error {
+	for _, side := range []struct {
+		name string
+		n    int
+	}{{"pro", pro}, {"con", con}} {
+		if side.n < f.minPerSide() {
+			return fmt.Errorf("format %s needs at least %d %s speakers, got %d", f.Name, f.minPerSide(), side.name, side.n)
+		}
+		if f.MaxPerSide > 0 && side.n > f.MaxPerSide {
+			return fmt.Errorf("format %s allows at most %d %s speakers, got %d", f.Name, f.MaxPerSide, side.name, side.n)
+		}
+	}
+	return nil
+}
+
+// PhaseTitle 阶段的展示名称
+func (f *DebateFormat) PhaseTitle(phase DebatePhase) string {
+	if phase == PhaseJudging {
+		return "评委评判"
+	}
+	for _, p := range f.Phases {
+		if p.Phase == phase && p.Title != "" {
+			return p.Title
+		}
+	}
+	return string(phase)
+}
+
+func (f *DebateFormat) teamsPerSide() int {
+	if f.TeamsPerSide > 0 {
+		return f.TeamsPerSide
+	}
+	return 1
+}
+
+func (f *DebateFormat) minPerSide() int {
+	if f.MinPerSide > f.teamsPerSide() {
+		return f.MinPerSide
+	}
+	return f.teamsPerSide()
+}
+
+// team 把一方成员按顺序平分为若干队，返回第 index 队（前面的队多分余数）
+func (f *DebateFormat) team(side []PhilosopherType, index int) []PhilosopherType {
+	teams := f.teamsPerSide()
+	size, extra := len(side)/teams, len(side)%teams
+	start := index*size + min(index, extra)
+	end := start + size
+	if index < extra {
+		end++
+	}
+	return side[start:end]
+}
+
+// members 一方中参与该阶段的成员
+func (f *DebateFormat) members(side []PhilosopherType, spec PhaseSpec) []PhilosopherType {
+	if len(spec.Teams) == 0 {
+		return side
+	}
+	var members []PhilosopherType
+	for _, t := range spec.Teams {
+		members = append(members, f.team(side, t)...)
+	}
+	return members
+}
+
+// ==================== 内置赛制 ====================
+
+var (
+	debateFormats     = make(map[string]*DebateFormat)
+	debateFormatMutex sync.RWMutex
+)
+
+func init() {
+	for _, f := range builtinDebateFormats() {
+		if err := RegisterDebateFormat(f); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func builtinDebateFormats() []*DebateFormat {
+	return []*DebateFormat{
+		{
+			Name:        "standard",
+			Description: "默认赛制：开篇立论、交叉质询、自由辩论、总结陈词",
+			Phases: []PhaseSpec{
+				{Phase: PhaseOpening, Title: "开篇立论", Task: TaskOpening, Order: OrderProFirst},
+				{Phase: PhaseQuestioning, Title: "质询交锋", Task: TaskQuestion, Order: OrderCross},
+				{Phase: PhaseFreeDebate, Title: "自由辩论", Task: TaskFreeDebate, Order: OrderFree},
+				{Phase: PhaseClosing, Title: "总结陈词", Task: TaskClosing, Order: OrderConFirst},
+			},
+		},
+		{
+			Name:        "oxford",
+			Description: "牛津式：正反方交替陈述，主持人点名交锋，反方先总结、正方最后发言",
+			Phases: []PhaseSpec{
+				{Phase: PhaseOpening, Title: "主陈述", Task: TaskOpening, Order: OrderAlternate, WordLimit: 350},
+				{Phase: PhaseFreeDebate, Title: "交锋", Task: TaskFreeDebate, Order: OrderFree, Rounds: 2, TurnPolicy: TurnModerator},
+				{Phase: PhaseClosing, Title: "结案陈词", Task: TaskClosing, Order: OrderConFirst, WordLimit: 200},
+			},
+		},
+		{
+			Name:         "british_parliamentary",
+			Description:  "英国议会制：正反方各分上院、下院两队，上院立论与反驳，下院扩展论点，最后由下院党鞭总结",
+			TeamsPerSide: 2,
+			Phases: []PhaseSpec{
+				{Phase: PhaseOpening, Title: "上院立论", Task: TaskOpening, Order: OrderAlternate, Teams: []int{0},
+					Instruction: "请作为本方上院代表进行开篇立论"},
+				{Phase: "opening_rebuttal", Title: "上院反驳", Task: TaskRebuttal, Order: OrderAlternate, Teams: []int{0}, WordLimit: 200},
+				{Phase: "extension", Title: "下院扩展", Task: TaskRebuttal, Order: OrderAlternate, Teams: []int{1}, WordLimit: 250,
+					Instruction: "请作为本方下院代表，提出上院没有讲到的新论点，并回应对方的观点"},
+				{Phase: PhaseClosing, Title: "党鞭总结", Task: TaskClosing, Order: OrderAlternate, Teams: []int{1}, WordLimit: 200,
+					Instruction: "请作为本方党鞭，总结整场交锋，说明本方为什么更有说服力"},
+			},
+		},
+		{
+			Name:        "lincoln_douglas",
+			Description: "林肯-道格拉斯式：一对一，立论与质询交替，正方拥有第一个和最后一个发言",
+			MaxPerSide:  1,
+			Phases: []PhaseSpec{
+				{Phase: "affirmative_constructive", Title: "正方立论", Task: TaskOpening, Order: OrderProOnly, WordLimit: 400},
+				{Phase: "negative_cross_examination", Title: "反方质询", Task: TaskQuestion, Order: OrderConOnly, Rounds: 2},
+				{Phase: "negative_constructive", Title: "反方立论", Task: TaskOpening, Order: OrderConOnly, WordLimit: 400},
+				{Phase: "affirmative_cross_examination", Title: "正方质询", Task: TaskQuestion, Order: OrderProOnly, Rounds: 2},
+				{Phase: "first_affirmative_rebuttal", Title: "正方第一次反驳", Task: TaskRebuttal, Order: OrderProOnly, WordLimit: 200},
+				{Phase: "negative_rebuttal", Title: "反方反驳", Task: TaskRebuttal, Order: OrderConOnly, WordLimit: 300},
+				{Phase: "second_affirmative_rebuttal", Title: "正方最后反驳", Task: TaskClosing, Order: OrderProOnly, WordLimit: 150},
+			},
+		},
+		{
+			Name:        "band_meeting",
+			Description: "乐队会议：轻松的圆桌讨论，大家先各说两句，再随便聊聊，最后每人一句话收尾",
+			Phases: []PhaseSpec{
+				{Phase: "check_in", Title: "各自说说", Task: TaskOpening, Order: OrderAlternate, WordLimit: 120,
+					Instruction: "大家在练习室里坐下来了，先随便说说你对这件事的想法"},
+				{Phase: PhaseFreeDebate, Title: "随便聊聊", Task: TaskFreeDebate, Order: OrderFree, Rounds: 3, WordLimit: 100,
+					TurnPolicy: TurnLeastSpoken},
+				{Phase: "wrap_up", Title: "收尾", Task: TaskClosing, Order: OrderAlternate, WordLimit: 60,
+					Instruction: "会议快结束了，用一两句话说说你现在的想法"},
+			},
+		},
+	}
+}
+
+// RegisterDebateFormat 注册赛制，同名的赛制会被覆盖
+func RegisterDebateFormat(f *DebateFormat) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	debateFormatMutex.Lock()
+	defer debateFormatMutex.Unlock()
+	debateFormats[f.Name] = f
+	return nil
+}
+
+// GetDebateFormat 按名称获取赛制，名称为空时返回默认赛制
+func GetDebateFormat(name string) (*DebateFormat, error) {
+	if name == "" {
+		name = DefaultDebateFormat
+	}
+	debateFormatMutex.RLock()
+	defer debateFormatMutex.RUnlock()
+	f, ok := debateFormats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown debate format %q", name)
+	}
+	return f, nil
+}
+
+// DebateFormats 所有已注册的赛制（按名称排序）
+func DebateFormats() []*DebateFormat {
+	debateFormatMutex.RLock()
+	defer debateFormatMutex.RUnlock()
+	formats := make([]*DebateFormat, 0, len(debateFormats))
+	for _, f := range debateFormats {
+		formats = append(formats, f)
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i].Name < formats[j].Name })
+	return formats
+}
+
+// LoadDebateFormats 从 YAML / JSON 文件读取自定义赛制（顶层为 formats 列表）并注册；
+// 先读取并校验所有文件，全部通过后才一起注册，任一文件出错时已注册的赛制保持不变
+func LoadDebateFormats(paths ...string) ([]*DebateFormat, error) {
+	var formats []*DebateFormat
+	for _, path := range paths {
+		loaded, err := readDebateFormats(path)
+		if err != nil {
+			return nil, err
+		}
+		formats = append(formats, loaded...)
+	}
+
+	debateFormatMutex.Lock()
+	defer debateFormatMutex.Unlock()
+	for _, f := range formats {
+		debateFormats[f.Name] = f
+	}
+	return formats, nil
+}
+
+// readDebateFormats 读取并校验一个赛制文件，不注册
+func readDebateFormats(path string) ([]*DebateFormat, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read debate formats: %w", err)
+	}
+
+	var file struct {
+		Formats []*DebateFormat `mapstructure:"formats"`
+	}
+	if err := v.Unmarshal(&file); err != nil {
+		return nil, fmt.Errorf("decode debate formats: %w", err)
+	}
+	for _, f := range file.Formats {
+		if err := f.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return file.Formats, nil
+}
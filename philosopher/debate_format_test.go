@@ -0,0 +1,267 @@
+package philosopher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validFormat 一个合法的两队赛制，用例在其副本上修改
+func validFormat() *DebateFormat {
+	return &DebateFormat{
+		Name:         "test",
+		TeamsPerSide: 2,
+		Phases: []PhaseSpec{
+			{Phase: PhaseOpening, Task: TaskOpening, Order: OrderAlternate, Teams: []int{0}},
+			{Phase: PhaseQuestioning, Task: TaskQuestion, Order: OrderCross},
+			{Phase: PhaseFreeDebate, Task: TaskFreeDebate, Order: OrderFree, TurnPolicy: TurnLeastSpoken},
+			{Phase: PhaseClosing, Task: TaskClosing, Order: OrderConFirst, Teams: []int{1}},
+		},
+	}
+}
+
+func TestDebateFormatValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(f *DebateFormat)
+		wantErr string // 为空表示应当通过
+	}{
+		{name: "valid", modify: func(f *DebateFormat) {}},
+		{name: "missing name", modify: func(f *DebateFormat) { f.Name = "" }, wantErr: "name is required"},
+		{name: "no phases", modify: func(f *DebateFormat) { f.Phases = nil }, wantErr: "phases is empty"},
+		{name: "negative teams", modify: func(f *DebateFormat) { f.TeamsPerSide = -1 }, wantErr: "must not be negative"},
+		{name: "negative max per side", modify: func(f *DebateFormat) { f.MaxPerSide = -1 }, wantErr: "must not be negative"},
+		{
+			// 两队时每方至少 2 人
+			name:    "max per side below the team count",
+			modify:  func(f *DebateFormat) { f.MaxPerSide = 1 },
+			wantErr: "max_per_side 1 is less than min_per_side 2",
+		},
+		{
+			name:    "max per side below min per side",
+			modify:  func(f *DebateFormat) { f.MinPerSide, f.MaxPerSide = 3, 2 },
+			wantErr: "max_per_side 2 is less than min_per_side 3",
+		},
+		{name: "max per side equal to min per side", modify: func(f *DebateFormat) { f.MinPerSide, f.MaxPerSide = 2, 2 }},
+		{name: "unnamed phase", modify: func(f *DebateFormat) { f.Phases[1].Phase = "" }, wantErr: "phase 1 has no name"},
+		{name: "duplicate phase", modify: func(f *DebateFormat) { f.Phases[3].Phase = PhaseOpening }, wantErr: "duplicate phase opening"},
+		{name: "unknown order", modify: func(f *DebateFormat) { f.Phases[0].Order = "random" }, wantErr: `unknown order "random"`},
+		{name: "unknown task", modify: func(f *DebateFormat) { f.Phases[0].Task = "sing" }, wantErr: `unknown task "sing"`},
+		{name: "question with pro_only", modify: func(f *DebateFormat) { f.Phases[1].Order = OrderProOnly }},
+		{name: "question with alternate", modify: func(f *DebateFormat) { f.Phases[1].Order = OrderAlternate }, wantErr: "question phase needs order"},
+		{name: "free debate with alternate", modify: func(f *DebateFormat) { f.Phases[2].Order = OrderAlternate }, wantErr: "free_debate phase needs order free"},
+		{name: "opening with cross", modify: func(f *DebateFormat) { f.Phases[0].Order = OrderCross }, wantErr: "does not support order cross"},
+		{name: "closing with free", modify: func(f *DebateFormat) { f.Phases[3].Order = OrderFree }, wantErr: "does not support order free"},
+		{name: "unknown turn policy", modify: func(f *DebateFormat) { f.Phases[2].TurnPolicy = "loudest" }, wantErr: `unknown turn policy "loudest"`},
+		{name: "negative rounds", modify: func(f *DebateFormat) { f.Phases[2].Rounds = -1 }, wantErr: "rounds / word_limit"},
+		{name: "negative word limit", modify: func(f *DebateFormat) { f.Phases[0].WordLimit = -1 }, wantErr: "rounds / word_limit"},
+		{name: "team out of range", modify: func(f *DebateFormat) { f.Phases[3].Teams = []int{2} }, wantErr: "team 2 out of range (teams_per_side is 2)"},
+		{name: "negative team", modify: func(f *DebateFormat) { f.Phases[3].Teams = []int{-1} }, wantErr: "team -1 out of range"},
+		{
+			// 默认一队，只有第 0 队
+			name:    "team 1 with a single team",
+			modify:  func(f *DebateFormat) { f.TeamsPerSide = 0 },
+			wantErr: "team 1 out of range (teams_per_side is 1)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := validFormat()
+			tt.modify(f)
+			err := f.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuiltinDebateFormatsValid(t *testing.T) {
+	for _, f := range builtinDebateFormats() {
+		if err := f.Validate(); err != nil {
+			t.Errorf("%s: %v", f.Name, err)
+		}
+	}
+}
+
+func TestDebateFormatCheckSides(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   DebateFormat
+		pro, con int
+		wantErr  string
+	}{
+		{name: "default needs one per side", format: DebateFormat{Name: "f"}, pro: 1, con: 3},
+		{name: "empty side", format: DebateFormat{Name: "f"}, pro: 1, con: 0, wantErr: "needs at least 1 con speakers, got 0"},
+		{name: "teams raise the minimum", format: DebateFormat{Name: "f", TeamsPerSide: 2}, pro: 1, con: 2, wantErr: "needs at least 2 pro speakers, got 1"},
+		{name: "min per side", format: DebateFormat{Name: "f", MinPerSide: 3}, pro: 3, con: 2, wantErr: "needs at least 3 con speakers"},
+		{name: "within max per side", format: DebateFormat{Name: "f", MaxPerSide: 1}, pro: 1, con: 1},
+		{name: "above max per side", format: DebateFormat{Name: "f", MaxPerSide: 1}, pro: 2, con: 1, wantErr: "allows at most 1 pro speakers, got 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.format.CheckSides(tt.pro, tt.con)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckSides() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CheckSides() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDebateFormatMembers(t *testing.T) {
+	side := []PhilosopherType{TakamatsuTomori, ChihayaAnon, KanameMana, NagasakiSoyo, ShiinaTaki}
+
+	tests := []struct {
+		name  string
+		teams int
+		spec  PhaseSpec
+		want  []PhilosopherType
+	}{
+		{name: "single team", teams: 1, spec: PhaseSpec{Teams: []int{0}}, want: side},
+		{name: "no teams means everyone", teams: 2, spec: PhaseSpec{}, want: side},
+		{name: "first team takes the remainder", teams: 2, spec: PhaseSpec{Teams: []int{0}}, want: side[:3]},
+		{name: "second team", teams: 2, spec: PhaseSpec{Teams: []int{1}}, want: side[3:]},
+		{name: "teams in the given order", teams: 2, spec: PhaseSpec{Teams: []int{1, 0}}, want: append(append([]PhilosopherType{}, side[3:]...), side[:3]...)},
+		{name: "three teams", teams: 3, spec: PhaseSpec{Teams: []int{2}}, want: side[4:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &DebateFormat{TeamsPerSide: tt.teams}
+			got := f.members(side, tt.spec)
+			if len(got) != len(tt.want) {
+				t.Fatalf("members = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("members = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetDebateFormat(t *testing.T) {
+	f, err := GetDebateFormat("")
+	if err != nil || f.Name != DefaultDebateFormat {
+		t.Fatalf("GetDebateFormat(\"\") = %v, %v; want the default format", f, err)
+	}
+	if _, err := GetDebateFormat("no_such_format"); err == nil {
+		t.Fatal("unknown format was accepted")
+	}
+}
+
+// formatYAML 一个只含一种赛制的赛制文件；broken 时自由辩论阶段的发言顺序不合法
+func formatYAML(name string, broken bool) string {
+	order := "free"
+	if broken {
+		order = "alternate"
+	}
+	return `formats:
+  - name: ` + name + `
+    description: 快速赛
+    max_per_side: 2
+    phases:
+      - {phase: opening, title: 立论, task: opening, order: pro_first, word_limit: 100}
+      - {phase: free_debate, task: free_debate, order: ` + order + `, rounds: 1, turn_policy: least_spoken}
+`
+}
+
+func TestLoadDebateFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       []string
+		want        []string // 加载后应能按名称取到的赛制
+		wantMissing []string // 加载失败后不应注册的赛制
+		wantErr     string
+	}{
+		{
+			name:  "valid file",
+			files: []string{formatYAML("test_loaded_quick", false)},
+			want:  []string{"test_loaded_quick"},
+		},
+		{
+			name:  "several files",
+			files: []string{formatYAML("test_loaded_first", false), formatYAML("test_loaded_second", false)},
+			want:  []string{"test_loaded_first", "test_loaded_second"},
+		},
+		{
+			name:        "invalid format is rejected",
+			files:       []string{formatYAML("test_loaded_broken", true)},
+			wantMissing: []string{"test_loaded_broken"},
+			wantErr:     "free_debate phase needs order free",
+		},
+		{
+			name:        "a later invalid file leaves the registry unchanged",
+			files:       []string{formatYAML("test_loaded_valid", false), formatYAML("test_loaded_later_broken", true)},
+			wantMissing: []string{"test_loaded_valid", "test_loaded_later_broken"},
+			wantErr:     "formats1.yaml",
+		},
+		{
+			name:        "a missing file leaves the registry unchanged",
+			files:       []string{formatYAML("test_loaded_before_missing", false), ""},
+			wantMissing: []string{"test_loaded_before_missing"},
+			wantErr:     "read debate formats",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var paths []string
+			for i, content := range tt.files {
+				path := filepath.Join(dir, fmt.Sprintf("formats%d.yaml", i))
+				if content != "" {
+					if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				paths = append(paths, path)
+			}
+
+			formats, err := LoadDebateFormats(paths...)
+			for _, name := range tt.wantMissing {
+				if _, err := GetDebateFormat(name); err == nil {
+					t.Errorf("format %s was registered by a failed load", name)
+				}
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadDebateFormats() = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(formats) != len(tt.want) {
+				t.Errorf("loaded %d formats, want %d", len(formats), len(tt.want))
+			}
+			for _, name := range tt.want {
+				f, err := GetDebateFormat(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if f.MaxPerSide != 2 || len(f.Phases) != 2 || f.Phases[1].TurnPolicy != TurnLeastSpoken {
+					t.Errorf("loaded format = %+v", f)
+				}
+			}
+		})
+	}
+}
@@ -1,10 +1,14 @@
 package philosopher
 
 import (
+	"context"
 	"regexp"
 	"strings"
+	"sync/atomic"
 
 	"agent/config"
+	"agent/metrics"
+	"agent/tracing"
 )
 
 // EmotionLevel 情绪级别
@@ -20,7 +24,8 @@ const (
 
 // EmotionAnalyzer 情绪分析器
 type EmotionAnalyzer struct {
-	model *config.ChatModel
+	model    config.Provider
+	enableAI atomic.Bool // 关键词无法判断时是否调用 AI 深度分析（可热更新）
 
 	// 关键词规则（快速判断）
 	painKeywords        []string
@@ -30,8 +35,8 @@ type EmotionAnalyzer struct {
 }
 
 // NewEmotionAnalyzer 创建情绪分析器
-func NewEmotionAnalyzer(model *config.ChatModel) *EmotionAnalyzer {
-	return &EmotionAnalyzer{
+func NewEmotionAnalyzer(model config.Provider) *EmotionAnalyzer {
+	a := &EmotionAnalyzer{
 		model: model,
 		painKeywords: []string{
 			"好痛苦", "受不了", "活不下去", "想死", "崩溃", "绝望",
@@ -54,18 +59,33 @@ func NewEmotionAnalyzer(model *config.ChatModel) *EmotionAnalyzer {
 			"不是我的错", "我尽力了", "我没有能力",
 		},
 	}
+	a.enableAI.Store(true)
+	return a
+}
+
+// SetAIAnalysis 设置是否启用 AI 深度情绪分析（关闭后只使用关键词规则）
+func (a *EmotionAnalyzer) SetAIAnalysis(enabled bool) {
+	a.enableAI.Store(enabled)
 }
 
 // Analyze 分析用户输入的情绪
-func (a *EmotionAnalyzer) Analyze(text string) EmotionLevel {
+func (a *EmotionAnalyzer) Analyze(ctx context.Context, text string) EmotionLevel {
+	ctx, span := tracing.Start(ctx, "emotion.analyze")
+	defer span.End(nil)
+
 	// 第一层：关键词快速判断
 	level := a.quickAnalyze(text)
-	if level != EmotionNeutral {
-		return level
+	method := "keyword"
+	if level == EmotionNeutral {
+		// 如果关键词无法判断，使用 AI 分析
+		level = a.aiAnalyze(ctx, text)
+		method = "ai"
 	}
 
-	// 如果关键词无法判断，使用 AI 分析
-	return a.aiAnalyze(text)
+	span.SetAttr("level", string(level))
+	span.SetAttr("method", method)
+	metrics.EmotionLevels.Inc(string(level))
+	return level
 }
 
 // quickAnalyze 快速关键词分析
@@ -124,8 +144,8 @@ func (a *EmotionAnalyzer) containsStrongPainIndicator(text string) bool {
 }
 
 // aiAnalyze 使用 AI 进行深度情绪分析
-func (a *EmotionAnalyzer) aiAnalyze(text string) EmotionLevel {
-	if a.model == nil {
+func (a *EmotionAnalyzer) aiAnalyze(ctx context.Context, text string) EmotionLevel {
+	if a.model == nil || !a.enableAI.Load() || config.BudgetLevelFrom(ctx) >= config.BudgetDegraded {
 		return EmotionNeutral
 	}
 
@@ -149,7 +169,8 @@ func (a *EmotionAnalyzer) aiAnalyze(text string) EmotionLevel {
 		{Role: "user", Content: prompt},
 	}
 
-	response, _, err := a.model.Invoke(messages, nil)
+	ctx = config.WithSemanticCache(config.WithUsageScope(ctx, config.ScopeEmotion), text)
+	response, _, err := a.model.Invoke(ctx, messages, nil)
 	if err != nil {
 		return EmotionNeutral
 	}
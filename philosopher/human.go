@@ -0,0 +1,185 @@
+package philosopher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ==================== 人类参与者 ====================
+// 人类作为一方的成员参加辩论：轮到人类发言时引擎暂停，等待 API 或 CLI 提交发言；
+// 人类的发言与 AI 的发言一样写入辩论纪要，AI 成员会照常质询、反驳；
+// 等待期间辩论被暂停时停表，继续后按剩余时长重新计时
+
+// HumanSpeaker 人类参与者在辩论中的代号
+const HumanSpeaker PhilosopherType = "human"
+
+// DefaultHumanTimeout 未指定时等待人类发言的时长
+const DefaultHumanTimeout = 3 * time.Minute
+
+// HumanPassText 人类跳过发言时写入纪要的内容
+const HumanPassText = "（没有发言）"
+
+// ErrHumanTimeout 等待人类发言超时（且未开启自动跳过）
+var ErrHumanTimeout = errors.New("等待人类发言超时")
+
+// HumanConfig 人类参与者配置
+type HumanConfig struct {
+	Side           DebateSide `json:"side"`                      // 加入哪一方：pro / con，作为该方的第一位成员
+	Name           string     `json:"name,omitempty"`            // 显示名称，默认"你"
+	TimeoutSeconds int        `json:"timeout_seconds,omitempty"` // 每次发言的等待时长（秒），0 使用默认值
+	AutoPass       bool       `json:"auto_pass,omitempty"`       // 超时后自动跳过本次发言；否则超时结束辩论
+
+	Input HumanInput `json:"-"` // 发言的输入端（API / CLI）
+}
+
+// Validate 校验人类参与者配置
+func (h *HumanConfig) Validate() error {
+	if h.Side != SidePro && h.Side != SideCon {
+		return fmt.Errorf("human side must be pro or con, got %q", h.Side)
+	}
+	if h.TimeoutSeconds < 0 {
+		return fmt.Errorf("human timeout_seconds must not be negative, got %d", h.TimeoutSeconds)
+	}
+	return nil
+}
+
+// DisplayName 显示名称
+func (h *HumanConfig) DisplayName() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return "你"
+}
+
+// timeout 每次发言的等待时长
+func (h *HumanConfig) timeout() time.Duration {
+	if h.TimeoutSeconds > 0 {
+		return time.Duration(h.TimeoutSeconds) * time.Second
+	}
+	return DefaultHumanTimeout
+}
+
+// HumanTurn 轮到人类发言时交给输入端的信息
+type HumanTurn struct {
+	Topic         string         `json:"topic"`
+	Stance        string         `json:"stance"` // 人类所在一方的立场
+	Phase         DebatePhase    `json:"phase"`
+	Task          DebateTaskType `json:"task"`
+	Instruction   string         `json:"instruction"`
+	TargetName    string         `json:"target_name,omitempty"` // 质询 / 回应的对象
+	Question      string         `json:"question,omitempty"`    // 回应质询时对方的问题
+	WordLimit     int            `json:"word_limit"`
+	ModeratorNote string         `json:"moderator_note,omitempty"` // 主持人插入的提示
+	Deadline      time.Time      `json:"deadline"`                 // 暂停后继续时顺延
+}
+
+// HumanInput 人类发言的输入端
+type HumanInput interface {
+	// Await 等待人类提交发言；ctx 到期时返回 ctx 的错误，返回空字符串表示跳过
+	Await(ctx context.Context, turn HumanTurn) (string, error)
+}
+
+// HumanTurnUpdater 可选：输入端实现后，截止时间因暂停顺延时收到更新后的回合
+type HumanTurnUpdater interface {
+	UpdateTurn(turn HumanTurn)
+}
+
+// humanSpeak 等待人类发言：超时时按配置自动跳过或结束辩论
+func (e *DebateEngine) humanSpeak(ctx context.Context, task DebateTask) (string, error) {
+	h := e.config.Human
+	if h == nil || h.Input == nil {
+		return "", errors.New("人类参与者没有输入端")
+	}
+
+	stance := e.config.ProStance
+	if h.Side == SideCon {
+		stance = e.config.ConStance
+	}
+	turn := HumanTurn{
+		Topic:         e.config.Topic,
+		Stance:        stance,
+		Phase:         e.context.CurrentPhase,
+		Task:          task.Type,
+		Instruction:   task.Instruction,
+		TargetName:    task.TargetName,
+		WordLimit:     task.wordLimit(),
+		ModeratorNote: task.ModeratorNote,
+		Deadline:      time.Now().Add(h.timeout()),
+	}
+	if task.Type == TaskAnswer {
+		if n := len(e.context.History); n > 0 {
+			turn.Question = e.context.History[n-1].Content
+		}
+	}
+
+	// 计时器到时以 context.DeadlineExceeded 为原因取消 waitCtx；返回前等计时器退出，之后不会再更新回合
+	waitCtx, expire := context.WithCancelCause(ctx)
+	timerDone := make(chan struct{})
+	go func() {
+		defer close(timerDone)
+		e.humanTimer(waitCtx, expire, turn)
+	}()
+	content, err := h.Input.Await(waitCtx, turn)
+	timedOut := errors.Is(context.Cause(waitCtx), context.DeadlineExceeded)
+	expire(nil)
+	<-timerDone
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return "", ctx.Err()
+	case err != nil && timedOut:
+		if !h.AutoPass {
+			return "", ErrHumanTimeout
+		}
+		return HumanPassText, nil
+	case err != nil:
+		return "", err
+	}
+
+	if content = strings.TrimSpace(content); content == "" {
+		return HumanPassText, nil
+	}
+	return content, nil
+}
+
+// humanTimer 人类发言的计时器：暂停期间停表，继续后按剩余时长重新计时，并把顺延后的截止时间通知输入端
+func (e *DebateEngine) humanTimer(ctx context.Context, expire context.CancelCauseFunc, turn HumanTurn) {
+	remaining := time.Until(turn.Deadline)
+	wasPaused := false
+	for {
+		paused, changed := e.pauseState()
+		if paused {
+			wasPaused = true
+			select {
+			case <-changed:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if wasPaused {
+			wasPaused = false
+			turn.Deadline = time.Now().Add(remaining)
+			if u, ok := e.config.Human.Input.(HumanTurnUpdater); ok {
+				u.UpdateTurn(turn)
+			}
+		}
+
+		started := time.Now()
+		timer := time.NewTimer(remaining)
+		select {
+		case <-timer.C:
+			expire(context.DeadlineExceeded)
+			return
+		case <-changed: // 没有控制器时为 nil，永远不会触发
+			timer.Stop()
+			remaining -= time.Since(started)
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package philosopher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// channelHuman 通过通道交互的人类输入端：开始等待时送出回合，收到 replies 时提交发言
+type channelHuman struct {
+	turns   chan HumanTurn
+	updates chan HumanTurn
+	replies chan string
+}
+
+func newChannelHuman() *channelHuman {
+	return &channelHuman{turns: make(chan HumanTurn, 1), updates: make(chan HumanTurn, 4), replies: make(chan string)}
+}
+
+func (h *channelHuman) Await(ctx context.Context, turn HumanTurn) (string, error) {
+	h.turns <- turn
+	select {
+	case content := <-h.replies:
+		return content, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (h *channelHuman) UpdateTurn(turn HumanTurn) {
+	h.updates <- turn
+}
+
+// startHumanTurn 在后台等待一次人类发言（每次等待 1 秒），返回输入端与结果通道
+func startHumanTurn(ctx context.Context, autoPass bool, control *DebateControl) (*channelHuman, HumanTurn, <-chan humanResult) {
+	human := newChannelHuman()
+	e := &DebateEngine{
+		config: &DebateConfig{
+			Human:   &HumanConfig{Side: SidePro, TimeoutSeconds: 1, AutoPass: autoPass, Input: human},
+			Control: control,
+		},
+		context: &DebateContext{},
+	}
+	results := make(chan humanResult, 1)
+	go func() {
+		content, err := e.humanSpeak(ctx, DebateTask{Type: TaskOpening})
+		results <- humanResult{content, err}
+	}()
+	return human, <-human.turns, results
+}
+
+type humanResult struct {
+	content string
+	err     error
+}
+
+// closeTo 两个时间相差不超过 150ms
+func closeTo(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d > -150*time.Millisecond && d < 150*time.Millisecond
+}
+
+func TestHumanSpeakTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		autoPass    bool
+		wantContent string
+		wantErr     error
+	}{
+		{name: "auto pass", autoPass: true, wantContent: HumanPassText},
+		{name: "timeout ends the debate", autoPass: false, wantErr: ErrHumanTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			start := time.Now()
+			_, turn, results := startHumanTurn(context.Background(), tt.autoPass, NewDebateControl())
+			if !closeTo(turn.Deadline, start.Add(time.Second)) {
+				t.Errorf("deadline = %v, want about 1s from now", turn.Deadline.Sub(start))
+			}
+
+			r := <-results
+			if r.content != tt.wantContent || !errors.Is(r.err, tt.wantErr) {
+				t.Fatalf("got (%q, %v), want (%q, %v)", r.content, r.err, tt.wantContent, tt.wantErr)
+			}
+			if !closeTo(time.Now(), turn.Deadline) {
+				t.Errorf("timed out after %v, want at the deadline", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestHumanSpeakPauseStopsClock(t *testing.T) {
+	tests := []struct {
+		name        string
+		reply       bool // 继续后提交发言；否则等到顺延后的截止时间
+		wantContent string
+	}{
+		{name: "timeout is postponed by the pause", wantContent: HumanPassText},
+		{name: "speech after resume", reply: true, wantContent: "我想和大家一起"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			control := NewDebateControl()
+			human, turn, results := startHumanTurn(context.Background(), true, control)
+
+			time.Sleep(300 * time.Millisecond)
+			control.Pause()
+			// 越过原来的截止时间后仍在等待
+			time.Sleep(time.Until(turn.Deadline) + 300*time.Millisecond)
+			select {
+			case r := <-results:
+				t.Fatalf("turn ended while paused: (%q, %v)", r.content, r.err)
+			default:
+			}
+
+			control.Resume()
+			resumed := time.Now()
+			var updated HumanTurn
+			select {
+			case updated = <-human.updates:
+			case <-time.After(time.Second):
+				t.Fatal("deadline was not updated after resume")
+			}
+			// 暂停前已过去约 300ms，剩下约 700ms
+			if want := resumed.Add(700 * time.Millisecond); !closeTo(updated.Deadline, want) {
+				t.Errorf("new deadline = %v after resume, want about 700ms", updated.Deadline.Sub(resumed))
+			}
+
+			if tt.reply {
+				human.replies <- tt.wantContent
+			}
+			r := <-results
+			if r.err != nil || r.content != tt.wantContent {
+				t.Fatalf("got (%q, %v), want %q", r.content, r.err, tt.wantContent)
+			}
+			if !tt.reply && !closeTo(time.Now(), updated.Deadline) {
+				t.Errorf("timed out %v after resume, want at the new deadline", time.Since(resumed))
+			}
+		})
+	}
+}
+
+func TestHumanSpeakCancelledWhilePaused(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	control := NewDebateControl()
+	human, _, results := startHumanTurn(ctx, true, control)
+
+	control.Pause()
+	cancel()
+	r := <-results
+	if !errors.Is(r.err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", r.err)
+	}
+	// 没有继续过，不会顺延截止时间
+	select {
+	case turn := <-human.updates:
+		t.Errorf("unexpected update %+v", turn)
+	default:
+	}
+}
@@ -0,0 +1,502 @@
+package philosopher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"agent/config"
+	"agent/tracing"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ==================== 裁判 ====================
+// 裁判按评分标准给每次发言打分，汇总到每方与每位成员，并给出胜负判定与理由；
+// 多位评委组成评审团时按相对多数判定，并报告评委之间的分歧
+
+// DebateSide 辩论中的一方（也用于胜负判定）
+type DebateSide string
+
+const (
+	SidePro DebateSide = "pro" // 正方
+	SideCon DebateSide = "con" // 反方
+	SideTie DebateSide = "tie" // 平局（仅用于判定）
+)
+
+// 评分标准：每项 0~10 分，0 表示不适用（例如开篇立论没有可反驳的对象）
+const (
+	CriterionArgumentStrength = "argument_strength" // 论证力度
+	CriterionRebuttalQuality  = "rebuttal_quality"  // 反驳质量
+	CriterionCharacter        = "character"         // 角色一致性
+	CriterionResponsiveness   = "responsiveness"    // 回应问题
+)
+
+// RubricScores 各项评分的平均值（不计不适用的项）
+type RubricScores struct {
+	ArgumentStrength float64 `json:"argument_strength"`
+	RebuttalQuality  float64 `json:"rebuttal_quality"`
+	Character        float64 `json:"character"`
+	Responsiveness   float64 `json:"responsiveness"`
+	Total            float64 `json:"total"`    // 各项平均分的平均（不计没有分数的项）
+	Speeches         int     `json:"speeches"` // 参与平均的发言次数（多位评委时按评委重复计）
+}
+
+// SpeechScore 一次发言的评分
+type SpeechScore struct {
+	Index            int             `json:"index"` // 在发言记录中的位置
+	Speaker          PhilosopherType `json:"speaker"`
+	Side             DebateSide      `json:"side"`
+	ArgumentStrength int             `json:"argument_strength"`
+	RebuttalQuality  int             `json:"rebuttal_quality"`
+	Character        int             `json:"character"`
+	Responsiveness   int             `json:"responsiveness"`
+	Comment          string          `json:"comment,omitempty"`
+}
+
+// JudgeVerdict 一位评委的评判
+type JudgeVerdict struct {
+	Judge     string        `json:"judge"`
+	Winner    DebateSide    `json:"winner"`
+	Reasoning string        `json:"reasoning"`
+	Speeches  []SpeechScore `json:"speeches"`
+}
+
+// Judgement 评审结果
+type Judgement struct {
+	Winner       DebateSide                       `json:"winner"`
+	Reasoning    string                           `json:"reasoning"`
+	Votes        map[DebateSide]int               `json:"votes"`
+	BySide       map[DebateSide]RubricScores      `json:"by_side"`
+	BySpeaker    map[PhilosopherType]RubricScores `json:"by_speaker"`
+	Verdicts     []JudgeVerdict                   `json:"verdicts"`
+	FailedJudges []string                         `json:"failed_judges,omitempty"` // 评判失败的评委，结果只汇总其余评委
+	Disagreement *JudgeDisagreement               `json:"disagreement,omitempty"`  // 仅多位评委时
+}
+
+// JudgeDisagreement 评委之间的分歧
+type JudgeDisagreement struct {
+	Unanimous     bool                        `json:"unanimous"`
+	Dissenters    []string                    `json:"dissenters,omitempty"`     // 与最终判定不同的评委
+	SpeakerSpread map[PhilosopherType]float64 `json:"speaker_spread,omitempty"` // 各评委给该成员的总分的极差
+	MaxSpread     float64                     `json:"max_spread"`
+}
+
+// judgePersona 评委的身份与关注点，让评审团的视角各不相同
+type judgePersona struct {
+	Name  string
+	Focus string
+}
+
+var judgePersonas = []judgePersona{
+	{Name: "辩论教练", Focus: "你是一位严谨的辩论教练，最看重论证是否成立、反驳是否击中对方要害"},
+	{Name: "Live House 店长", Focus: "你是 RiNG 的店长，看过无数乐队，最看重每个人是否真诚、说话是否像她自己"},
+	{Name: "观众代表", Focus: "你是一名普通观众，最看重发言是否打动人、有没有正面回答别人的问题"},
+}
+
+// MaxJudges 评审团最多的评委人数（每位评委的身份不同）
+const MaxJudges = 3
+
+// JudgeAgent 评委
+type JudgeAgent struct {
+	Name  string
+	focus string
+	model config.Provider
+}
+
+// NewJudgeAgent 创建评委，focus 描述评委的身份与关注点
+func NewJudgeAgent(model config.Provider, name, focus string) *JudgeAgent {
+	return &JudgeAgent{Name: name, focus: focus, model: model}
+}
+
+// JudgePanel 评审团
+type JudgePanel struct {
+	judges []*JudgeAgent
+}
+
+// NewJudgePanel 创建 n 人评审团（最多 MaxJudges 人）
+func NewJudgePanel(model config.Provider, n int) *JudgePanel {
+	panel := &JudgePanel{}
+	for _, persona := range judgePersonas[:min(max(n, 1), MaxJudges)] {
+		panel.judges = append(panel.judges, NewJudgeAgent(model, persona.Name, persona.Focus))
+	}
+	return panel
+}
+
+// Judge 各评委并行评判，再汇总评分与判定；部分评委失败时用其余评委的结果
+func (p *JudgePanel) Judge(ctx context.Context, cfg *DebateConfig, format *DebateFormat, records []DebateRecord) (*Judgement, error) {
+	verdicts := make([]*JudgeVerdict, len(p.judges))
+	errs := make([]error, len(p.judges))
+	var wg sync.WaitGroup
+	for i, j := range p.judges {
+		wg.Add(1)
+		go func(i int, j *JudgeAgent) {
+			defer wg.Done()
+			verdicts[i], errs[i] = j.Evaluate(ctx, cfg, format, records)
+		}(i, j)
+	}
+	wg.Wait()
+
+	var (
+		succeeded []JudgeVerdict
+		failed    []string
+	)
+	for i, v := range verdicts {
+		if errs[i] != nil {
+			log.Warn().Err(errs[i]).Str("judge", p.judges[i].Name).Msg("评委评判失败")
+			failed = append(failed, p.judges[i].Name)
+			continue
+		}
+		succeeded = append(succeeded, *v)
+	}
+	if len(succeeded) == 0 {
+		return nil, fmt.Errorf("所有评委都评判失败: %w", errors.Join(errs...))
+	}
+	j := aggregateVerdicts(succeeded)
+	j.FailedJudges = failed
+	return j, nil
+}
+
+// Evaluate 对整场辩论的每次发言评分并判定胜负
+func (j *JudgeAgent) Evaluate(ctx context.Context, cfg *DebateConfig, format *DebateFormat, records []DebateRecord) (_ *JudgeVerdict, err error) {
+	if len(records) == 0 {
+		return nil, errors.New("没有可评判的发言")
+	}
+
+	ctx = config.WithUsageScope(ctx, config.ScopeJudge)
+	ctx, span := tracing.Start(ctx, "debate.judge")
+	span.SetAttr("judge", j.Name)
+	defer func() { span.End(err) }()
+
+	messages := []config.Message{
+		{Role: "system", Content: j.buildJudgePrompt()},
+		{Role: "user", Content: buildTranscript(cfg, format, records)},
+	}
+
+	output := &judgeOutput{speeches: len(records)}
+	if err := config.InvokeStructured(ctx, j.model, messages, judgeSchema, output); err != nil {
+		return nil, err
+	}
+
+	verdict := &JudgeVerdict{
+		Judge:     j.Name,
+		Winner:    output.Winner,
+		Reasoning: strings.TrimSpace(output.Reasoning),
+	}
+	for _, s := range output.Speeches {
+		r := records[s.Index]
+		s.Speaker = r.Speaker
+		s.Side = sideOf(cfg, r.Speaker)
+		verdict.Speeches = append(verdict.Speeches, s)
+	}
+	sort.Slice(verdict.Speeches, func(a, b int) bool { return verdict.Speeches[a].Index < verdict.Speeches[b].Index })
+	return verdict, nil
+}
+
+// buildJudgePrompt 构建评委的系统 Prompt
+func (j *JudgeAgent) buildJudgePrompt() string {
+	return j.focus + `。现在请你担任这场乐队讨论会的评委。
+
+【评分标准】每次发言的每一项打 0~10 分：
+1. argument_strength 论证力度：观点是否清楚、有理由支撑
+2. rebuttal_quality 反驳质量：是否抓住并回应了对方的观点；没有可反驳的对象时（如第一个开篇立论）打 0 表示不适用
+3. character 角色一致性：说话方式、性格是否符合该成员的人设
+4. responsiveness 回应问题：被提问时是否正面回答；发言不是在回应问题时打 0 表示不适用
+
+argument_strength 与 character 必须打 1~10 分。
+
+【判定】综合整场表现判定哪一方更有说服力：pro（正方）、con（反方）或 tie（平局），并说明理由。
+
+【输出格式】
+请只输出一个 JSON 对象，不要包含任何其他文字，speeches 中每次发言一项（index 对应发言编号）：
+{
+  "speeches": [
+    {"index": 0, "argument_strength": 7, "rebuttal_quality": 0, "character": 8, "responsiveness": 0, "comment": "一句话点评"}
+  ],
+  "winner": "pro / con / tie",
+  "reasoning": "判定理由"
+}`
+}
+
+// buildTranscript 把辩论整理为带编号的发言记录
+func buildTranscript(cfg *DebateConfig, format *DebateFormat, records []DebateRecord) string {
+	prompts := GetPhilosopherPrompts()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("【辩题】%s\n", cfg.Topic))
+	sb.WriteString(fmt.Sprintf("【正方立场】%s\n【反方立场】%s\n\n", cfg.ProStance, cfg.ConStance))
+
+	sb.WriteString("【成员与人设】\n")
+	for _, side := range []struct {
+		name    string
+		members []PhilosopherType
+	}{{"正方", cfg.ProPhilosophers}, {"反方", cfg.ConPhilosophers}} {
+		for _, pType := range side.members {
+			if prompt, ok := prompts[pType]; ok {
+				sb.WriteString(fmt.Sprintf("- %s %s：\n%s\n", side.name, prompt.Name, prompt.LinguisticStyle))
+			} else if pType == HumanSpeaker && cfg.Human != nil {
+				sb.WriteString(fmt.Sprintf("- %s %s：人类参与者，没有人设，character 一项按发言是否真诚、清楚打分\n", side.name, cfg.Human.DisplayName()))
+			}
+		}
+	}
+
+	sb.WriteString("\n【发言记录】\n")
+	for i, r := range records {
+		sb.WriteString(fmt.Sprintf("[%d]（%s）%s %s", i, format.PhaseTitle(r.Phase), sideOf(cfg, r.Speaker).Title(), r.SpeakerName))
+		switch {
+		case r.TargetSpeaker == HumanSpeaker && cfg.Human != nil:
+			sb.WriteString(" → " + cfg.Human.DisplayName())
+		case r.TargetSpeaker != "":
+			if prompt, ok := prompts[r.TargetSpeaker]; ok {
+				sb.WriteString(" → " + prompt.Name)
+			}
+		}
+		sb.WriteString(fmt.Sprintf("：\n%s\n\n", r.Content))
+	}
+	return sb.String()
+}
+
+// sideOf 成员所在的一方
+func sideOf(cfg *DebateConfig, pType PhilosopherType) DebateSide {
+	if contains(cfg.ConPhilosophers, pType) {
+		return SideCon
+	}
+	return SidePro
+}
+
+// judgeSchema 评委输出的 JSON Schema
+var judgeSchema = &config.JSONSchema{
+	Name:   "debate_judgement",
+	Strict: true,
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"speeches": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"index":             map[string]interface{}{"type": "integer", "minimum": 0},
+						"argument_strength": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 10},
+						"rebuttal_quality":  map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 10},
+						"character":         map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 10},
+						"responsiveness":    map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 10},
+						"comment":           map[string]interface{}{"type": "string"},
+					},
+					"required":             []string{"index", "argument_strength", "rebuttal_quality", "character", "responsiveness", "comment"},
+					"additionalProperties": false,
+				},
+			},
+			"winner":    map[string]interface{}{"type": "string", "enum": []string{string(SidePro), string(SideCon), string(SideTie)}},
+			"reasoning": map[string]interface{}{"type": "string"},
+		},
+		"required":             []string{"speeches", "winner", "reasoning"},
+		"additionalProperties": false,
+	},
+}
+
+// judgeOutput 评委的结构化输出
+type judgeOutput struct {
+	Speeches  []SpeechScore `json:"speeches"`
+	Winner    DebateSide    `json:"winner"`
+	Reasoning string        `json:"reasoning"`
+
+	speeches int // 发言总数，用于校验每次发言都有评分
+}
+
+// Validate 每次发言恰好评分一次，分数在范围内，判定有效
+func (o *judgeOutput) Validate() error {
+	switch o.Winner {
+	case SidePro, SideCon, SideTie:
+	default:
+		return fmt.Errorf("winner must be pro, con or tie, got %q", o.Winner)
+	}
+	if strings.TrimSpace(o.Reasoning) == "" {
+		return errors.New("reasoning is required")
+	}
+
+	seen := make(map[int]bool, len(o.Speeches))
+	for _, s := range o.Speeches {
+		if s.Index < 0 || s.Index >= o.speeches {
+			return fmt.Errorf("speech index %d out of range [0, %d)", s.Index, o.speeches)
+		}
+		if seen[s.Index] {
+			return fmt.Errorf("speech %d is scored more than once", s.Index)
+		}
+		seen[s.Index] = true
+
+		if s.ArgumentStrength < 1 || s.ArgumentStrength > 10 || s.Character < 1 || s.Character > 10 {
+			return fmt.Errorf("speech %d: argument_strength and character must be between 1 and 10", s.Index)
+		}
+		if s.RebuttalQuality < 0 || s.RebuttalQuality > 10 || s.Responsiveness < 0 || s.Responsiveness > 10 {
+			return fmt.Errorf("speech %d: rebuttal_quality and responsiveness must be between 0 and 10", s.Index)
+		}
+	}
+	if len(seen) != o.speeches {
+		return fmt.Errorf("expected scores for all %d speeches, got %d", o.speeches, len(seen))
+	}
+	return nil
+}
+
+// ==================== 汇总 ====================
+
+// rubricSum 累计各项评分，0 分（不适用）不计入
+type rubricSum struct {
+	sum      [4]float64
+	n        [4]int
+	speeches int
+}
+
+func (r *rubricSum) add(s SpeechScore) {
+	for i, v := range [4]int{s.ArgumentStrength, s.RebuttalQuality, s.Character, s.Responsiveness} {
+		if v > 0 {
+			r.sum[i] += float64(v)
+			r.n[i]++
+		}
+	}
+	r.speeches++
+}
+
+func (r *rubricSum) scores() RubricScores {
+	var avg [4]float64
+	var total float64
+	var items int
+	for i := range avg {
+		if r.n[i] > 0 {
+			avg[i] = r.sum[i] / float64(r.n[i])
+			total += avg[i]
+			items++
+		}
+	}
+	if items > 0 {
+		total /= float64(items)
+	}
+	return RubricScores{
+		ArgumentStrength: avg[0],
+		RebuttalQuality:  avg[1],
+		Character:        avg[2],
+		Responsiveness:   avg[3],
+		Total:            total,
+		Speeches:         r.speeches,
+	}
+}
+
+// aggregateVerdicts 汇总各评委的评分：按相对多数判定胜负，正反方票数并列最多时比较双方总分
+func aggregateVerdicts(verdicts []JudgeVerdict) *Judgement {
+	bySide := make(map[DebateSide]*rubricSum)
+	bySpeaker := make(map[PhilosopherType]*rubricSum)
+	perJudge := make([]map[PhilosopherType]*rubricSum, len(verdicts))
+	votes := make(map[DebateSide]int)
+
+	for i, v := range verdicts {
+		votes[v.Winner]++
+		perJudge[i] = make(map[PhilosopherType]*rubricSum)
+		for _, s := range v.Speeches {
+			for _, m := range []map[PhilosopherType]*rubricSum{bySpeaker, perJudge[i]} {
+				if m[s.Speaker] == nil {
+					m[s.Speaker] = &rubricSum{}
+				}
+				m[s.Speaker].add(s)
+			}
+			if bySide[s.Side] == nil {
+				bySide[s.Side] = &rubricSum{}
+			}
+			bySide[s.Side].add(s)
+		}
+	}
+
+	j := &Judgement{
+		Votes:     votes,
+		BySide:    make(map[DebateSide]RubricScores, len(bySide)),
+		BySpeaker: make(map[PhilosopherType]RubricScores, len(bySpeaker)),
+		Verdicts:  verdicts,
+	}
+	for side, sum := range bySide {
+		j.BySide[side] = sum.scores()
+	}
+	for pType, sum := range bySpeaker {
+		j.BySpeaker[pType] = sum.scores()
+	}
+
+	// 相对多数：平局票与正反方票一起比较；只有正反方并列最多时才看总分，
+	// 某一方与平局并列最多时判为平局
+	switch pro, con, tie := votes[SidePro], votes[SideCon], votes[SideTie]; {
+	case pro > con && pro > tie:
+		j.Winner = SidePro
+	case con > pro && con > tie:
+		j.Winner = SideCon
+	case pro != con || tie > pro:
+		j.Winner = SideTie
+	case j.BySide[SidePro].Total > j.BySide[SideCon].Total:
+		j.Winner = SidePro
+	case j.BySide[SideCon].Total > j.BySide[SidePro].Total:
+		j.Winner = SideCon
+	default:
+		j.Winner = SideTie
+	}
+
+	if len(verdicts) == 1 {
+		j.Reasoning = verdicts[0].Reasoning
+		return j
+	}
+
+	// 评审团：报告分歧
+	d := &JudgeDisagreement{Unanimous: true, SpeakerSpread: make(map[PhilosopherType]float64)}
+	for _, v := range verdicts {
+		if v.Winner != j.Winner {
+			d.Unanimous = false
+			d.Dissenters = append(d.Dissenters, v.Judge)
+		}
+	}
+	for pType := range bySpeaker {
+		lo, hi, first := 0.0, 0.0, true
+		for _, m := range perJudge {
+			sum, ok := m[pType]
+			if !ok {
+				continue
+			}
+			t := sum.scores().Total
+			if first || t < lo {
+				lo = t
+			}
+			if first || t > hi {
+				hi = t
+			}
+			first = false
+		}
+		d.SpeakerSpread[pType] = hi - lo
+		d.MaxSpread = max(d.MaxSpread, hi-lo)
+	}
+	j.Disagreement = d
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("评审团 %d:%d:%d（正方:反方:平局）判定%s。", votes[SidePro], votes[SideCon], votes[SideTie], j.Winner.Verdict()))
+	for _, v := range verdicts {
+		sb.WriteString(fmt.Sprintf("\n%s（判%s）：%s", v.Judge, v.Winner.Verdict(), v.Reasoning))
+	}
+	j.Reasoning = sb.String()
+	return j
+}
+
+// Title 中文名称：正方 / 反方 / 平局
+func (s DebateSide) Title() string {
+	switch s {
+	case SidePro:
+		return "正方"
+	case SideCon:
+		return "反方"
+	default:
+		return "平局"
+	}
+}
+
+// Verdict 作为判定结果的说法：正方胜 / 反方胜 / 平局
+func (s DebateSide) Verdict() string {
+	if s == SidePro || s == SideCon {
+		return s.Title() + "胜"
+	}
+	return s.Title()
+}
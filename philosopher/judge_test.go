@@ -0,0 +1,201 @@
+package philosopher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"agent/config"
+)
+
+// verdict 一位评委的评判：正反方各一次发言，分数为四项的统一得分
+func verdict(judge string, winner DebateSide, proScore, conScore int) JudgeVerdict {
+	score := func(speaker PhilosopherType, side DebateSide, v int) SpeechScore {
+		return SpeechScore{Speaker: speaker, Side: side, ArgumentStrength: v, RebuttalQuality: v, Character: v, Responsiveness: v}
+	}
+	return JudgeVerdict{
+		Judge:     judge,
+		Winner:    winner,
+		Reasoning: judge + "的理由",
+		Speeches:  []SpeechScore{score(TakamatsuTomori, SidePro, proScore), score(ChihayaAnon, SideCon, conScore)},
+	}
+}
+
+func TestAggregateVerdicts(t *testing.T) {
+	tests := []struct {
+		name           string
+		verdicts       []JudgeVerdict
+		want           DebateSide
+		wantUnanimous  bool
+		wantDissenters []string
+	}{
+		{
+			// 评委判平局时不能被总分改判
+			name:     "single judge tie",
+			verdicts: []JudgeVerdict{verdict("A", SideTie, 8, 6)},
+			want:     SideTie,
+		},
+		{
+			name:     "single judge",
+			verdicts: []JudgeVerdict{verdict("A", SideCon, 8, 6)},
+			want:     SideCon,
+		},
+		{
+			name:          "unanimous tie",
+			verdicts:      []JudgeVerdict{verdict("A", SideTie, 8, 6), verdict("B", SideTie, 7, 5), verdict("C", SideTie, 9, 4)},
+			want:          SideTie,
+			wantUnanimous: true,
+		},
+		{
+			name:           "1-1 split falls back to side totals",
+			verdicts:       []JudgeVerdict{verdict("A", SidePro, 8, 6), verdict("B", SideCon, 6, 7)},
+			want:           SidePro, // 正方平均 7，反方 6.5
+			wantDissenters: []string{"B"},
+		},
+		{
+			name:     "1-1 split with equal totals",
+			verdicts: []JudgeVerdict{verdict("A", SidePro, 8, 6), verdict("B", SideCon, 6, 8)},
+			want:     SideTie,
+			// 两位评委都与最终判定不同
+			wantDissenters: []string{"A", "B"},
+		},
+		{
+			// 多数票优先于总分
+			name:           "2-1 split",
+			verdicts:       []JudgeVerdict{verdict("A", SideCon, 6, 7), verdict("B", SidePro, 10, 2), verdict("C", SideCon, 6, 7)},
+			want:           SideCon,
+			wantDissenters: []string{"B"},
+		},
+		{
+			name:           "2-1 for tie",
+			verdicts:       []JudgeVerdict{verdict("A", SideTie, 6, 6), verdict("B", SidePro, 9, 5), verdict("C", SideTie, 7, 7)},
+			want:           SideTie,
+			wantDissenters: []string{"B"},
+		},
+		{
+			name:           "one side level with tie votes",
+			verdicts:       []JudgeVerdict{verdict("A", SidePro, 9, 5), verdict("B", SideTie, 7, 7)},
+			want:           SideTie,
+			wantDissenters: []string{"A"},
+		},
+		{
+			name:           "1-1-1 split falls back to side totals",
+			verdicts:       []JudgeVerdict{verdict("A", SidePro, 6, 8), verdict("B", SideCon, 6, 8), verdict("C", SideTie, 6, 8)},
+			want:           SideCon,
+			wantDissenters: []string{"A", "C"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := aggregateVerdicts(tt.verdicts)
+			if j.Winner != tt.want {
+				t.Fatalf("winner = %s, want %s (votes %v)", j.Winner, tt.want, j.Votes)
+			}
+
+			if len(tt.verdicts) == 1 {
+				if j.Disagreement != nil || j.Reasoning != tt.verdicts[0].Reasoning {
+					t.Errorf("single judge: disagreement = %+v, reasoning = %q", j.Disagreement, j.Reasoning)
+				}
+				return
+			}
+			d := j.Disagreement
+			if d == nil {
+				t.Fatal("panel result has no disagreement report")
+			}
+			if d.Unanimous != tt.wantUnanimous {
+				t.Errorf("unanimous = %v, want %v", d.Unanimous, tt.wantUnanimous)
+			}
+			if len(d.Dissenters) != len(tt.wantDissenters) {
+				t.Fatalf("dissenters = %v, want %v", d.Dissenters, tt.wantDissenters)
+			}
+			for i := range d.Dissenters {
+				if d.Dissenters[i] != tt.wantDissenters[i] {
+					t.Fatalf("dissenters = %v, want %v", d.Dissenters, tt.wantDissenters)
+				}
+			}
+		})
+	}
+}
+
+// judgeModel 评委测试用的模型：返回固定回复或错误
+type judgeModel struct {
+	reply string
+	err   error
+}
+
+func (m judgeModel) Invoke(ctx context.Context, messages []config.Message, tools []map[string]interface{}) (string, []config.ToolCall, error) {
+	return m.reply, nil, m.err
+}
+
+func TestJudgePanelPartialFailure(t *testing.T) {
+	const reply = `{"speeches": [
+		{"index": 0, "argument_strength": 8, "rebuttal_quality": 0, "character": 9, "responsiveness": 0, "comment": "真诚"},
+		{"index": 1, "argument_strength": 6, "rebuttal_quality": 5, "character": 7, "responsiveness": 0, "comment": "稳"}
+	], "winner": "pro", "reasoning": "正方更打动人"}`
+	failing := judgeModel{err: errors.New("upstream unavailable")}
+
+	tests := []struct {
+		name       string
+		models     []config.Provider
+		wantFailed []string
+		wantErr    bool
+	}{
+		{
+			name:   "all judges succeed",
+			models: []config.Provider{judgeModel{reply: reply}, judgeModel{reply: reply}},
+		},
+		{
+			name:       "one failing judge is reported",
+			models:     []config.Provider{judgeModel{reply: reply}, failing, judgeModel{reply: reply}},
+			wantFailed: []string{"评委1"},
+		},
+		{
+			name:    "all judges fail",
+			models:  []config.Provider{failing, failing},
+			wantErr: true,
+		},
+	}
+
+	cfg := &DebateConfig{
+		Topic:           "乐队应该优先考虑技术还是感情",
+		ProPhilosophers: []PhilosopherType{TakamatsuTomori},
+		ConPhilosophers: []PhilosopherType{NagasakiSoyo},
+	}
+	format, _ := GetDebateFormat("")
+	records := []DebateRecord{
+		{Speaker: TakamatsuTomori, Phase: PhaseOpening, Content: replyOpening},
+		{Speaker: NagasakiSoyo, Phase: PhaseOpening, Content: replyRebuttal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			panel := &JudgePanel{}
+			for i, m := range tt.models {
+				panel.judges = append(panel.judges, NewJudgeAgent(m, fmt.Sprintf("评委%d", i), ""))
+			}
+
+			j, err := panel.Judge(context.Background(), cfg, format, records)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "upstream unavailable") {
+					t.Fatalf("err = %v, want every judge's failure", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := len(tt.models) - len(tt.wantFailed); len(j.Verdicts) != want {
+				t.Errorf("verdicts = %d, want %d", len(j.Verdicts), want)
+			}
+			if strings.Join(j.FailedJudges, ",") != strings.Join(tt.wantFailed, ",") {
+				t.Errorf("failed judges = %v, want %v", j.FailedJudges, tt.wantFailed)
+			}
+			if j.Winner != SidePro || j.Disagreement == nil || !j.Disagreement.Unanimous {
+				t.Errorf("winner = %s, disagreement = %+v; want a unanimous pro verdict", j.Winner, j.Disagreement)
+			}
+		})
+	}
+}
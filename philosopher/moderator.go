@@ -1,17 +1,20 @@
 package philosopher
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"agent/config"
+	"agent/tracing"
 )
 
 // ==================== 主持人 Agent（自主驱动讨论）====================
 
 // ModeratorAgent 主持人 Agent，负责自主驱动讨论流程
 type ModeratorAgent struct {
-	model      *config.ChatModel
+	model      config.Provider
 	context    *DebateContext
 	members    map[PhilosopherType]*Philosopher
 	roundCount int
@@ -44,7 +47,7 @@ const (
 )
 
 // NewModeratorAgent 创建主持人 Agent
-func NewModeratorAgent(model *config.ChatModel, topic string, members map[PhilosopherType]*Philosopher) *ModeratorAgent {
+func NewModeratorAgent(model config.Provider, topic string, members map[PhilosopherType]*Philosopher) *ModeratorAgent {
 	return &ModeratorAgent{
 		model:     model,
 		members:   members,
@@ -71,7 +74,7 @@ func (m *ModeratorAgent) SetMaxRounds(rounds int) {
 }
 
 // Think 主持人思考下一步决策
-func (m *ModeratorAgent) Think() (*ModeratorDecision, error) {
+func (m *ModeratorAgent) Think(ctx context.Context) (*ModeratorDecision, error) {
 	// 构建主持人的思考 Prompt
 	systemPrompt := m.buildModeratorPrompt()
 
@@ -83,23 +86,21 @@ func (m *ModeratorAgent) Think() (*ModeratorDecision, error) {
 		{Role: "user", Content: stateDesc},
 	}
 
-	// 调用模型进行决策
-	response, _, err := m.model.Invoke(messages, nil)
-	if err != nil {
+	// 调用模型进行决策（JSON 结构化输出）
+	output := &moderatorOutput{members: m.members}
+	ctx = config.WithCacheable(config.WithUsageScope(ctx, config.ScopeModerator))
+	if err := config.InvokeStructured(ctx, m.model, messages, moderatorDecisionSchema, output); err != nil {
 		return nil, fmt.Errorf("主持人思考失败: %w", err)
 	}
 
-	// 解析决策
-	decision := m.parseDecision(response)
-
-	return decision, nil
+	return m.toDecision(output), nil
 }
 
 // buildModeratorPrompt 构建主持人 Prompt
 func (m *ModeratorAgent) buildModeratorPrompt() string {
 	memberNames := []string{}
-	for _, member := range m.members {
-		memberNames = append(memberNames, member.Name)
+	for _, pType := range m.memberTypes() {
+		memberNames = append(memberNames, m.members[pType].Name)
 	}
 
 	return fmt.Sprintf(`你是一个讨论会的主持人，负责引导 MyGO!!!!! 乐队成员进行话题讨论。
@@ -120,14 +121,16 @@ func (m *ModeratorAgent) buildModeratorPrompt() string {
 3. closing（总结）：每个成员做最后总结
 
 【决策格式】
-请用以下格式输出你的决策：
-ACTION: [动作类型]
-SPEAKER: [发言者代号]
-TARGET: [目标成员代号，如果有的话]
-INSTRUCTION: [给发言者的具体指令]
-REASON: [你做出这个决策的理由]
-SHOULD_END: [true/false]
-PHASE: [当前阶段]
+请只输出一个 JSON 对象，不要包含任何其他文字：
+{
+  "action": "动作类型",
+  "speaker": "发言者代号（结束讨论时可为空）",
+  "target": "目标成员代号，没有则为空字符串",
+  "instruction": "给发言者的具体指令",
+  "reason": "你做出这个决策的理由",
+  "should_end": false,
+  "phase": "当前阶段（opening / questioning / closing）"
+}
 
 【动作类型】
 - opening_speech: 开场发言
@@ -146,6 +149,16 @@ PHASE: [当前阶段]
 - taki: 椎名立希`, strings.Join(memberNames, "、"))
 }
 
+// memberTypes 按代号排序的成员列表，保证 Prompt 稳定（响应缓存与 cassette 按 Prompt 计算 Key）
+func (m *ModeratorAgent) memberTypes() []PhilosopherType {
+	types := make([]PhilosopherType, 0, len(m.members))
+	for pType := range m.members {
+		types = append(types, pType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
 // buildStateDescription 构建当前状态描述
 func (m *ModeratorAgent) buildStateDescription() string {
 	var sb strings.Builder
@@ -176,7 +189,8 @@ func (m *ModeratorAgent) buildStateDescription() string {
 
 	// 开场发言情况
 	sb.WriteString("\n【开场发言完成情况】\n")
-	for pType, member := range m.members {
+	for _, pType := range m.memberTypes() {
+		member := m.members[pType]
 		if _, ok := m.context.OpeningStatements[pType]; ok {
 			sb.WriteString(fmt.Sprintf("✓ %s 已完成开场\n", member.Name))
 		} else {
@@ -187,7 +201,8 @@ func (m *ModeratorAgent) buildStateDescription() string {
 	// 总结发言情况
 	if m.context.CurrentPhase == PhaseClosing {
 		sb.WriteString("\n【总结发言完成情况】\n")
-		for pType, member := range m.members {
+		for _, pType := range m.memberTypes() {
+			member := m.members[pType]
 			if _, ok := m.context.ClosingStatements[pType]; ok {
 				sb.WriteString(fmt.Sprintf("✓ %s 已完成总结\n", member.Name))
 			} else {
@@ -201,42 +216,101 @@ func (m *ModeratorAgent) buildStateDescription() string {
 	return sb.String()
 }
 
-// parseDecision 解析主持人的决策
-func (m *ModeratorAgent) parseDecision(response string) *ModeratorDecision {
-	decision := &ModeratorDecision{
-		Phase: m.context.CurrentPhase,
-	}
+// moderatorDecisionSchema 主持人决策的 JSON Schema
+var moderatorDecisionSchema = &config.JSONSchema{
+	Name:   "moderator_decision",
+	Strict: true,
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type": "string",
+				"enum": []string{
+					string(ActionOpeningSpeech), string(ActionAskQuestion), string(ActionRequestAnswer),
+					string(ActionInviteComment), string(ActionFreeDiscussion), string(ActionRequestSummary),
+					string(ActionEndDiscussion),
+				},
+			},
+			"speaker":     map[string]interface{}{"type": "string"},
+			"target":      map[string]interface{}{"type": "string"},
+			"instruction": map[string]interface{}{"type": "string"},
+			"reason":      map[string]interface{}{"type": "string"},
+			"should_end":  map[string]interface{}{"type": "boolean"},
+			"phase": map[string]interface{}{
+				"type": "string",
+				"enum": []string{string(PhaseOpening), string(PhaseQuestioning), string(PhaseFreeDebate), string(PhaseClosing)},
+			},
+		},
+		"required":             []string{"action", "speaker", "target", "instruction", "reason", "should_end", "phase"},
+		"additionalProperties": false,
+	},
+}
 
-	lines := strings.Split(response, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "ACTION:") {
-			action := strings.TrimSpace(strings.TrimPrefix(line, "ACTION:"))
-			decision.Action = ModeratorAction(action)
-		} else if strings.HasPrefix(line, "SPEAKER:") {
-			speaker := strings.TrimSpace(strings.TrimPrefix(line, "SPEAKER:"))
-			decision.NextSpeaker = PhilosopherType(speaker)
-		} else if strings.HasPrefix(line, "TARGET:") {
-			target := strings.TrimSpace(strings.TrimPrefix(line, "TARGET:"))
-			if target != "" && target != "none" && target != "无" {
-				decision.TargetMember = PhilosopherType(target)
-			}
-		} else if strings.HasPrefix(line, "INSTRUCTION:") {
-			decision.Instruction = strings.TrimSpace(strings.TrimPrefix(line, "INSTRUCTION:"))
-		} else if strings.HasPrefix(line, "REASON:") {
-			decision.Reason = strings.TrimSpace(strings.TrimPrefix(line, "REASON:"))
-		} else if strings.HasPrefix(line, "SHOULD_END:") {
-			shouldEnd := strings.TrimSpace(strings.TrimPrefix(line, "SHOULD_END:"))
-			decision.ShouldEnd = shouldEnd == "true"
-		} else if strings.HasPrefix(line, "PHASE:") {
-			phase := strings.TrimSpace(strings.TrimPrefix(line, "PHASE:"))
-			decision.Phase = DebatePhase(phase)
+// moderatorOutput 主持人决策的结构化输出
+type moderatorOutput struct {
+	Action      ModeratorAction `json:"action"`
+	Speaker     PhilosopherType `json:"speaker"`
+	Target      string          `json:"target"`
+	Instruction string          `json:"instruction"`
+	Reason      string          `json:"reason"`
+	ShouldEnd   bool            `json:"should_end"`
+	Phase       DebatePhase     `json:"phase"`
+
+	members map[PhilosopherType]*Philosopher // 用于校验发言者
+}
+
+// Validate 校验动作、阶段与发言者
+func (o *moderatorOutput) Validate() error {
+	switch o.Action {
+	case ActionOpeningSpeech, ActionAskQuestion, ActionRequestAnswer, ActionInviteComment,
+		ActionFreeDiscussion, ActionRequestSummary, ActionEndDiscussion:
+	default:
+		return fmt.Errorf("unknown action %q", o.Action)
+	}
+	switch o.Phase {
+	case "", PhaseOpening, PhaseQuestioning, PhaseFreeDebate, PhaseClosing:
+	default:
+		return fmt.Errorf("unknown phase %q", o.Phase)
+	}
+	if o.ShouldEnd || o.Action == ActionEndDiscussion {
+		return nil
+	}
+	if _, ok := o.members[o.Speaker]; !ok {
+		return fmt.Errorf("speaker %q is not a participant", o.Speaker)
+	}
+	if target := o.target(); target != "" {
+		if _, ok := o.members[target]; !ok {
+			return fmt.Errorf("target %q is not a participant", target)
 		}
 	}
+	return nil
+}
+
+// target 目标成员，"none" / "无" 视为没有
+func (o *moderatorOutput) target() PhilosopherType {
+	switch t := strings.TrimSpace(o.Target); t {
+	case "", "none", "无":
+		return ""
+	default:
+		return PhilosopherType(t)
+	}
+}
+
+// toDecision 把结构化输出转换为主持人决策
+func (m *ModeratorAgent) toDecision(output *moderatorOutput) *ModeratorDecision {
+	decision := &ModeratorDecision{
+		Action:       output.Action,
+		NextSpeaker:  output.Speaker,
+		TargetMember: output.target(),
+		Instruction:  output.Instruction,
+		Reason:       output.Reason,
+		ShouldEnd:    output.ShouldEnd,
+		Phase:        output.Phase,
+	}
 
 	// 默认值处理
-	if decision.Action == "" {
-		decision.Action = ActionFreeDiscussion
+	if decision.Phase == "" {
+		decision.Phase = m.context.CurrentPhase
 	}
 	if decision.Instruction == "" {
 		decision.Instruction = "请分享你的想法"
@@ -246,7 +320,7 @@ func (m *ModeratorAgent) parseDecision(response string) *ModeratorDecision {
 }
 
 // Execute 执行决策，让对应成员发言
-func (m *ModeratorAgent) Execute(decision *ModeratorDecision) (*DebateRecord, error) {
+func (m *ModeratorAgent) Execute(ctx context.Context, decision *ModeratorDecision) (*DebateRecord, error) {
 	// 检查是否结束
 	if decision.ShouldEnd || decision.Action == ActionEndDiscussion {
 		return nil, nil
@@ -265,7 +339,7 @@ func (m *ModeratorAgent) Execute(decision *ModeratorDecision) (*DebateRecord, er
 	task := m.buildTask(decision)
 
 	// 让成员发言
-	content, err := speaker.Debate(m.context, task)
+	content, err := speaker.Debate(ctx, m.context, task)
 	if err != nil {
 		return nil, fmt.Errorf("%s 发言失败: %w", speaker.Name, err)
 	}
@@ -333,15 +407,24 @@ func (m *ModeratorAgent) buildTask(decision *ModeratorDecision) DebateTask {
 }
 
 // RunAutonomous 自主运行完整讨论
-func (m *ModeratorAgent) RunAutonomous(onSpeech func(speaker string, content string, phase DebatePhase)) (*DebateResult, error) {
+// ctx 被取消时在当前发言结束前中止，返回 ctx.Err()
+func (m *ModeratorAgent) RunAutonomous(ctx context.Context, onSpeech func(speaker string, content string, phase DebatePhase)) (_ *DebateResult, err error) {
+	ctx, span := tracing.Start(ctx, "discussion")
+	span.SetText("topic", m.context.Topic)
+	defer func() { span.End(err) }()
+
 	result := &DebateResult{
 		Topic:   m.context.Topic,
 		Records: []DebateRecord{},
 	}
 
 	for m.roundCount < m.maxRounds {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		// 主持人思考
-		decision, err := m.Think()
+		decision, err := m.Think(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -357,7 +440,7 @@ func (m *ModeratorAgent) RunAutonomous(onSpeech func(speaker string, content str
 		}
 
 		// 执行决策
-		record, err := m.Execute(decision)
+		record, err := m.Execute(ctx, decision)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,87 @@
+package philosopher
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"agent/config/replaytest"
+)
+
+func TestModeratorRunAutonomous(t *testing.T) {
+	tests := []struct {
+		name        string
+		topic       string
+		maxRounds   int
+		wantActions []ModeratorAction
+		want        []wantRecord
+	}{
+		{
+			name:  "scripted discussion",
+			topic: "乐队应该优先考虑技术还是感情",
+			wantActions: []ModeratorAction{
+				ActionOpeningSpeech, ActionOpeningSpeech, ActionAskQuestion,
+				ActionRequestAnswer, ActionRequestSummary, ActionEndDiscussion,
+			},
+			want: []wantRecord{
+				{TakamatsuTomori, PhaseOpening, TaskOpening, "", replyOpening},
+				{NagasakiSoyo, PhaseOpening, TaskOpening, "", replyOpening},
+				{NagasakiSoyo, PhaseQuestioning, TaskQuestion, TakamatsuTomori, replyQuestion},
+				{TakamatsuTomori, PhaseQuestioning, TaskAnswer, NagasakiSoyo, replyAnswer},
+				{TakamatsuTomori, PhaseClosing, TaskClosing, "", replyClosing},
+			},
+		},
+		{
+			name:        "stops at max rounds",
+			topic:       "乐队应该优先考虑技术还是感情",
+			maxRounds:   2,
+			wantActions: []ModeratorAction{ActionOpeningSpeech, ActionOpeningSpeech},
+			want: []wantRecord{
+				{TakamatsuTomori, PhaseOpening, TaskOpening, "", replyOpening},
+				{NagasakiSoyo, PhaseOpening, TaskOpening, "", replyOpening},
+			},
+		},
+		{
+			name:        "invalid decision is repaired",
+			topic:       "修复测试：乐队练习要不要定时间",
+			wantActions: []ModeratorAction{ActionOpeningSpeech, ActionEndDiscussion},
+			want: []wantRecord{
+				{TakamatsuTomori, PhaseOpening, TaskOpening, "", replyOpening},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := replaytest.Model(t, filepath.Join("testdata", "cassettes"))
+			members := map[PhilosopherType]*Philosopher{
+				TakamatsuTomori: NewPhilosopher(TakamatsuTomori, model),
+				NagasakiSoyo:    NewPhilosopher(NagasakiSoyo, model),
+			}
+			moderator := NewModeratorAgent(model, tt.topic, members)
+			if tt.maxRounds > 0 {
+				moderator.SetMaxRounds(tt.maxRounds)
+			}
+			var actions []ModeratorAction
+			moderator.SetOnDecision(func(d *ModeratorDecision) { actions = append(actions, d.Action) })
+			var speeches int
+
+			result, err := moderator.RunAutonomous(context.Background(), func(string, string, DebatePhase) { speeches++ })
+			if err != nil {
+				t.Fatalf("RunAutonomous: %v", err)
+			}
+			checkRecords(t, result.Records, tt.want)
+			if len(actions) != len(tt.wantActions) {
+				t.Fatalf("decisions = %v, want %v", actions, tt.wantActions)
+			}
+			for i := range actions {
+				if actions[i] != tt.wantActions[i] {
+					t.Errorf("decision %d = %s, want %s", i, actions[i], tt.wantActions[i])
+				}
+			}
+			if speeches != len(tt.want) {
+				t.Errorf("onSpeech called %d times, want %d", speeches, len(tt.want))
+			}
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package philosopher
 
 import (
+	"context"
+	"strconv"
+
 	"agent/config"
+	"agent/tracing"
 )
 
 // Philosopher 哲学家 Agent
@@ -9,13 +13,13 @@ type Philosopher struct {
 	Type          PhilosopherType
 	Name          string
 	Prompt        *PhilosopherPrompt
-	Model         *config.ChatModel
+	Model         config.Provider
 	CurrentStance string // 当前立场（正方/反方）
 	IsForced      bool   // 是否被强制指定立场
 }
 
 // NewPhilosopher 创建哲学家
-func NewPhilosopher(pType PhilosopherType, model *config.ChatModel) *Philosopher {
+func NewPhilosopher(pType PhilosopherType, model config.Provider) *Philosopher {
 	prompts := GetPhilosopherPrompts()
 	prompt := prompts[pType]
 
@@ -28,7 +32,24 @@ func NewPhilosopher(pType PhilosopherType, model *config.ChatModel) *Philosopher
 }
 
 // Chat 与哲学家进行一对一对话
-func (p *Philosopher) Chat(messages []config.Message, emotionLevel EmotionLevel) (string, error) {
+func (p *Philosopher) Chat(ctx context.Context, messages []config.Message, emotionLevel EmotionLevel) (string, error) {
+	// 调用模型
+	content, _, err := p.Model.Invoke(config.WithUsageScope(ctx, config.ScopeChat), p.buildChatMessages(messages, emotionLevel), nil)
+	return content, err
+}
+
+// ChatStream 与哲学家进行一对一对话（流式），每生成一段文本就回调 onToken
+func (p *Philosopher) ChatStream(ctx context.Context, messages []config.Message, emotionLevel EmotionLevel, onToken func(token string)) (string, error) {
+	content, _, err := config.InvokeStream(config.WithUsageScope(ctx, config.ScopeChat), p.Model, p.buildChatMessages(messages, emotionLevel), nil, func(chunk config.StreamChunk) {
+		if onToken != nil && chunk.Content != "" {
+			onToken(chunk.Content)
+		}
+	})
+	return content, err
+}
+
+// buildChatMessages 构建一对一对话的完整消息（情绪感知 system + 历史）
+func (p *Philosopher) buildChatMessages(messages []config.Message, emotionLevel EmotionLevel) []config.Message {
 	// 根据情绪级别调整 Prompt
 	systemPrompt := p.buildEmotionAwarePrompt(emotionLevel)
 
@@ -36,11 +57,7 @@ func (p *Philosopher) Chat(messages []config.Message, emotionLevel EmotionLevel)
 	fullMessages := []config.Message{
 		{Role: "system", Content: systemPrompt},
 	}
-	fullMessages = append(fullMessages, messages...)
-
-	// 调用模型
-	content, _, err := p.Model.Invoke(fullMessages, nil)
-	return content, err
+	return append(fullMessages, messages...)
 }
 
 // buildEmotionAwarePrompt 根据情绪级别构建 Prompt
@@ -84,13 +101,18 @@ func (p *Philosopher) buildEmotionAwarePrompt(level EmotionLevel) string {
 }
 
 // Debate 在辩论中发言
-func (p *Philosopher) Debate(context *DebateContext, task DebateTask) (string, error) {
+func (p *Philosopher) Debate(ctx context.Context, dc *DebateContext, task DebateTask) (string, error) {
 	// 构建辩论 Prompt
 	var systemPrompt string
 	if p.IsForced {
-		systemPrompt = p.Prompt.BuildForcedStancePrompt(context.Topic, p.CurrentStance)
+		systemPrompt = p.Prompt.BuildForcedStancePrompt(dc.Topic, p.CurrentStance)
 	} else {
-		systemPrompt = p.Prompt.BuildDebatePrompt(context.Topic, p.CurrentStance, string(context.CurrentPhase))
+		systemPrompt = p.Prompt.BuildDebatePrompt(dc.Topic, p.CurrentStance, string(dc.CurrentPhase))
+	}
+
+	// 预算紧张时缩短发言
+	if config.BudgetLevelFrom(ctx) >= config.BudgetDegraded {
+		task.WordLimit = task.wordLimit() / 2
 	}
 
 	// 添加任务指令
@@ -102,7 +124,7 @@ func (p *Philosopher) Debate(context *DebateContext, task DebateTask) (string, e
 	}
 
 	// 添加相关的辩论历史
-	relevantHistory := context.GetRelevantHistory(p.Type, task.Type)
+	relevantHistory := dc.GetRelevantHistory(p.Type, task.Type)
 	for _, h := range relevantHistory {
 		messages = append(messages, config.Message{
 			Role:    "user",
@@ -110,14 +132,28 @@ func (p *Philosopher) Debate(context *DebateContext, task DebateTask) (string, e
 		})
 	}
 
+	// 主持人插入的提示
+	if task.ModeratorNote != "" {
+		messages = append(messages, config.Message{
+			Role:    "user",
+			Content: "[主持人] " + task.ModeratorNote,
+		})
+	}
+
 	// 添加当前任务
 	messages = append(messages, config.Message{
 		Role:    "user",
 		Content: task.Instruction,
 	})
 
-	// 调用模型
-	content, _, err := p.Model.Invoke(messages, nil)
+	// 调用模型（用量记在该发言者名下）
+	ctx = config.WithUsageSpeaker(config.WithUsageScope(ctx, config.ScopeDebate), string(p.Type))
+	ctx, span := tracing.Start(ctx, "debate.speech")
+	span.SetAttr("speaker", string(p.Type))
+	span.SetAttr("task", string(task.Type))
+	span.SetAttr("phase", string(dc.CurrentPhase))
+	content, _, err := p.Model.Invoke(ctx, messages, nil)
+	span.End(err)
 	return content, err
 }
 
@@ -126,6 +162,9 @@ type DebateTask struct {
 	Type        DebateTaskType
 	Instruction string
 	TargetName  string // 质询对象（如果有）
+	WordLimit   int    // 字数上限，0 表示使用该任务类型的默认值
+
+	ModeratorNote string // 主持人插入的提示（如"请围绕 X 展开"），没有时为空
 }
 
 // DebateTaskType 辩论任务类型
@@ -142,6 +181,8 @@ const (
 
 // BuildTaskPrompt 构建任务 Prompt
 func (t *DebateTask) BuildTaskPrompt() string {
+	limit := strconv.Itoa(t.wordLimit())
+
 	switch t.Type {
 	case TaskOpening:
 		return `【当前任务：开场发言】
@@ -150,7 +191,7 @@ func (t *DebateTask) BuildTaskPrompt() string {
 1. 用你自己的方式表达立场
 2. 说出你真实的感受
 3. 保持你的性格特点
-4. 控制在300字以内`
+4. 控制在` + limit + `字以内`
 
 	case TaskQuestion:
 		return `【当前任务：提问】
@@ -159,7 +200,7 @@ func (t *DebateTask) BuildTaskPrompt() string {
 1. 用你的方式提出问题
 2. 可以是好奇，也可以是质疑
 3. 保持你的性格
-4. 控制在150字以内`
+4. 控制在` + limit + `字以内`
 
 	case TaskAnswer:
 		return `【当前任务：回应】
@@ -168,7 +209,7 @@ func (t *DebateTask) BuildTaskPrompt() string {
 1. 认真回答问题
 2. 用你的方式表达
 3. 可以分享你的感受
-4. 控制在200字以内`
+4. 控制在` + limit + `字以内`
 
 	case TaskRebuttal:
 		return `【当前任务：回应】
@@ -177,7 +218,7 @@ func (t *DebateTask) BuildTaskPrompt() string {
 1. 表达你的看法
 2. 可以同意也可以不同意
 3. 保持你的性格
-4. 控制在200字以内`
+4. 控制在` + limit + `字以内`
 
 	case TaskFreeDebate:
 		return `【当前任务：自由讨论】
@@ -185,7 +226,7 @@ func (t *DebateTask) BuildTaskPrompt() string {
 1. 回应刚才的发言
 2. 补充新的想法
 3. 分享你的感受
-要求：保持你的风格，控制在200字以内`
+要求：保持你的风格，控制在` + limit + `字以内`
 
 	case TaskClosing:
 		return `【当前任务：总结发言】
@@ -194,9 +235,24 @@ func (t *DebateTask) BuildTaskPrompt() string {
 1. 总结你的想法
 2. 表达你的感受
 3. 用你的方式收尾
-4. 控制在300字以内`
+4. 控制在` + limit + `字以内`
 
 	default:
 		return t.Instruction
 	}
 }
+
+// wordLimit 字数上限：未指定时按任务类型取默认值
+func (t *DebateTask) wordLimit() int {
+	if t.WordLimit > 0 {
+		return t.WordLimit
+	}
+	switch t.Type {
+	case TaskOpening, TaskClosing:
+		return 300
+	case TaskQuestion:
+		return 150
+	default:
+		return 200
+	}
+}
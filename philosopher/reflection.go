@@ -1,6 +1,7 @@
 package philosopher
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -11,7 +12,7 @@ import (
 
 // ReflectionEngine 反思引擎
 type ReflectionEngine struct {
-	model *config.ChatModel
+	model config.Provider
 }
 
 // ReflectionResult 反思结果
@@ -32,30 +33,31 @@ type ReflectionIssue struct {
 }
 
 // NewReflectionEngine 创建反思引擎
-func NewReflectionEngine(model *config.ChatModel) *ReflectionEngine {
+func NewReflectionEngine(model config.Provider) *ReflectionEngine {
 	return &ReflectionEngine{model: model}
 }
 
 // Reflect 对回复进行反思
 func (r *ReflectionEngine) Reflect(
+	ctx context.Context,
 	response string,
 	philosopherType PhilosopherType,
-	context *AgentContext,
+	agentCtx *AgentContext,
 	userMessage string,
 ) (*ReflectionResult, error) {
-	prompt := r.buildReflectionPrompt(response, philosopherType, context, userMessage)
+	prompt := r.buildReflectionPrompt(response, philosopherType, agentCtx, userMessage)
 
 	messages := []config.Message{
 		{Role: "system", Content: prompt},
 		{Role: "user", Content: "请对以上回复进行反思和评估。"},
 	}
 
-	result, _, err := r.model.Invoke(messages, nil)
-	if err != nil {
+	output := &reflectionOutput{}
+	if err := config.InvokeStructured(ctx, r.model, messages, reflectionSchema, output); err != nil {
 		return nil, fmt.Errorf("反思失败: %w", err)
 	}
 
-	return r.parseReflectionResult(response, result), nil
+	return output.toResult(response), nil
 }
 
 // buildReflectionPrompt 构建反思 Prompt
@@ -90,18 +92,16 @@ func (r *ReflectionEngine) buildReflectionPrompt(
 5. 深度适当性：回复的深度是否合适（不过于肤浅也不过于复杂）？
 
 【输出格式】
-ACCEPTABLE: [true/false]
-CONFIDENCE: [0-1之间的数字]
-
-ISSUES:
-- [方面]: [问题描述] (严重程度: low/medium/high)
-
-SUGGESTIONS:
-- [改进建议1]
-- [改进建议2]
-
-REVISED_RESPONSE:
-[如果有问题，给出修改后的回复；如果没问题，输出"无需修改"]`,
+请只输出一个 JSON 对象，不要包含任何其他文字：
+{
+  "acceptable": true,
+  "confidence": 0.8,
+  "issues": [
+    {"aspect": "问题方面", "description": "问题描述", "severity": "low / medium / high"}
+  ],
+  "suggestions": ["改进建议"],
+  "revised_response": "如果有问题，给出修改后的回复；如果没问题，输出空字符串"
+}`,
 		characterPrompt.BuildFullPrompt(),
 		userMessage,
 		context.CurrentMood,
@@ -109,96 +109,97 @@ REVISED_RESPONSE:
 	)
 }
 
-// parseReflectionResult 解析反思结果
-func (r *ReflectionEngine) parseReflectionResult(originalResponse, result string) *ReflectionResult {
+// reflectionSchema 反思结果的 JSON Schema
+var reflectionSchema = &config.JSONSchema{
+	Name:   "reflection_result",
+	Strict: true,
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"acceptable": map[string]interface{}{"type": "boolean"},
+			"confidence": map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+			"issues": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"aspect":      map[string]interface{}{"type": "string"},
+						"description": map[string]interface{}{"type": "string"},
+						"severity":    map[string]interface{}{"type": "string", "enum": []string{"low", "medium", "high"}},
+					},
+					"required":             []string{"aspect", "description", "severity"},
+					"additionalProperties": false,
+				},
+			},
+			"suggestions":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"revised_response": map[string]interface{}{"type": "string"},
+		},
+		"required":             []string{"acceptable", "confidence", "issues", "suggestions", "revised_response"},
+		"additionalProperties": false,
+	},
+}
+
+// reflectionOutput 反思的结构化输出
+type reflectionOutput struct {
+	Acceptable      bool              `json:"acceptable"`
+	Confidence      float64           `json:"confidence"`
+	Issues          []ReflectionIssue `json:"issues"`
+	Suggestions     []string          `json:"suggestions"`
+	RevisedResponse string            `json:"revised_response"`
+}
+
+// Validate 校验置信度范围与问题的严重程度
+func (o *reflectionOutput) Validate() error {
+	if o.Confidence < 0 || o.Confidence > 1 {
+		return fmt.Errorf("confidence must be between 0 and 1, got %v", o.Confidence)
+	}
+	for i, issue := range o.Issues {
+		switch issue.Severity {
+		case "low", "medium", "high":
+		default:
+			return fmt.Errorf("issues[%d].severity must be low, medium or high, got %q", i, issue.Severity)
+		}
+	}
+	if !o.Acceptable && strings.TrimSpace(o.RevisedResponse) == "" {
+		return fmt.Errorf("revised_response is required when acceptable is false")
+	}
+	return nil
+}
+
+// toResult 把结构化输出转换为反思结果
+func (o *reflectionOutput) toResult(originalResponse string) *ReflectionResult {
 	reflection := &ReflectionResult{
 		OriginalResponse: originalResponse,
-		IsAcceptable:     true,
-		ConfidenceScore:  0.8,
-		Issues:           []ReflectionIssue{},
-		Suggestions:      []string{},
+		IsAcceptable:     o.Acceptable,
+		ConfidenceScore:  o.Confidence,
+		Issues:           o.Issues,
+		Suggestions:      o.Suggestions,
+		RevisedResponse:  strings.TrimSpace(o.RevisedResponse),
 	}
-
-	lines := strings.Split(result, "\n")
-	currentSection := ""
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		if strings.HasPrefix(line, "ACCEPTABLE:") {
-			value := strings.TrimSpace(strings.TrimPrefix(line, "ACCEPTABLE:"))
-			reflection.IsAcceptable = value == "true"
-		} else if strings.HasPrefix(line, "CONFIDENCE:") {
-			value := strings.TrimSpace(strings.TrimPrefix(line, "CONFIDENCE:"))
-			fmt.Sscanf(value, "%f", &reflection.ConfidenceScore)
-		} else if strings.HasPrefix(line, "ISSUES:") {
-			currentSection = "issues"
-		} else if strings.HasPrefix(line, "SUGGESTIONS:") {
-			currentSection = "suggestions"
-		} else if strings.HasPrefix(line, "REVISED_RESPONSE:") {
-			currentSection = "revised"
-		} else if strings.HasPrefix(line, "- ") {
-			content := strings.TrimPrefix(line, "- ")
-			switch currentSection {
-			case "issues":
-				issue := parseIssue(content)
-				if issue.Aspect != "" {
-					reflection.Issues = append(reflection.Issues, issue)
-				}
-			case "suggestions":
-				reflection.Suggestions = append(reflection.Suggestions, content)
-			}
-		} else if currentSection == "revised" && line != "" && line != "无需修改" {
-			if reflection.RevisedResponse == "" {
-				reflection.RevisedResponse = line
-			} else {
-				reflection.RevisedResponse += "\n" + line
-			}
-		}
+	if reflection.Issues == nil {
+		reflection.Issues = []ReflectionIssue{}
+	}
+	if reflection.Suggestions == nil {
+		reflection.Suggestions = []string{}
 	}
 
 	// 如果没有修改建议，使用原始回复
-	if reflection.RevisedResponse == "" {
+	if reflection.RevisedResponse == "" || reflection.RevisedResponse == "无需修改" {
 		reflection.RevisedResponse = originalResponse
 	}
 
 	return reflection
 }
 
-// parseIssue 解析问题
-func parseIssue(content string) ReflectionIssue {
-	issue := ReflectionIssue{Severity: "low"}
-
-	// 格式: [方面]: [描述] (严重程度: xxx)
-	if idx := strings.Index(content, ":"); idx > 0 {
-		issue.Aspect = strings.TrimSpace(content[:idx])
-		rest := strings.TrimSpace(content[idx+1:])
-
-		// 提取严重程度
-		if sevIdx := strings.Index(rest, "(严重程度:"); sevIdx > 0 {
-			issue.Description = strings.TrimSpace(rest[:sevIdx])
-			sevPart := rest[sevIdx:]
-			if strings.Contains(sevPart, "high") {
-				issue.Severity = "high"
-			} else if strings.Contains(sevPart, "medium") {
-				issue.Severity = "medium"
-			}
-		} else {
-			issue.Description = rest
-		}
-	}
-
-	return issue
-}
-
 // ReflectAndRefine 反思并优化回复（一体化方法）
 func (r *ReflectionEngine) ReflectAndRefine(
+	ctx context.Context,
 	response string,
 	philosopherType PhilosopherType,
-	context *AgentContext,
+	agentCtx *AgentContext,
 	userMessage string,
 ) (string, *ReflectionResult, error) {
-	result, err := r.Reflect(response, philosopherType, context, userMessage)
+	result, err := r.Reflect(ctx, response, philosopherType, agentCtx, userMessage)
 	if err != nil {
 		return response, nil, err
 	}
@@ -216,7 +217,7 @@ func (r *ReflectionEngine) ReflectAndRefine(
 
 // SelfEvaluator 自我评估器
 type SelfEvaluator struct {
-	model *config.ChatModel
+	model config.Provider
 }
 
 // EvaluationCriteria 评估标准
@@ -241,7 +242,7 @@ type SelfEvaluationResult struct {
 }
 
 // NewSelfEvaluator 创建自我评估器
-func NewSelfEvaluator(model *config.ChatModel) *SelfEvaluator {
+func NewSelfEvaluator(model config.Provider) *SelfEvaluator {
 	return &SelfEvaluator{model: model}
 }
 
@@ -258,6 +259,7 @@ func GetDefaultCriteria() []EvaluationCriteria {
 
 // Evaluate 进行自我评估
 func (e *SelfEvaluator) Evaluate(
+	ctx context.Context,
 	response string,
 	philosopherType PhilosopherType,
 	userMessage string,
@@ -274,12 +276,12 @@ func (e *SelfEvaluator) Evaluate(
 		{Role: "user", Content: "请对回复进行评分。"},
 	}
 
-	result, _, err := e.model.Invoke(messages, nil)
-	if err != nil {
+	output := &evaluationOutput{criteria: len(criteria)}
+	if err := config.InvokeStructured(config.WithCacheable(ctx), e.model, messages, evaluationSchema, output); err != nil {
 		return nil, fmt.Errorf("自我评估失败: %w", err)
 	}
 
-	return e.parseEvaluationResult(result, criteria), nil
+	return output.toResult(criteria), nil
 }
 
 // buildEvaluationPrompt 构建评估 Prompt
@@ -313,61 +315,90 @@ func (e *SelfEvaluator) buildEvaluationPrompt(
 %s
 
 【输出格式】
-请为每个标准打分（0-10分），并给出具体反馈：
-
-SCORE_1: [分数]
-FEEDBACK_1: [反馈]
-
-SCORE_2: [分数]
-FEEDBACK_2: [反馈]
-
-... 以此类推 ...
-
-SUMMARY: [整体评价总结]`,
+请按评估标准的顺序为每个标准打分（0-10分），并给出具体反馈。只输出一个 JSON 对象，不要包含任何其他文字：
+{
+  "scores": [
+    {"score": 8, "feedback": "对第 1 个标准的反馈"},
+    {"score": 7, "feedback": "对第 2 个标准的反馈"}
+  ],
+  "summary": "整体评价总结"
+}
+scores 的数量必须与评估标准的数量（%d）一致。`,
 		characterPrompt.Name,
 		userMessage,
 		response,
 		criteriaDesc.String(),
+		len(criteria),
 	)
 }
 
-// parseEvaluationResult 解析评估结果
-func (e *SelfEvaluator) parseEvaluationResult(result string, criteria []EvaluationCriteria) *SelfEvaluationResult {
-	evaluation := &SelfEvaluationResult{
-		Scores: make([]EvaluationScore, len(criteria)),
-	}
-
-	lines := strings.Split(result, "\n")
+// evaluationSchema 自我评估结果的 JSON Schema
+var evaluationSchema = &config.JSONSchema{
+	Name:   "self_evaluation",
+	Strict: true,
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"scores": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"score":    map[string]interface{}{"type": "number", "minimum": 0, "maximum": 10},
+						"feedback": map[string]interface{}{"type": "string"},
+					},
+					"required":             []string{"score", "feedback"},
+					"additionalProperties": false,
+				},
+			},
+			"summary": map[string]interface{}{"type": "string"},
+		},
+		"required":             []string{"scores", "summary"},
+		"additionalProperties": false,
+	},
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+// evaluationOutput 自我评估的结构化输出
+type evaluationOutput struct {
+	Scores []struct {
+		Score    float64 `json:"score"`
+		Feedback string  `json:"feedback"`
+	} `json:"scores"`
+	Summary string `json:"summary"`
 
-		for i := range criteria {
-			scoreKey := fmt.Sprintf("SCORE_%d:", i+1)
-			feedbackKey := fmt.Sprintf("FEEDBACK_%d:", i+1)
+	criteria int // 评估标准的数量，用于校验
+}
 
-			if strings.HasPrefix(line, scoreKey) {
-				value := strings.TrimSpace(strings.TrimPrefix(line, scoreKey))
-				fmt.Sscanf(value, "%f", &evaluation.Scores[i].Score)
-				evaluation.Scores[i].Criteria = criteria[i].Name
-			} else if strings.HasPrefix(line, feedbackKey) {
-				evaluation.Scores[i].Feedback = strings.TrimSpace(strings.TrimPrefix(line, feedbackKey))
-			}
+// Validate 校验分数个数与范围
+func (o *evaluationOutput) Validate() error {
+	if len(o.Scores) != o.criteria {
+		return fmt.Errorf("expected %d scores, got %d", o.criteria, len(o.Scores))
+	}
+	for i, s := range o.Scores {
+		if s.Score < 0 || s.Score > 10 {
+			return fmt.Errorf("scores[%d].score must be between 0 and 10, got %v", i, s.Score)
 		}
+	}
+	return nil
+}
 
-		if strings.HasPrefix(line, "SUMMARY:") {
-			evaluation.Summary = strings.TrimSpace(strings.TrimPrefix(line, "SUMMARY:"))
-		}
+// toResult 把结构化输出转换为评估结果并计算加权总分
+func (o *evaluationOutput) toResult(criteria []EvaluationCriteria) *SelfEvaluationResult {
+	evaluation := &SelfEvaluationResult{
+		Scores:  make([]EvaluationScore, len(criteria)),
+		Summary: o.Summary,
 	}
 
-	// 计算加权总分
 	totalWeight := 0.0
 	weightedSum := 0.0
-	for i, score := range evaluation.Scores {
-		if i < len(criteria) {
-			weightedSum += score.Score * criteria[i].Weight
-			totalWeight += criteria[i].Weight
+	for i, c := range criteria {
+		evaluation.Scores[i] = EvaluationScore{
+			Criteria: c.Name,
+			Score:    o.Scores[i].Score,
+			Feedback: o.Scores[i].Feedback,
 		}
+		weightedSum += o.Scores[i].Score * c.Weight
+		totalWeight += c.Weight
 	}
 	if totalWeight > 0 {
 		evaluation.TotalScore = weightedSum / totalWeight
@@ -380,7 +411,7 @@ func (e *SelfEvaluator) parseEvaluationResult(result string, criteria []Evaluati
 
 // IterativeRefiner 迭代优化器
 type IterativeRefiner struct {
-	model            *config.ChatModel
+	model            config.Provider
 	reflectionEngine *ReflectionEngine
 	evaluator        *SelfEvaluator
 	maxIterations    int
@@ -388,7 +419,7 @@ type IterativeRefiner struct {
 }
 
 // NewIterativeRefiner 创建迭代优化器
-func NewIterativeRefiner(model *config.ChatModel) *IterativeRefiner {
+func NewIterativeRefiner(model config.Provider) *IterativeRefiner {
 	return &IterativeRefiner{
 		model:            model,
 		reflectionEngine: NewReflectionEngine(model),
@@ -410,9 +441,10 @@ func (r *IterativeRefiner) SetTargetScore(score float64) {
 
 // RefineResponse 迭代优化回复
 func (r *IterativeRefiner) RefineResponse(
+	ctx context.Context,
 	initialResponse string,
 	philosopherType PhilosopherType,
-	context *AgentContext,
+	agentCtx *AgentContext,
 	userMessage string,
 ) (string, []SelfEvaluationResult, error) {
 	currentResponse := initialResponse
@@ -420,7 +452,7 @@ func (r *IterativeRefiner) RefineResponse(
 
 	for i := 0; i < r.maxIterations; i++ {
 		// 评估当前回复
-		eval, err := r.evaluator.Evaluate(currentResponse, philosopherType, userMessage, nil)
+		eval, err := r.evaluator.Evaluate(ctx, currentResponse, philosopherType, userMessage, nil)
 		if err != nil {
 			return currentResponse, evaluations, err
 		}
@@ -433,7 +465,7 @@ func (r *IterativeRefiner) RefineResponse(
 
 		// 反思并优化
 		refined, _, err := r.reflectionEngine.ReflectAndRefine(
-			currentResponse, philosopherType, context, userMessage)
+			ctx, currentResponse, philosopherType, agentCtx, userMessage)
 		if err != nil {
 			return currentResponse, evaluations, err
 		}
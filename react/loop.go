@@ -1,16 +1,30 @@
 package react
 
 import (
-	"agent/config"
+	"context"
 	"fmt"
+
+	"agent/config"
+	"agent/metrics"
+	"agent/tracing"
 )
 
 // Run 执行 ReAct 循环：Thought -> Action -> Observation，直到模型返回最终答案或达到最大步数
-func Run(input *RunInput) (*RunResult, error) {
+// ctx 被取消时在当前模型调用或工具调用结束后停止，返回 ctx.Err()
+func Run(ctx context.Context, input *RunInput) (result *RunResult, err error) {
 	if input.MaxSteps <= 0 {
 		input.MaxSteps = 10
 	}
 
+	ctx, span := tracing.Start(ctx, "react.run")
+	span.SetAttr("tools", len(input.Tools))
+	defer func() {
+		if result != nil {
+			span.SetAttr("steps", len(result.Steps))
+		}
+		span.End(err)
+	}()
+
 	// 若有 ReAct 说明，注入到第一条 system 消息末尾
 	messages := make([]config.Message, len(input.Messages))
 	copy(messages, input.Messages)
@@ -21,7 +35,11 @@ func Run(input *RunInput) (*RunResult, error) {
 	var steps []Step
 
 	for i := 0; i < input.MaxSteps; i++ {
-		content, toolCalls, err := input.Model.Invoke(messages, input.Tools)
+		stepCtx, stepSpan := tracing.Start(ctx, "react.step")
+		stepSpan.SetAttr("step", i+1)
+		content, toolCalls, err := invoke(stepCtx, input, messages, input.Tools)
+		stepSpan.SetAttr("tool_calls", len(toolCalls))
+		stepSpan.End(err)
 		if err != nil {
 			return nil, fmt.Errorf("react step %d invoke: %w", i+1, err)
 		}
@@ -31,24 +49,38 @@ func Run(input *RunInput) (*RunResult, error) {
 		// 无工具调用 -> 视为最终答案
 		if len(toolCalls) == 0 {
 			steps = append(steps, step)
+			input.emitStep(step)
+			metrics.ReActSteps.Observe(float64(len(steps)))
 			return &RunResult{FinalAnswer: content, Steps: steps}, nil
 		}
 
 		// 有工具调用 -> 记录 Action，执行并得到 Observation，并收集 tool_call_id -> observation
 		toolResults := make(map[string]string)
 		for _, tc := range toolCalls {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			name := tc.Function.Name
 			args := tc.Function.Arguments
+			_, toolSpan := tracing.Start(ctx, "tool."+name)
+			toolSpan.SetText("tool.input", args)
 			obs, err := input.Executor.Execute(name, args)
+			toolSpan.SetText("tool.observation", obs)
+			toolSpan.End(err)
 			if err != nil {
 				obs = fmt.Sprintf("执行失败: %s", err.Error())
+				metrics.ToolInvocations.Inc(name, "error")
+			} else {
+				metrics.ToolInvocations.Inc(name, "ok")
 			}
 			toolResults[tc.ID] = obs
-			steps = append(steps, Step{
+			step := Step{
 				Thought:     content,
 				Action:      &Action{ToolName: name, Input: args},
 				Observation: obs,
-			})
+			}
+			steps = append(steps, step)
+			input.emitStep(step)
 		}
 
 		// 将 assistant 消息（含 content + tool_calls）加入历史
@@ -86,13 +118,33 @@ func Run(input *RunInput) (*RunResult, error) {
 	finalMessages := make([]config.Message, len(messages))
 	copy(finalMessages, messages)
 	// 不传 tools，强制模型只输出文字
-	finalContent, _, err := input.Model.Invoke(finalMessages, nil)
+	finalContent, _, err := invoke(ctx, input, finalMessages, nil)
 	if err != nil {
 		return nil, fmt.Errorf("react final answer: %w", err)
 	}
+	metrics.ReActSteps.Observe(float64(len(steps)))
 	return &RunResult{FinalAnswer: finalContent, Steps: steps}, nil
 }
 
+// invoke 调用模型：设置了 OnToken 时走流式接口（模型不支持流式则一次性回调），否则走普通接口
+func invoke(ctx context.Context, input *RunInput, messages []config.Message, tools []map[string]interface{}) (string, []config.ToolCall, error) {
+	if input.OnToken != nil {
+		return config.InvokeStream(ctx, input.Model, messages, tools, func(chunk config.StreamChunk) {
+			if chunk.Content != "" {
+				input.OnToken(chunk.Content)
+			}
+		})
+	}
+	return input.Model.Invoke(ctx, messages, tools)
+}
+
+// emitStep 触发步骤回调
+func (input *RunInput) emitStep(step Step) {
+	if input.OnStep != nil {
+		input.OnStep(step)
+	}
+}
+
 // ToolExecutorFunc 将函数实现为 ToolExecutor
 type ToolExecutorFunc func(toolName string, argsJSON string) (string, error)
 
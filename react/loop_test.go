@@ -0,0 +1,147 @@
+package react
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"agent/config"
+	"agent/config/replaytest"
+)
+
+var testTools = []map[string]interface{}{
+	{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        "search_lyrics",
+			"description": "按心情查找歌词",
+			"parameters": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"mood": map[string]interface{}{"type": "string"}},
+			},
+		},
+	},
+	{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        "broken_tool",
+			"description": "总是失败的工具",
+			"parameters":  map[string]interface{}{"type": "object"},
+		},
+	},
+}
+
+func testExecutor(name, args string) (string, error) {
+	switch name {
+	case "search_lyrics":
+		return "迷子でもいい、迷子でも進め", nil
+	default:
+		return "", errors.New("tool unavailable")
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   string
+		maxSteps  int
+		stream    bool
+		wantSteps []Step
+		wantFinal string
+	}{
+		{
+			name:      "direct answer",
+			message:   "你好",
+			wantSteps: []Step{{Thought: "嗯...你好。"}},
+			wantFinal: "嗯...你好。",
+		},
+		{
+			name:    "tool call then answer",
+			message: "有没有适合现在的歌词",
+			wantSteps: []Step{
+				{Thought: "想找一句歌词。", Action: &Action{ToolName: "search_lyrics", Input: `{"mood":"迷茫"}`}, Observation: "迷子でもいい、迷子でも進め"},
+				{Thought: "迷子でもいい、迷子でも進め。"},
+			},
+			wantFinal: "迷子でもいい、迷子でも進め。",
+		},
+		{
+			name:    "tool error becomes observation",
+			message: "用那个坏掉的工具",
+			wantSteps: []Step{
+				{Thought: "试试这个工具。", Action: &Action{ToolName: "broken_tool", Input: `{}`}, Observation: "执行失败: tool unavailable"},
+				{Thought: "工具好像坏了，我直接说吧。"},
+			},
+			wantFinal: "工具好像坏了，我直接说吧。",
+		},
+		{
+			name:     "max steps forces text answer",
+			message:  "帮我一直查下去",
+			maxSteps: 1,
+			wantSteps: []Step{
+				{Thought: "再查一次。", Action: &Action{ToolName: "search_lyrics", Input: `{"mood":"孤独"}`}, Observation: "迷子でもいい、迷子でも進め"},
+			},
+			wantFinal: "查到这里就够了。",
+		},
+		{
+			name:      "streaming tokens",
+			message:   "你好",
+			stream:    true,
+			wantSteps: []Step{{Thought: "嗯...你好。"}},
+			wantFinal: "嗯...你好。",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var emitted []Step
+			var tokens strings.Builder
+			input := &RunInput{
+				Model:    replaytest.Model(t, filepath.Join("testdata", "cassettes")),
+				Executor: ToolExecutorFunc(testExecutor),
+				Tools:    testTools,
+				Messages: []config.Message{
+					{Role: "system", Content: "你是高松灯。"},
+					{Role: "user", Content: tt.message},
+				},
+				MaxSteps: tt.maxSteps,
+				OnStep:   func(step Step) { emitted = append(emitted, step) },
+			}
+			if tt.stream {
+				input.OnToken = func(token string) { tokens.WriteString(token) }
+			}
+
+			result, err := Run(context.Background(), input)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if result.FinalAnswer != tt.wantFinal {
+				t.Errorf("FinalAnswer = %q, want %q", result.FinalAnswer, tt.wantFinal)
+			}
+			if !reflect.DeepEqual(result.Steps, tt.wantSteps) {
+				t.Errorf("Steps = %+v, want %+v", result.Steps, tt.wantSteps)
+			}
+			if !reflect.DeepEqual(emitted, tt.wantSteps) {
+				t.Errorf("OnStep emitted %+v, want %+v", emitted, tt.wantSteps)
+			}
+			if tt.stream && tokens.String() != tt.wantFinal {
+				t.Errorf("streamed tokens = %q, want %q", tokens.String(), tt.wantFinal)
+			}
+		})
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Run(ctx, &RunInput{
+		Model:    replaytest.Model(t, filepath.Join("testdata", "cassettes")),
+		Executor: ToolExecutorFunc(testExecutor),
+		Messages: []config.Message{{Role: "user", Content: "你好"}},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
@@ -2,10 +2,11 @@ package react
 
 import "agent/config"
 
-// Model 模型接口：与 config.ChatModel / config.FaultTolerantModel 兼容
-type Model interface {
-	Invoke(messages []config.Message, tools []map[string]interface{}) (string, []config.ToolCall, error)
-}
+// Model 模型接口，即 config.Provider：ChatModel / FaultTolerantModel / 路由器等均可传入
+type Model = config.Provider
+
+// StreamModel 支持流式输出的模型，即 config.StreamProvider
+type StreamModel = config.StreamProvider
 
 // ToolExecutor 工具执行器：根据工具名和参数执行并返回观察结果
 type ToolExecutor interface {
@@ -33,6 +34,8 @@ type RunInput struct {
 	Messages    []config.Message         // 初始消息（含 system + 历史 + 当前 user）
 	MaxSteps    int                      // 最大 Thought-Action-Observation 轮数
 	ReActPrompt string                   // 追加到 system 的 ReAct 行为说明
+	OnToken     func(token string)       // 可选：逐字回调模型输出（含中间轮的 Thought），需 Model 实现 StreamModel
+	OnStep      func(step Step)          // 可选：每产生一个步骤时回调
 }
 
 // RunResult ReAct 运行结果
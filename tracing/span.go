@@ -0,0 +1,284 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ==================== 链路追踪 ====================
+// 一轮 Agent 对话拆成情绪分析、ReAct 各轮、工具调用、反思等 span，父子关系通过 ctx 传递；
+// 未安装 Tracer 时 Start 返回 nil span，所有方法都是空操作
+
+// SpanKind span 类型（与 OTLP 的 SpanKind 对应）
+type SpanKind string
+
+const (
+	KindInternal SpanKind = "internal" // 进程内的步骤
+	KindClient   SpanKind = "client"   // 对外调用（模型 API）
+)
+
+// SpanData 结束后交给 Exporter 的 span 数据
+type SpanData struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+	Name       string                 `json:"name"`
+	Kind       SpanKind               `json:"kind"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	DurationMs float64                `json:"duration_ms"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"` // 非空表示 span 以错误结束
+}
+
+// Span 进行中的 span，方法均可在 nil 上调用
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// Options Tracer 选项
+type Options struct {
+	ServiceName  string // 服务名（OTLP 的 service.name）
+	MaxTextChars int    // Prompt / 回复等文本属性的最大字符数，默认 500
+	BatchSize    int    // 攒够多少个 span 导出一次，默认 64
+	FlushEvery   time.Duration
+}
+
+// Tracer 收集结束的 span，攒批后交给 Exporter（后台导出，不阻塞调用方）
+type Tracer struct {
+	exporter Exporter
+	opts     Options
+
+	queue chan SpanData
+	flush chan chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewTracer 创建 Tracer 并启动后台导出
+func NewTracer(exporter Exporter, opts Options) *Tracer {
+	if opts.ServiceName == "" {
+		opts.ServiceName = "mygo-chat"
+	}
+	if opts.MaxTextChars <= 0 {
+		opts.MaxTextChars = 500
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 64
+	}
+	if opts.FlushEvery <= 0 {
+		opts.FlushEvery = 2 * time.Second
+	}
+	t := &Tracer{
+		exporter: exporter,
+		opts:     opts,
+		queue:    make(chan SpanData, 1024),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+// ServiceName 服务名
+func (t *Tracer) ServiceName() string {
+	return t.opts.ServiceName
+}
+
+var global atomic.Pointer[Tracer]
+
+// SetTracer 安装全局 Tracer，传 nil 关闭追踪
+func SetTracer(t *Tracer) {
+	global.Store(t)
+}
+
+// Enabled 是否安装了 Tracer
+func Enabled() bool {
+	return global.Load() != nil
+}
+
+type spanKey struct{}
+
+// Start 开始一个 span：ctx 上已有 span 时作为其子 span，否则开启新的 trace
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return StartKind(ctx, name, KindInternal)
+}
+
+// StartKind 与 Start 相同，但指定 span 类型
+func StartKind(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	t := global.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			SpanID: newID(8),
+			Name:   name,
+			Kind:   kind,
+			Start:  time.Now(),
+		},
+	}
+	if parent := SpanFrom(ctx); parent != nil {
+		span.data.TraceID = parent.data.TraceID
+		span.data.ParentID = parent.data.SpanID
+	} else {
+		span.data.TraceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFrom ctx 上当前的 span，没有时返回 nil
+func SpanFrom(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// TraceID span 所属 trace 的 ID
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.TraceID
+}
+
+// SetAttr 设置属性，值应为 string / int / int64 / float64 / bool；span 结束后的设置被忽略
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetText 设置文本属性（Prompt、回复等），超过 MaxTextChars 时截断
+func (s *Span) SetText(key, text string) {
+	if s == nil {
+		return
+	}
+	s.SetAttr(key, truncate(text, s.tracer.opts.MaxTextChars))
+}
+
+// End 结束 span 并交给 Tracer 导出；err 非空时记为错误。重复调用只生效一次
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	s.data.DurationMs = float64(s.data.End.Sub(s.data.Start)) / float64(time.Millisecond)
+	if err != nil {
+		s.data.Error = err.Error()
+	}
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.enqueue(data)
+}
+
+// enqueue 放入导出队列；队列满时丢弃，追踪不应拖慢请求
+func (t *Tracer) enqueue(data SpanData) {
+	select {
+	case t.queue <- data:
+	default:
+	}
+}
+
+// Flush 导出队列中已有的 span（阻塞直到完成）
+func (t *Tracer) Flush() {
+	ack := make(chan struct{})
+	select {
+	case t.flush <- ack:
+		<-ack
+	case <-t.done:
+	}
+}
+
+// Shutdown 导出剩余的 span 并关闭 Exporter
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.Flush()
+	t.once.Do(func() { close(t.done) })
+	return t.exporter.Shutdown(ctx)
+}
+
+func (t *Tracer) loop() {
+	ticker := time.NewTicker(t.opts.FlushEvery)
+	defer ticker.Stop()
+
+	var batch []SpanData
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.ExportSpans(ctx, batch); err != nil {
+			log.Warn().Err(err).Int("spans", len(batch)).Msg("导出 trace 失败")
+		}
+		cancel()
+		batch = nil
+	}
+
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) >= t.opts.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-t.flush:
+			for drained := false; !drained; {
+				select {
+				case data := <-t.queue:
+					batch = append(batch, data)
+				default:
+					drained = true
+				}
+			}
+			export()
+			close(ack)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// truncate 按字符截断
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max]) + "..."
+}
@@ -0,0 +1,34 @@
+export interface SSEEvent {
+  event: string;
+  data: string;
+}
+
+// 读取 fetch 返回的 text/event-stream，逐个回调事件
+// EventSource 只支持 GET，POST 类的流式接口（/api/chat/stream 等）用它解析
+export async function readSSE(response: Response, onEvent: (evt: SSEEvent) => void) {
+  if (!response.body) throw new Error('响应不支持流式读取');
+
+  const reader = response.body.getReader();
+  const decoder = new TextDecoder();
+  let buffer = '';
+
+  for (;;) {
+    const { done, value } = await reader.read();
+    if (done) break;
+    buffer += decoder.decode(value, { stream: true });
+
+    let sep: number;
+    while ((sep = buffer.indexOf('\n\n')) >= 0) {
+      const block = buffer.slice(0, sep);
+      buffer = buffer.slice(sep + 2);
+
+      let event = 'message';
+      const data: string[] = [];
+      for (const line of block.split('\n')) {
+        if (line.startsWith('event:')) event = line.slice(6).trim();
+        else if (line.startsWith('data:')) data.push(line.slice(5).trim());
+      }
+      if (data.length > 0) onEvent({ event, data: data.join('\n') });
+    }
+  }
+}
@@ -1,5 +1,6 @@
 import { useState, useCallback } from 'react';
 import { Message, ChatResponse, PhilosopherType } from '../types';
+import { readSSE } from './sse';
 
 export function useChat() {
   const [messages, setMessages] = useState<Message[]>([]);
@@ -17,8 +18,11 @@ export function useChat() {
     setMessages(prev => [...prev, userMessage]);
     setIsLoading(true);
 
+    const assistantId = `msg_${Date.now()}_reply`;
+    let started = false;
+
     try {
-      const response = await fetch('/api/chat', {
+      const response = await fetch('/api/chat/stream', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({
@@ -30,18 +34,44 @@ export function useChat() {
 
       if (!response.ok) throw new Error('请求失败');
 
-      const data: ChatResponse = await response.json();
+      const done: { result?: ChatResponse } = {};
 
-      const assistantMessage: Message = {
-        id: `msg_${Date.now()}`,
-        role: 'assistant',
-        content: data.response,
-        timestamp: new Date(),
-        philosopher: data.philosopher,
-      };
+      await readSSE(response, ({ event, data }) => {
+        if (event === 'token') {
+          const { content: token } = JSON.parse(data) as { content: string };
+          if (!started) {
+            started = true;
+            setIsLoading(false);
+            setMessages(prev => [...prev, {
+              id: assistantId,
+              role: 'assistant',
+              content: token,
+              timestamp: new Date(),
+            }]);
+          } else {
+            setMessages(prev => prev.map(m => m.id === assistantId ? { ...m, content: m.content + token } : m));
+          }
+        } else if (event === 'done') {
+          const final = JSON.parse(data) as ChatResponse;
+          done.result = final;
+          const assistantMessage: Message = {
+            id: assistantId,
+            role: 'assistant',
+            content: final.response,
+            timestamp: new Date(),
+            philosopher: final.philosopher,
+          };
+          setMessages(prev => started
+            ? prev.map(m => m.id === assistantId ? assistantMessage : m)
+            : [...prev, assistantMessage]);
+          started = true;
+        } else if (event === 'error') {
+          throw new Error((JSON.parse(data) as { error: string }).error);
+        }
+      });
 
-      setMessages(prev => [...prev, assistantMessage]);
-      return data;
+      if (!done.result) throw new Error('连接中断');
+      return done.result;
     } catch (error) {
       console.error('Chat error:', error);
       const errorMessage: Message = {
@@ -50,7 +80,7 @@ export function useChat() {
         content: '抱歉，系统暂时出了点问题...迷子でもいい，但现在真的连不上了。',
         timestamp: new Date(),
       };
-      setMessages(prev => [...prev, errorMessage]);
+      setMessages(prev => [...prev.filter(m => m.id !== assistantId), errorMessage]);
       throw error;
     } finally {
       setIsLoading(false);
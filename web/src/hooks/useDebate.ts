@@ -1,5 +1,5 @@
 import { useState, useCallback, useRef } from 'react';
-import { DebateResponse, PhilosopherType } from '../types';
+import { DebateRecord, DebateResponse, PhilosopherType } from '../types';
 
 interface DebateConfig {
   topic: string;
@@ -12,7 +12,52 @@ interface DebateConfig {
 export function useDebate() {
   const [debate, setDebate] = useState<DebateResponse | null>(null);
   const [isLoading, setIsLoading] = useState(false);
-  const pollingRef = useRef<number | null>(null);
+  const sourceRef = useRef<EventSource | null>(null);
+
+  const stopListening = useCallback(() => {
+    if (sourceRef.current) {
+      sourceRef.current.close();
+      sourceRef.current = null;
+    }
+  }, []);
+
+  // 订阅辩论事件（SSE），服务端会先补发已有发言再推送后续事件
+  const listen = useCallback((debateId: string) => {
+    stopListening();
+
+    const source = new EventSource(`/api/debate/events?id=${debateId}`);
+    sourceRef.current = source;
+
+    source.addEventListener('phase', (e) => {
+      const { phase } = JSON.parse((e as MessageEvent).data) as { phase: string };
+      setDebate(prev => prev && { ...prev, status: 'running', current_phase: phase });
+    });
+
+    source.addEventListener('speech', (e) => {
+      const record = JSON.parse((e as MessageEvent).data) as DebateRecord;
+      setDebate(prev => prev && {
+        ...prev,
+        status: 'running',
+        current_phase: record.phase,
+        records: [...(prev.records ?? []), record],
+      });
+    });
+
+    source.addEventListener('done', (e) => {
+      setDebate(JSON.parse((e as MessageEvent).data) as DebateResponse);
+      setIsLoading(false);
+      stopListening();
+    });
+
+    source.addEventListener('error', (e) => {
+      const data = (e as MessageEvent).data;
+      // 无 data 的 error 是连接层错误
+      const message = data ? (JSON.parse(data) as { error: string }).error : '连接中断';
+      setDebate(prev => ({ ...(prev ?? {}), status: 'failed', error: message }));
+      setIsLoading(false);
+      stopListening();
+    });
+  }, [stopListening]);
 
   const startDebate = useCallback(async (config: DebateConfig) => {
     setIsLoading(true);
@@ -35,50 +80,20 @@ export function useDebate() {
       if (!response.ok) throw new Error('请求失败');
 
       const data: DebateResponse = await response.json();
-      setDebate(data);
+      setDebate({ ...data, records: [] });
 
-      // 开始轮询状态
       if (data.id) {
-        pollStatus(data.id);
+        listen(data.id);
       }
 
       return data;
     } catch (error) {
       console.error('Debate error:', error);
       setDebate({ status: 'failed', error: '启动讨论失败' });
+      setIsLoading(false);
       throw error;
     }
-  }, []);
-
-  const pollStatus = useCallback(async (debateId: string) => {
-    const poll = async () => {
-      try {
-        const response = await fetch(`/api/debate/status?id=${debateId}`);
-        if (!response.ok) throw new Error('查询失败');
-
-        const data: DebateResponse = await response.json();
-        setDebate(data);
-
-        if (data.status === 'running' || data.status === 'pending') {
-          pollingRef.current = window.setTimeout(poll, 2000);
-        } else {
-          setIsLoading(false);
-        }
-      } catch (error) {
-        console.error('Poll error:', error);
-        setIsLoading(false);
-      }
-    };
-
-    poll();
-  }, []);
-
-  const stopPolling = useCallback(() => {
-    if (pollingRef.current) {
-      clearTimeout(pollingRef.current);
-      pollingRef.current = null;
-    }
-  }, []);
+  }, [listen]);
 
-  return { debate, isLoading, startDebate, stopPolling };
+  return { debate, isLoading, startDebate, stopListening };
 }
@@ -8,11 +8,28 @@ export interface Message {
   philosopher?: string;
 }
 
+export interface UsageTotal {
+  calls: number;
+  prompt_tokens: number;
+  completion_tokens: number;
+  total_tokens: number;
+  cost: number;
+}
+
+export interface UsageSummary extends UsageTotal {
+  currency?: string;
+  by_scope?: Record<string, UsageTotal>;
+  by_model?: Record<string, UsageTotal>;
+  by_speaker?: Record<string, UsageTotal>;
+}
+
 export interface ChatResponse {
   response: string;
   philosopher: string;
   emotion_level: string;
   critical_hit: boolean;
+  usage?: UsageSummary;
+  session_usage?: UsageSummary;
 }
 
 export interface DebateRecord {
@@ -27,6 +44,7 @@ export interface DebateResponse {
   topic?: string;
   current_phase?: string;
   records?: DebateRecord[];
+  usage?: UsageSummary;
   error?: string;
 }
 